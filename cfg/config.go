@@ -1,25 +1,375 @@
 package cfg
 
-//local go run main.go
-
-//var (
-//	DBPort     = "5432"
-//	DBUser     = "postgres"
-//	DBPassword = "postgres"
-//	DBHost     = "localhost"
-//	DBName     = "postgres"
-//	DBMaxCon   = 100
-//	ApiPort    = ":5000"
-//)
-
-// conf for docker run
-
-var (
-	DBPort     = "5432"
-	DBUser     = "dyndtikj"
-	DBPassword = "postgres_pw"
-	DBHost     = "localhost"
-	DBName     = "forum"
-	DBMaxCon   = 100
-	ApiPort    = ":5000"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
+
+// Config is the runtime configuration of the service.
+//
+// Every leaf field can be set from a config file (yaml/json key), from the
+// environment (env tag) and from the command line (flag tag). Precedence is
+// flags > environment > file > defaults.
+type Config struct {
+	Storage   string    `yaml:"storage" json:"storage" env:"FORUM_STORAGE" flag:"storage" usage:"where data is kept: postgres or memory"`
+	Log       Log       `yaml:"log" json:"log"`
+	DB        DB        `yaml:"db" json:"db"`
+	API       API       `yaml:"api" json:"api"`
+	GRPC      GRPC      `yaml:"grpc" json:"grpc"`
+	GraphQL   GraphQL   `yaml:"graphql" json:"graphql"`
+	Websocket Websocket `yaml:"websocket" json:"websocket"`
+	Metrics   Metrics   `yaml:"metrics" json:"metrics"`
+	Tracing   Tracing   `yaml:"tracing" json:"tracing"`
+	Auth      Auth      `yaml:"auth" json:"auth"`
+	Threads   Threads   `yaml:"threads" json:"threads"`
+}
+
+// Storage backends.
+const (
+	StoragePostgres = "postgres"
+	// StorageMemory keeps everything in process memory and loses it on
+	// exit. Used for demos and tests, the db section is ignored.
+	StorageMemory = "memory"
+)
+
+// Log is the structured logger every component writes to.
+type Log struct {
+	Level  string `yaml:"level" json:"level" env:"FORUM_LOG_LEVEL" flag:"log-level" usage:"least severe level logged: debug, info, warn or error"`
+	Format string `yaml:"format" json:"format" env:"FORUM_LOG_FORMAT" flag:"log-format" usage:"log line format: text or json"`
+	Access bool   `yaml:"access" json:"access" env:"FORUM_LOG_ACCESS" flag:"log-access" usage:"log every http request and grpc call"`
+}
+
+// Log formats.
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+type DB struct {
+	Host     string `yaml:"host" json:"host" env:"FORUM_DB_HOST" flag:"db-host" usage:"postgres host"`
+	Port     string `yaml:"port" json:"port" env:"FORUM_DB_PORT" flag:"db-port" usage:"postgres port"`
+	User     string `yaml:"user" json:"user" env:"FORUM_DB_USER" flag:"db-user" usage:"postgres user"`
+	Password string `yaml:"password" json:"password" env:"FORUM_DB_PASSWORD" flag:"db-password" usage:"postgres password" secret:"true"`
+	Name     string `yaml:"name" json:"name" env:"FORUM_DB_NAME" flag:"db-name" usage:"postgres database name"`
+	MaxConns int    `yaml:"max_conns" json:"max_conns" env:"FORUM_DB_MAX_CONNS" flag:"db-max-conns" usage:"max open connections to postgres"`
+	MinConns int    `yaml:"min_conns" json:"min_conns" env:"FORUM_DB_MIN_CONNS" flag:"db-min-conns" usage:"connections kept open even when idle"`
+
+	ConnectTimeout    time.Duration `yaml:"connect_timeout" json:"connect_timeout" env:"FORUM_DB_CONNECT_TIMEOUT" flag:"db-connect-timeout" usage:"timeout for dialing a new connection"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" json:"max_conn_lifetime" env:"FORUM_DB_MAX_CONN_LIFETIME" flag:"db-max-conn-lifetime" usage:"connections older than this are recycled"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time" json:"max_conn_idle_time" env:"FORUM_DB_MAX_CONN_IDLE_TIME" flag:"db-max-conn-idle-time" usage:"idle connections above min_conns are closed after this"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period" json:"health_check_period" env:"FORUM_DB_HEALTH_CHECK_PERIOD" flag:"db-health-check-period" usage:"how often idle connections are checked"`
+	StatementCache    int           `yaml:"statement_cache" json:"statement_cache" env:"FORUM_DB_STATEMENT_CACHE" flag:"db-statement-cache" usage:"prepared statements cached per connection, 0 disables"`
+	CopyThreshold     int           `yaml:"copy_threshold" json:"copy_threshold" env:"FORUM_DB_COPY_THRESHOLD" flag:"db-copy-threshold" usage:"post batches larger than this are inserted with COPY, 0 disables"`
+	SlowQuery         time.Duration `yaml:"slow_query" json:"slow_query" env:"FORUM_DB_SLOW_QUERY" flag:"db-slow-query" usage:"queries taking longer are logged at warn, 0 disables; log.level debug logs every query"`
+}
+
+type API struct {
+	Addr            string        `yaml:"addr" json:"addr" env:"FORUM_API_ADDR" flag:"api-addr" usage:"http listen address"`
+	RequestTimeout  time.Duration `yaml:"request_timeout" json:"request_timeout" env:"FORUM_API_REQUEST_TIMEOUT" flag:"api-request-timeout" usage:"deadline for the database work of a single request; a client disconnect cancels it early on TCP and unix listeners"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" json:"idle_timeout" env:"FORUM_API_IDLE_TIMEOUT" flag:"api-idle-timeout" usage:"keep-alive connection idle timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout" env:"FORUM_API_SHUTDOWN_TIMEOUT" flag:"api-shutdown-timeout" usage:"how long to drain in-flight requests on SIGTERM"`
+}
+
+// GRPC is the gRPC listener, see doc/forum.proto. It shares
+// api.request_timeout and api.shutdown_timeout with the http server.
+type GRPC struct {
+	Addr string `yaml:"addr" json:"addr" env:"FORUM_GRPC_ADDR" flag:"grpc-addr" usage:"grpc listen address, empty disables grpc"`
+}
+
+// GraphQL limits the queries of /api/graphql, 0 disables a limit.
+type GraphQL struct {
+	MaxDepth      int `yaml:"max_depth" json:"max_depth" env:"FORUM_GRAPHQL_MAX_DEPTH" flag:"graphql-max-depth" usage:"deepest nesting of fields a graphql query may have"`
+	MaxComplexity int `yaml:"max_complexity" json:"max_complexity" env:"FORUM_GRAPHQL_MAX_COMPLEXITY" flag:"graphql-max-complexity" usage:"most fields a graphql query may resolve, list fields count limit times"`
+}
+
+// Websocket configures the forum activity sockets of /api/forum/activity.
+type Websocket struct {
+	SendBuffer   int           `yaml:"send_buffer" json:"send_buffer" env:"FORUM_WEBSOCKET_SEND_BUFFER" flag:"websocket-send-buffer" usage:"updates queued for a socket before it counts as slow"`
+	SlowConsumer string        `yaml:"slow_consumer" json:"slow_consumer" env:"FORUM_WEBSOCKET_SLOW_CONSUMER" flag:"websocket-slow-consumer" usage:"what a slow socket gets: drop (updates, counted in missed) or disconnect"`
+	MaxForums    int           `yaml:"max_forums" json:"max_forums" env:"FORUM_WEBSOCKET_MAX_FORUMS" flag:"websocket-max-forums" usage:"forums one socket may follow, 0 disables the limit"`
+	PingInterval time.Duration `yaml:"ping_interval" json:"ping_interval" env:"FORUM_WEBSOCKET_PING_INTERVAL" flag:"websocket-ping-interval" usage:"how often sockets are pinged, no pong within two intervals closes them"`
+}
+
+// What happens to a socket that does not read its updates fast enough.
+const (
+	SlowConsumerDrop       = "drop"
+	SlowConsumerDisconnect = "disconnect"
+)
+
+// Metrics are served in the prometheus text format by the http server.
+type Metrics struct {
+	Path string `yaml:"path" json:"path" env:"FORUM_METRICS_PATH" flag:"metrics-path" usage:"http path of the prometheus metrics, empty disables it"`
+}
+
+// Tracing exports OpenTelemetry spans of the http requests and of the
+// postgres queries made for them.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" json:"exporter" env:"FORUM_TRACING_EXPORTER" flag:"tracing-exporter" usage:"where spans go: none, stdout, file or otlp"`
+	File        string  `yaml:"file" json:"file" env:"FORUM_TRACING_FILE" flag:"tracing-file" usage:"file spans are appended to as JSON lines, for exporter file"`
+	Endpoint    string  `yaml:"endpoint" json:"endpoint" env:"FORUM_TRACING_ENDPOINT" flag:"tracing-endpoint" usage:"OTLP/HTTP collector URL, for exporter otlp"`
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio" env:"FORUM_TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"share of requests traced unless the caller sent a sampled traceparent"`
+}
+
+// Auth makes the write routes act as the user of a bearer token instead of
+// the nickname in the request. Off by default, the course test tool sends
+// no tokens.
+type Auth struct {
+	Enabled      bool          `yaml:"enabled" json:"enabled" env:"FORUM_AUTH_ENABLED" flag:"auth-enabled" usage:"require a bearer token on the write routes"`
+	Secret       string        `yaml:"secret" json:"secret" env:"FORUM_AUTH_SECRET" flag:"auth-secret" usage:"HMAC key tokens are signed with, at least 32 bytes" secret:"true"`
+	TokenTTL     time.Duration `yaml:"token_ttl" json:"token_ttl" env:"FORUM_AUTH_TOKEN_TTL" flag:"auth-token-ttl" usage:"how long an issued token is valid"`
+	PasswordCost int           `yaml:"password_cost" json:"password_cost" env:"FORUM_AUTH_PASSWORD_COST" flag:"auth-password-cost" usage:"bcrypt cost of stored passwords"`
+	Admins       []string      `yaml:"admins" json:"admins" env:"FORUM_AUTH_ADMINS" flag:"auth-admins" usage:"comma separated nicknames of registered users granted the admin role at startup, with memory storage on registration"`
+	// OpenModeration lets anyone moderate while auth is disabled, there is
+	// no caller then to hold a role.
+	OpenModeration bool `yaml:"open_moderation" json:"open_moderation" env:"FORUM_AUTH_OPEN_MODERATION" flag:"auth-open-moderation" usage:"without auth, let anyone close, pin and delete threads and delete post trees"`
+}
+
+// MinSecret is the shortest auth.secret accepted, the size of an HMAC-SHA256
+// key.
+const MinSecret = 32
+
+// Threads are the rules of threads moderators closed or pinned.
+type Threads struct {
+	ClosedVotes bool `yaml:"closed_votes" json:"closed_votes" env:"FORUM_THREADS_CLOSED_VOTES" flag:"threads-closed-votes" usage:"let closed threads still take votes, they never take posts"`
+}
+
+// Span exporters.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Default returns the configuration used by the docker image.
+func Default() Config {
+	return Config{
+		Storage: StoragePostgres,
+		Log: Log{
+			Level:  "info",
+			Format: LogText,
+			Access: true,
+		},
+		DB: DB{
+			Host:     "localhost",
+			Port:     "5432",
+			User:     "dyndtikj",
+			Password: "postgres_pw",
+			Name:     "forum",
+			MaxConns: 100,
+			MinConns: 10,
+
+			ConnectTimeout:    5 * time.Second,
+			MaxConnLifetime:   time.Hour,
+			MaxConnIdleTime:   30 * time.Minute,
+			HealthCheckPeriod: time.Minute,
+			StatementCache:    512,
+			CopyThreshold:     1000,
+			SlowQuery:         200 * time.Millisecond,
+		},
+		API: API{
+			Addr:            ":5000",
+			RequestTimeout:  10 * time.Second,
+			IdleTimeout:     time.Minute,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		GRPC: GRPC{
+			Addr: ":5001",
+		},
+		GraphQL: GraphQL{
+			MaxDepth:      10,
+			MaxComplexity: 10000,
+		},
+		Websocket: Websocket{
+			SendBuffer:   256,
+			SlowConsumer: SlowConsumerDisconnect,
+			MaxForums:    100,
+			PingInterval: 30 * time.Second,
+		},
+		Metrics: Metrics{
+			Path: "/metrics",
+		},
+		Tracing: Tracing{
+			Exporter:    ExporterNone,
+			File:        "traces.jsonl",
+			Endpoint:    "http://localhost:4318",
+			SampleRatio: 1,
+		},
+		Auth: Auth{
+			TokenTTL:     24 * time.Hour,
+			PasswordCost: 10,
+		},
+		Threads: Threads{
+			ClosedVotes: true,
+		},
+	}
+}
+
+// DSN returns the postgres connection URL. Every value is escaped, so
+// passwords may hold spaces, quotes and backslashes.
+func (c DB) DSN() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.Host, c.Port),
+		Path:     "/" + c.Name,
+		RawQuery: url.Values{"sslmode": {"disable"}}.Encode(),
+	}
+	return u.String()
+}
+
+// Validate reports every invalid value at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Storage != StoragePostgres && c.Storage != StorageMemory {
+		errs = append(errs, fmt.Errorf("storage: %q is not one of %s, %s", c.Storage, StoragePostgres, StorageMemory))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %q is not one of debug, info, warn, error", c.Log.Level))
+	}
+	if c.Log.Format != LogText && c.Log.Format != LogJSON {
+		errs = append(errs, fmt.Errorf("log.format: %q is not one of %s, %s", c.Log.Format, LogText, LogJSON))
+	}
+	if c.DB.Host == "" {
+		errs = append(errs, errors.New("db.host: must not be empty"))
+	}
+	if port, err := strconv.Atoi(c.DB.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("db.port: %q is not a valid port", c.DB.Port))
+	}
+	if c.DB.User == "" {
+		errs = append(errs, errors.New("db.user: must not be empty"))
+	}
+	if c.DB.Name == "" {
+		errs = append(errs, errors.New("db.name: must not be empty"))
+	}
+	if c.DB.MaxConns < 1 {
+		errs = append(errs, fmt.Errorf("db.max_conns: must be positive, got %d", c.DB.MaxConns))
+	}
+	if c.DB.MinConns < 0 || c.DB.MinConns > c.DB.MaxConns {
+		errs = append(errs, fmt.Errorf("db.min_conns: must be between 0 and db.max_conns, got %d", c.DB.MinConns))
+	}
+	if c.DB.ConnectTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("db.connect_timeout: must be positive, got %s", c.DB.ConnectTimeout))
+	}
+	if c.DB.MaxConnLifetime <= 0 {
+		errs = append(errs, fmt.Errorf("db.max_conn_lifetime: must be positive, got %s", c.DB.MaxConnLifetime))
+	}
+	if c.DB.MaxConnIdleTime <= 0 {
+		errs = append(errs, fmt.Errorf("db.max_conn_idle_time: must be positive, got %s", c.DB.MaxConnIdleTime))
+	}
+	if c.DB.HealthCheckPeriod <= 0 {
+		errs = append(errs, fmt.Errorf("db.health_check_period: must be positive, got %s", c.DB.HealthCheckPeriod))
+	}
+	if c.DB.StatementCache < 0 {
+		errs = append(errs, fmt.Errorf("db.statement_cache: must not be negative, got %d", c.DB.StatementCache))
+	}
+	if c.DB.CopyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("db.copy_threshold: must not be negative, got %d", c.DB.CopyThreshold))
+	}
+	if c.DB.SlowQuery < 0 {
+		errs = append(errs, fmt.Errorf("db.slow_query: must not be negative, got %s", c.DB.SlowQuery))
+	}
+	if _, port, err := net.SplitHostPort(c.API.Addr); err != nil || port == "" {
+		errs = append(errs, fmt.Errorf("api.addr: %q is not a valid listen address", c.API.Addr))
+	}
+	if c.API.RequestTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("api.request_timeout: must be positive, got %s", c.API.RequestTimeout))
+	}
+	if c.API.IdleTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("api.idle_timeout: must be positive, got %s", c.API.IdleTimeout))
+	}
+	if c.API.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("api.shutdown_timeout: must be positive, got %s", c.API.ShutdownTimeout))
+	}
+	if c.GRPC.Addr != "" {
+		if _, port, err := net.SplitHostPort(c.GRPC.Addr); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("grpc.addr: %q is not a valid listen address", c.GRPC.Addr))
+		} else if c.GRPC.Addr == c.API.Addr {
+			errs = append(errs, fmt.Errorf("grpc.addr: %q is already used by api.addr", c.GRPC.Addr))
+		}
+	}
+	if c.GraphQL.MaxDepth < 0 {
+		errs = append(errs, fmt.Errorf("graphql.max_depth: must not be negative, got %d", c.GraphQL.MaxDepth))
+	}
+	if c.GraphQL.MaxComplexity < 0 {
+		errs = append(errs, fmt.Errorf("graphql.max_complexity: must not be negative, got %d", c.GraphQL.MaxComplexity))
+	}
+	if c.Websocket.SendBuffer < 1 {
+		errs = append(errs, fmt.Errorf("websocket.send_buffer: must be positive, got %d", c.Websocket.SendBuffer))
+	}
+	if c.Websocket.SlowConsumer != SlowConsumerDrop && c.Websocket.SlowConsumer != SlowConsumerDisconnect {
+		errs = append(errs, fmt.Errorf("websocket.slow_consumer: %q is not one of %s, %s", c.Websocket.SlowConsumer, SlowConsumerDrop, SlowConsumerDisconnect))
+	}
+	if c.Websocket.MaxForums < 0 {
+		errs = append(errs, fmt.Errorf("websocket.max_forums: must not be negative, got %d", c.Websocket.MaxForums))
+	}
+	if c.Websocket.PingInterval <= 0 {
+		errs = append(errs, fmt.Errorf("websocket.ping_interval: must be positive, got %s", c.Websocket.PingInterval))
+	}
+	if p := c.Metrics.Path; p != "" && (!strings.HasPrefix(p, "/") || p == "/api" || strings.HasPrefix(p, "/api/")) {
+		errs = append(errs, fmt.Errorf("metrics.path: %q must start with / and be outside of /api", p))
+	}
+	switch c.Tracing.Exporter {
+	case ExporterNone, ExporterStdout:
+	case ExporterFile:
+		if c.Tracing.File == "" {
+			errs = append(errs, errors.New("tracing.file: must not be empty with exporter file"))
+		}
+	case ExporterOTLP:
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint: %q is not an http(s) URL", c.Tracing.Endpoint))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q is not one of %s, %s, %s, %s", c.Tracing.Exporter, ExporterNone, ExporterStdout, ExporterFile, ExporterOTLP))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio: must be between 0 and 1, got %g", c.Tracing.SampleRatio))
+	}
+
+	if c.Auth.Enabled && len(c.Auth.Secret) < MinSecret {
+		errs = append(errs, fmt.Errorf("auth.secret: must be at least %d bytes with auth enabled, got %d", MinSecret, len(c.Auth.Secret)))
+	}
+	if c.Auth.TokenTTL <= 0 {
+		errs = append(errs, fmt.Errorf("auth.token_ttl: must be positive, got %s", c.Auth.TokenTTL))
+	}
+	if c.Auth.PasswordCost < 4 || c.Auth.PasswordCost > 31 {
+		errs = append(errs, fmt.Errorf("auth.password_cost: must be between 4 and 31, got %d", c.Auth.PasswordCost))
+	}
+
+	return errors.Join(errs...)
+}
+
+// String prints the config one key per line with secrets redacted.
+func (c Config) String() string {
+	var b strings.Builder
+	for _, f := range fields(&c) {
+		val := fmt.Sprint(f.value.Interface())
+		if f.secret && val != "" {
+			val = "******"
+		}
+		fmt.Fprintf(&b, "%s=%s\n", f.key, val)
+	}
+	return b.String()
+}
+
+// LogValue logs the config as a group of its keys, secrets masked like in
+// String.
+func (c Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, f := range fields(&c) {
+		val := fmt.Sprint(f.value.Interface())
+		if f.secret && val != "" {
+			val = "******"
+		}
+		attrs = append(attrs, slog.String(f.key, val))
+	}
+	return slog.GroupValue(attrs...)
+}
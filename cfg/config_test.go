@@ -0,0 +1,24 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestDSN(t *testing.T) {
+	db := Default().DB
+	db.User = "forum user"
+	db.Password = `p@ss word' \\ sslmode=require /?#`
+	db.Name = "forum db"
+	db.Host = "::1"
+
+	conf, err := pgconn.ParseConfig(db.DSN())
+	if err != nil {
+		t.Fatalf("ParseConfig(%s): %v", db.DSN(), err)
+	}
+	if conf.User != db.User || conf.Password != db.Password || conf.Database != db.Name || conf.Host != db.Host || conf.TLSConfig != nil {
+		t.Errorf("DSN %s parsed as user %q password %q database %q host %q tls %v",
+			db.DSN(), conf.User, conf.Password, conf.Database, conf.Host, conf.TLSConfig != nil)
+	}
+}
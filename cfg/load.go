@@ -0,0 +1,179 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configEnv = "FORUM_CONFIG"
+
+// Load builds the config from defaults, the file given by -config (or
+// FORUM_CONFIG), FORUM_* environment variables and command line flags.
+// args must not include the program name. Positional arguments left after
+// the flags are returned so the caller can treat them as a subcommand.
+func Load(args []string) (Config, []string, error) {
+	conf := Default()
+
+	fs := flag.NewFlagSet("forum", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(configEnv), "path to yaml or json config file (env "+configEnv+")")
+
+	fromFlags := map[string]string{}
+	for _, f := range fields(&conf) {
+		if f.flag == "" {
+			continue
+		}
+		name := f.flag
+		fs.Func(name, fmt.Sprintf("%s (env %s)", f.usage, f.env), func(s string) error {
+			fromFlags[name] = s
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *configPath != "" {
+		if err := readFile(*configPath, &conf); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	for _, f := range fields(&conf) {
+		if f.env == "" {
+			continue
+		}
+		if s, ok := os.LookupEnv(f.env); ok {
+			if err := f.set(s); err != nil {
+				return Config{}, nil, fmt.Errorf("env %s: %w", f.env, err)
+			}
+		}
+	}
+
+	for _, f := range fields(&conf) {
+		if s, ok := fromFlags[f.flag]; ok {
+			if err := f.set(s); err != nil {
+				return Config{}, nil, fmt.Errorf("flag -%s: %w", f.flag, err)
+			}
+		}
+	}
+
+	if err := conf.Validate(); err != nil {
+		return Config{}, nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	return conf, fs.Args(), nil
+}
+
+func readFile(path string, conf *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(conf)
+	case ".yml", ".yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(conf)
+	default:
+		return fmt.Errorf("read config %s: unsupported extension, want .yml, .yaml or .json", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+type field struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// fields flattens the config struct into its settable leaves.
+func fields(conf *Config) []field {
+	var res []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key := prefix + strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			res = append(res, field{
+				key:    key,
+				env:    sf.Tag.Get("env"),
+				flag:   sf.Tag.Get("flag"),
+				usage:  sf.Tag.Get("usage"),
+				secret: sf.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(conf).Elem(), "")
+	return res
+}
+
+func (f field) set(s string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(s)
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case float64:
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(x)
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case []string:
+		// comma separated, like FORUM_AUTH_ADMINS=alice,bob
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported config type %s", f.value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"park_db_course/doc"
+	httphandlers "park_db_course/internal/api/http"
+
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+)
+
+var contract = flag.Bool("contract", true, "check every request/response pair of the handler suite against doc/swagger.yml")
+
+var (
+	specOnce sync.Once
+	specDoc  *loads.Document
+	specErr  error
+)
+
+// apiSpec loads doc/swagger.yml with every $ref resolved. Response objects
+// are closed, so a field the spec does not know (e.g. is_edited instead of
+// isEdited) is reported rather than silently accepted.
+func apiSpec(t *testing.T) *loads.Document {
+	t.Helper()
+	specOnce.Do(func() {
+		if specDoc, specErr = httphandlers.LoadSpec(doc.Swagger); specErr != nil {
+			return
+		}
+		for _, item := range specDoc.Spec().Paths.Paths {
+			for _, op := range []*spec.Operation{item.Get, item.Post} {
+				if op == nil || op.Responses == nil {
+					continue
+				}
+				for _, resp := range op.Responses.StatusCodeResponses {
+					closeSchema(resp.Schema)
+				}
+			}
+		}
+	})
+	if specErr != nil {
+		t.Fatalf("load doc/swagger.yml: %v", specErr)
+	}
+	return specDoc
+}
+
+func closeSchema(s *spec.Schema) {
+	if s == nil {
+		return
+	}
+	if len(s.Properties) > 0 {
+		s.AdditionalProperties = &spec.SchemaOrBool{Allows: false}
+		for name, prop := range s.Properties {
+			closeSchema(&prop)
+			s.Properties[name] = prop
+		}
+	}
+	if s.Items != nil {
+		closeSchema(s.Items.Schema)
+	}
+}
+
+// contractErrors checks one request/response pair against the spec: the
+// route and status code must be documented and the body must match the
+// response schema. Requests that succeeded must match their parameters,
+// a 2xx for a request the spec forbids is drift as well.
+func contractErrors(doc *loads.Document, method, rawPath, reqBody string, status int, respBody []byte) []error {
+	u, err := url.Parse(rawPath)
+	if err != nil {
+		return []error{err}
+	}
+	op, pathParams := findOperation(doc, method, strings.TrimPrefix(u.Path, doc.BasePath()))
+	if op == nil {
+		return []error{fmt.Errorf("%s %s is not in the spec", method, u.Path)}
+	}
+
+	var errs []error
+	resp, ok := op.Responses.StatusCodeResponses[status]
+	switch {
+	case !ok:
+		errs = append(errs, fmt.Errorf("%s: status %d is not documented", op.ID, status))
+	case resp.Schema != nil:
+		var data interface{}
+		if err := json.Unmarshal(respBody, &data); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %d body %q is not JSON: %v", op.ID, status, respBody, err))
+		} else if err := validate.AgainstSchema(resp.Schema, data, strfmt.Default); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %d body %s does not match the spec: %v", op.ID, status, respBody, err))
+		}
+	case len(respBody) != 0:
+		errs = append(errs, fmt.Errorf("%s: %d has no body in the spec, got %s", op.ID, status, respBody))
+	}
+
+	if status >= 200 && status < 300 {
+		errs = append(errs, requestErrors(op, pathParams, u.Query(), reqBody)...)
+	}
+	return errs
+}
+
+// findOperation matches a path without the base path against the spec
+// templates, e.g. /thread/{slug_or_id}/create.
+func findOperation(doc *loads.Document, method, path string) (*spec.Operation, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for tmpl, item := range doc.Spec().Paths.Paths {
+		tmplSegments := strings.Split(strings.Trim(tmpl, "/"), "/")
+		if len(tmplSegments) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		for i, s := range tmplSegments {
+			if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+				params[strings.Trim(s, "{}")] = segments[i]
+			} else if s != segments[i] {
+				params = nil
+				break
+			}
+		}
+		if params == nil {
+			continue
+		}
+		switch method {
+		case "GET":
+			return item.Get, params
+		case "POST":
+			return item.Post, params
+		case "PUT":
+			return item.Put, params
+		case "DELETE":
+			return item.Delete, params
+		}
+	}
+	return nil, nil
+}
+
+func requestErrors(op *spec.Operation, pathParams map[string]string, query url.Values, body string) []error {
+	var errs []error
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "body":
+			if body == "" {
+				if p.Required {
+					errs = append(errs, fmt.Errorf("%s: body %s is required", op.ID, p.Name))
+				}
+				continue
+			}
+			var data interface{}
+			if err := json.Unmarshal([]byte(body), &data); err != nil {
+				errs = append(errs, fmt.Errorf("%s: request body is not JSON: %v", op.ID, err))
+			} else if err := validate.AgainstSchema(p.Schema, data, strfmt.Default); err != nil {
+				errs = append(errs, fmt.Errorf("%s: request body %s does not match the spec: %v", op.ID, body, err))
+			}
+		case "path", "query":
+			raw, ok := pathParams[p.Name], true
+			if p.In == "query" {
+				_, ok = query[p.Name]
+				raw = query.Get(p.Name)
+			}
+			if !ok {
+				if p.Required {
+					errs = append(errs, fmt.Errorf("%s: %s parameter %s is required", op.ID, p.In, p.Name))
+				}
+				continue
+			}
+			if err := validateParam(p, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s parameter %s=%q: %v", op.ID, p.In, p.Name, raw, err))
+			}
+		}
+	}
+	return errs
+}
+
+// validateParam converts a raw path or query value to the declared type
+// and checks it against the constraints of the parameter.
+func validateParam(p spec.Parameter, raw string) error {
+	schema := &spec.Schema{SchemaProps: spec.SchemaProps{
+		Type:             spec.StringOrArray{p.Type},
+		Format:           p.Format,
+		Enum:             p.Enum,
+		Minimum:          p.Minimum,
+		Maximum:          p.Maximum,
+		ExclusiveMinimum: p.ExclusiveMinimum,
+		ExclusiveMaximum: p.ExclusiveMaximum,
+		Pattern:          p.Pattern,
+	}}
+	value, err := convertParam(p.Type, raw)
+	if err != nil {
+		return err
+	}
+	if p.Type == "array" && p.Items != nil {
+		schema.Items = &spec.SchemaOrArray{Schema: &spec.Schema{SchemaProps: spec.SchemaProps{
+			Type: spec.StringOrArray{p.Items.Type},
+			Enum: p.Items.Enum,
+		}}}
+	}
+	return validate.AgainstSchema(schema, value, strfmt.Default)
+}
+
+func convertParam(typ, raw string) (interface{}, error) {
+	switch typ {
+	case "number", "integer":
+		return strconv.ParseFloat(raw, 64)
+	case "boolean":
+		return strconv.ParseBool(raw)
+	case "array":
+		var items []interface{}
+		for _, s := range strings.Split(raw, ",") {
+			items = append(items, s)
+		}
+		return items, nil
+	}
+	return raw, nil
+}
+
+func TestContractCatchesDrift(t *testing.T) {
+	spec := apiSpec(t)
+
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		reqBody  string
+		status   int
+		respBody string
+	}{
+		{name: "undocumented route", method: "GET", path: "/api/forum/list", status: 200, respBody: `[]`},
+		{name: "undocumented status", method: "GET", path: "/api/forum/f/details", status: 418, respBody: `{}`},
+		{name: "empty body where an object is documented", method: "GET", path: "/api/service/status", status: 200},
+		{name: "renamed field", method: "GET", path: "/api/post/1/details", status: 200,
+			respBody: `{"post":{"id":1,"author":"a","message":"m","is_edited":false}}`},
+		{name: "wrong field type", method: "GET", path: "/api/post/1/details", status: 200,
+			respBody: `{"post":{"id":1,"author":"a","message":"m","isEdited":"no"}}`},
+		{name: "missing required field", method: "GET", path: "/api/user/a/profile", status: 200,
+			respBody: `{"nickname":"a","fullname":"A"}`},
+		{name: "2xx for a request outside the enum", method: "GET", path: "/api/thread/t/posts?sort=random", status: 200,
+			respBody: `[]`},
+		{name: "2xx for an invalid body", method: "POST", path: "/api/thread/t/vote", reqBody: `{"nickname":"a","voice":2}`, status: 200,
+			respBody: `{"title":"t","author":"a","message":"m"}`},
+	}
+	for _, c := range cases {
+		if errs := contractErrors(spec, c.method, c.path, c.reqBody, c.status, []byte(c.respBody)); len(errs) == 0 {
+			t.Errorf("%s: drift was not reported", c.name)
+		}
+	}
+
+	ok := contractErrors(spec, "GET", "/api/thread/t/posts?sort=tree&limit=10&desc=true", "", 200,
+		[]byte(`[{"id":1,"parent":0,"author":"a","message":"m","isEdited":false,"forum":"f","thread":1,"created":"2020-01-01T00:00:00Z"}]`))
+	if len(ok) != 0 {
+		t.Errorf("valid pair rejected: %v", ok)
+	}
+}
@@ -1,77 +1,147 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
 	"park_db_course/cfg"
 	httphandlers "park_db_course/internal/api/http"
+	"park_db_course/internal/logging"
+	"park_db_course/internal/metrics"
 	"park_db_course/internal/repository"
+	"park_db_course/internal/tracing"
+	"syscall"
 
-	"github.com/fasthttp/router"
-	"github.com/jackc/pgx"
 	"github.com/valyala/fasthttp"
 )
 
+// Process exit codes.
+const (
+	exitOK     = 0 // clean shutdown, every in-flight request was served
+	exitError  = 1 // startup or serve failure
+	exitForced = 2 // shutdown deadline expired with requests still in flight
+)
+
 func main() {
-	r := router.New()
+	os.Exit(run())
+}
 
-	dsn := fmt.Sprintf(`user=%s dbname=%s password=%s host=%s port=%s sslmode=disable`,
-		cfg.DBUser, cfg.DBName, cfg.DBPassword, cfg.DBHost, cfg.DBPort)
+func run() int {
+	conf, args, err := cfg.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		slog.Error("load config", "error", err)
+		return exitError
+	}
+	logger := logging.New(os.Stderr, conf.Log)
+	// Whatever still logs through the log package or slog.Default, e.g.
+	// the libraries, ends up in the same stream.
+	slog.SetDefault(logger)
+	logger.Info("config", "config", conf)
 
-	conn, err := pgx.ParseConnectionString(dsn)
+	tp, stopTracing, err := tracing.New(context.Background(), conf.Tracing)
 	if err != nil {
-		log.Fatalln("cant parse cfg", err)
+		logger.Error("start tracing", "error", err)
+		return exitError
 	}
+	defer func() {
+		// Flushes the spans of the drained requests.
+		ctx, cancel := context.WithTimeout(context.Background(), conf.API.ShutdownTimeout)
+		defer cancel()
+		if err := stopTracing(ctx); err != nil {
+			logger.Error("stop tracing", "error", err)
+		}
+	}()
 
-	db, err := pgx.NewConnPool(pgx.ConnPoolConfig{
-		ConnConfig:     conn,
-		MaxConnections: cfg.DBMaxCon,
-		AfterConnect:   nil,
-		AcquireTimeout: 0,
-	})
+	m := metrics.New()
+	// Without auth there is no caller to hold a role.
+	var admins []string
+	if conf.Auth.Enabled {
+		admins = conf.Auth.Admins
+	}
+	var repos repositories
+	switch conf.Storage {
+	case cfg.StorageMemory:
+		if len(args) > 0 {
+			logger.Error("command needs another storage", "command", args[0], "storage", cfg.StoragePostgres)
+			return exitError
+		}
+		repos = newMemoryRepositories(admins)
+	default:
+		db, err := repository.NewPool(context.Background(), conf.DB, logger, tracing.QueryTracer(tp))
+		if err != nil {
+			logger.Error("connect to postgres", "error", err)
+			return exitError
+		}
+		// Closed only after the server drained, so in-flight batches can finish.
+		defer db.Close()
+
+		if len(args) > 0 {
+			switch args[0] {
+			case "migrate":
+				return runMigrate(logger, db, args[1:])
+			case "password":
+				return runPassword(logger, repository.NewUserRepo(db), conf.Auth.PasswordCost, args[1:], os.Stdin)
+			default:
+				logger.Error("unknown command, want migrate or password", "command", args[0])
+				return exitError
+			}
+		}
+		repos = newPostgresRepositories(db, conf.DB)
+		if err = grantAdmins(context.Background(), repos.user, admins, logger); err != nil {
+			logger.Error("grant admins", "error", err)
+			return exitError
+		}
+		m.WatchPool(db)
+	}
 
+	uc := newUsecases(instrumentRepositories(repos, m), conf)
+	// Forum activity is public, every activity socket is let in.
+	handler, err := newRouter(uc, conf, httphandlers.SocketAuth{}, m, tp, logger)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("set up routes", "error", err)
+		return exitError
 	}
 
-	userRepo := repository.NewUserRepo(db)
-	forumRepo := repository.NewForumRepo(db)
-	threadRepo := repository.NewThreadRepo(db)
-	postRepo := repository.NewPostRepo(db)
-	serviceRepo := repository.NewServiceRepo(db)
+	srv := &fasthttp.Server{
+		Handler:         handler,
+		IdleTimeout:     conf.API.IdleTimeout,
+		CloseOnShutdown: true,
+		Logger:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
 
-	userH := httphandlers.NewUserH(userRepo)
-	forumH := httphandlers.NewForumH(forumRepo, userRepo, threadRepo)
-	threadH := httphandlers.NewThreadH(threadRepo, userRepo)
-	postH := httphandlers.NewPostH(postRepo)
-	serviceH := httphandlers.NewServiceH(serviceRepo)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// Register routes
-	// ---------------
-	// forum
-	r.POST("/api/forum/create", forumH.Create)
-	r.GET("/api/forum/{slug}/details", forumH.Details)
-	r.POST("/api/forum/{slug}/create", forumH.CreateThread)
-	r.GET("/api/forum/{slug}/threads", forumH.ForumThreads)
-	r.GET("/api/forum/{slug}/users", forumH.ForumUsers)
-	// post
-	r.GET("/api/post/{id}/details", postH.GetDetails)
-	r.POST("/api/post/{id}/details", postH.UpdateDetails)
-	// service
-	r.GET("/api/service/status", serviceH.Status)
-	r.POST("/api/service/clear", serviceH.Clear)
-	// thread
-	r.POST("/api/thread/{slug_or_id}/create", threadH.CreatePost)
-	r.POST("/api/thread/{slug_or_id}/vote", threadH.CreateVote)
-	r.GET("/api/thread/{slug_or_id}/details", threadH.Details)
-	r.GET("/api/thread/{slug_or_id}/posts", threadH.ThreadPost)
-	r.POST("/api/thread/{slug_or_id}/details", threadH.Update)
-	// user
-	r.POST("/api/user/{nickname}/create", userH.Create)
-	r.GET("/api/user/{nickname}/profile", userH.GetByNickname)
-	r.POST("/api/user/{nickname}/profile", userH.Update)
+	logger.Info("service started", "addr", conf.API.Addr, "storage", conf.Storage)
 
-	fmt.Println("[SERVICE STARTED]", cfg.ApiPort)
+	err = serveAll(ctx, func(ctx context.Context) error {
+		return serve(ctx, logger, srv, conf.API.Addr, conf.API.ShutdownTimeout)
+	}, func(ctx context.Context) error {
+		if conf.GRPC.Addr == "" {
+			return nil
+		}
+		logger.Info("grpc started", "addr", conf.GRPC.Addr)
+		return serveGRPC(ctx, logger, newGRPCServer(uc, conf, logger), conf.GRPC.Addr, conf.API.ShutdownTimeout)
+	}, func(ctx context.Context) error {
+		// Stopping the feed ends the thread streams, so they do not hold up
+		// the drain.
+		return uc.feed.Run(logging.NewContext(ctx, logger))
+	})
+	switch {
+	case errors.Is(err, errForcedShutdown):
+		logger.Error("shutdown", "error", err)
+		return exitForced
+	case err != nil:
+		logger.Error("serve", "error", err)
+		return exitError
+	}
 
-	log.Fatal(fasthttp.ListenAndServe(cfg.ApiPort, r.Handler))
+	logger.Info("service stopped")
+	return exitOK
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"park_db_course/db"
+	"park_db_course/internal/migrate"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = `usage: main [flags] migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [-n N] roll back the last N applied migrations (default 1)
+  status      list migrations and when they were applied
+`
+
+// runMigrate implements the `migrate` subcommand.
+func runMigrate(logger *slog.Logger, pool *pgxpool.Pool, args []string) int {
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return exitError
+	}
+
+	migrations, err := fs.Sub(db.Migrations, "migrations")
+	if err != nil {
+		logger.Error("migrate", "error", err)
+		return exitError
+	}
+	m, err := migrate.New(pool, migrations)
+	if err != nil {
+		logger.Error("migrate", "error", err)
+		return exitError
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			logger.Info("migration applied", "version", mig.Version, "name", mig.Name)
+		}
+		if err != nil {
+			logger.Error("migrate", "error", err)
+			return exitError
+		}
+		if len(applied) == 0 {
+			logger.Info("schema is up to date")
+		}
+	case "down":
+		downFlags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := downFlags.Int("n", 1, "number of migrations to roll back")
+		if err := downFlags.Parse(args[1:]); err != nil || *steps < 1 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return exitError
+		}
+		reverted, err := m.Down(ctx, *steps)
+		for _, mig := range reverted {
+			logger.Info("migration reverted", "version", mig.Version, "name", mig.Name)
+		}
+		if err != nil {
+			logger.Error("migrate", "error", err)
+			return exitError
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			logger.Error("migrate", "error", err)
+			return exitError
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"park_db_course/internal/auth"
+	"park_db_course/internal/repository"
+)
+
+const passwordUsage = `usage: main [flags] password <nickname>
+
+reads the new password of nickname from the first line of stdin, for users
+registered without one, e.g. while auth was disabled
+`
+
+// runPassword implements the `password` subcommand.
+func runPassword(logger *slog.Logger, users repository.UserRepoI, cost int, args []string, stdin io.Reader) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, passwordUsage)
+		return exitError
+	}
+	nickname := args[0]
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		logger.Error("read password", "error", err)
+		return exitError
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		logger.Error("password is empty", "nickname", nickname)
+		return exitError
+	}
+
+	hash, err := auth.HashPassword(password, cost)
+	if err != nil {
+		logger.Error("hash password", "error", err)
+		return exitError
+	}
+	if err = users.SetPassword(context.Background(), nickname, hash); err != nil {
+		logger.Error("set password", "nickname", nickname, "error", err)
+		return exitError
+	}
+	logger.Info("password set", "nickname", nickname)
+	return exitOK
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"park_db_course/internal/auth"
+	"park_db_course/internal/logging"
+	"park_db_course/internal/models"
+)
+
+func TestRunPassword(t *testing.T) {
+	repos := newMemoryRepositories(nil)
+	ctx := context.Background()
+	if _, err := repos.user.Create(ctx, models.User{Nickname: "bob", Fullname: "Bob", Email: "bob@mail.ru"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name  string
+		args  []string
+		stdin string
+		code  int
+	}{
+		{name: "no nickname", stdin: "pearl\n", code: exitError},
+		{name: "empty", args: []string{"bob"}, stdin: "\n", code: exitError},
+		{name: "unknown user", args: []string{"nobody"}, stdin: "pearl\n", code: exitError},
+		{name: "set", args: []string{"BOB"}, stdin: "black pearl\r\nignored\n", code: exitOK},
+	} {
+		if code := runPassword(logging.Discard(), repos.user, 4, c.args, strings.NewReader(c.stdin)); code != c.code {
+			t.Errorf("%s: exit code %d, want %d", c.name, code, c.code)
+		}
+	}
+
+	user, err := repos.user.GetCredentials(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !auth.CheckPassword(user.PasswordHash, "black pearl") {
+		t.Errorf("password of bob is not set to the first line")
+	}
+}
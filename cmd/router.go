@@ -0,0 +1,153 @@
+package main
+
+import (
+	"log/slog"
+
+	"park_db_course/cfg"
+	"park_db_course/doc"
+	"park_db_course/internal/api/graphql"
+	grpcapi "park_db_course/internal/api/grpc"
+	httphandlers "park_db_course/internal/api/http"
+	"park_db_course/internal/auth"
+	"park_db_course/internal/metrics"
+	"park_db_course/internal/usecase"
+
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// usecases is shared by the http and grpc servers, so both apply the same rules.
+type usecases struct {
+	user    usecase.UserUsecaseI
+	forum   usecase.ForumUsecaseI
+	thread  usecase.ThreadUsecaseI
+	post    usecase.PostUsecaseI
+	service usecase.ServiceUsecaseI
+	auth    usecase.AuthUsecaseI
+	// feed must be running for thread streams and activity sockets, see
+	// FeedUsecaseI.Run.
+	feed usecase.FeedUsecaseI
+}
+
+func newUsecases(repos repositories, conf cfg.Config) usecases {
+	authz := usecase.NewAuthorizer(repos.user, repos.forum, conf.Auth.OpenModeration)
+	return usecases{
+		user:    usecase.NewUserUsecase(repos.user, conf.Auth.PasswordCost, authz),
+		forum:   usecase.NewForumUsecase(repos.forum, repos.user, repos.thread, authz),
+		thread:  usecase.NewThreadUsecase(repos.thread, repos.user, authz, conf.Threads.ClosedVotes),
+		post:    usecase.NewPostUsecase(repos.post, authz),
+		service: usecase.NewServiceUsecase(repos.service, authz),
+		auth:    usecase.NewAuthUsecase(repos.user, auth.NewSigner([]byte(conf.Auth.Secret), conf.Auth.TokenTTL)),
+		feed:    usecase.NewFeed(repos.events, repos.thread, repos.post, repos.user),
+	}
+}
+
+// newRouter wires the handlers to the use cases and registers every API route.
+// Each route validates its requests against doc/swagger.yml first. auth
+// guards the forum activity sockets. With auth.enabled the write routes act
+// as the caller of the bearer token. The returned handler bounds requests by
+// api.request_timeout, records them in m and as spans of tp, named by
+// operationId, and logs them with logger under their request id.
+func newRouter(uc usecases, conf cfg.Config, auth httphandlers.SocketAuth, m *metrics.Metrics, tp trace.TracerProvider, logger *slog.Logger) (fasthttp.RequestHandler, error) {
+	spec, err := httphandlers.LoadSpec(doc.Swagger)
+	if err != nil {
+		return nil, err
+	}
+	validator := httphandlers.NewValidator(spec)
+	check := validator.Check
+
+	r := router.New()
+	r.SaveMatchedRoutePath = true
+
+	userH := httphandlers.NewUserH(uc.user)
+	forumH := httphandlers.NewForumH(uc.forum)
+	threadH := httphandlers.NewThreadH(uc.thread, uc.feed)
+	postH := httphandlers.NewPostH(uc.post)
+	authH := httphandlers.NewAuthH(uc.auth)
+	serviceH := httphandlers.NewServiceH(uc.service)
+	graphqlH := httphandlers.NewGraphQLH(graphql.NewExecutor(graphql.Usecases{
+		Users:   uc.user,
+		Forums:  uc.forum,
+		Threads: uc.thread,
+		Posts:   uc.post,
+	}, graphql.Limits{MaxDepth: conf.GraphQL.MaxDepth, MaxComplexity: conf.GraphQL.MaxComplexity}))
+	socketH := httphandlers.NewSocketH(uc.forum, uc.feed, httphandlers.SocketConfig{
+		Follow: usecase.FollowOptions{
+			Buffer:    conf.Websocket.SendBuffer,
+			DropSlow:  conf.Websocket.SlowConsumer == cfg.SlowConsumerDrop,
+			MaxForums: conf.Websocket.MaxForums,
+		},
+		PingInterval:   conf.Websocket.PingInterval,
+		RequestTimeout: conf.API.RequestTimeout,
+		Auth:           auth,
+	})
+
+	// Register routes
+	// ---------------
+	// auth
+	r.POST("/api/auth/login", check(authH.Login))
+	// forum
+	r.POST("/api/forum/create", check(forumH.Create))
+	r.GET("/api/forum/{slug}/details", check(forumH.Details))
+	r.POST("/api/forum/{slug}/create", check(forumH.CreateThread))
+	r.GET("/api/forum/{slug}/threads", check(forumH.ForumThreads))
+	r.GET("/api/forum/{slug}/users", check(forumH.ForumUsers))
+	r.GET("/api/forum/{slug}/moderators", check(forumH.Moderators))
+	r.PUT("/api/forum/{slug}/moderators/{nickname}", check(forumH.AddModerator))
+	r.DELETE("/api/forum/{slug}/moderators/{nickname}", check(forumH.RemoveModerator))
+	r.GET("/api/forum/activity", check(socketH.ForumActivity))
+	// graphql
+	r.POST("/api/graphql", check(graphqlH.Query))
+	// post
+	r.GET("/api/post/{id}/details", check(postH.GetDetails))
+	r.POST("/api/post/{id}/details", check(postH.UpdateDetails))
+	r.DELETE("/api/post/{id}", check(postH.Delete))
+	// service
+	r.GET("/api/service/status", check(serviceH.Status))
+	r.POST("/api/service/clear", check(serviceH.Clear))
+	r.GET("/api/service/pool", check(serviceH.PoolStats))
+	// thread
+	r.POST("/api/thread/{slug_or_id}/create", check(threadH.CreatePost))
+	r.POST("/api/thread/{slug_or_id}/vote", check(threadH.CreateVote))
+	r.GET("/api/thread/{slug_or_id}/details", check(threadH.Details))
+	r.GET("/api/thread/{slug_or_id}/posts", check(threadH.ThreadPost))
+	r.GET("/api/thread/{slug_or_id}/stream", check(threadH.Stream))
+	r.POST("/api/thread/{slug_or_id}/details", check(threadH.Update))
+	r.POST("/api/thread/{slug_or_id}/state", check(threadH.State))
+	r.DELETE("/api/thread/{slug_or_id}", check(threadH.Delete))
+	// user
+	r.POST("/api/user/{nickname}/create", check(userH.Create))
+	r.GET("/api/user/{nickname}/profile", check(userH.GetByNickname))
+	r.POST("/api/user/{nickname}/profile", check(userH.Update))
+	r.PUT("/api/user/{nickname}/role", check(userH.SetRole))
+	r.POST("/api/user/{nickname}/password", check(userH.SetPassword))
+	// metrics
+	if conf.Metrics.Path != "" {
+		r.GET(conf.Metrics.Path, m.Handler())
+	}
+
+	handler := r.Handler
+	if conf.Auth.Enabled {
+		handler = httphandlers.WithAuthentication(uc.auth.Authenticate, handler)
+	}
+	handler = httphandlers.WithTimeout(conf.API.RequestTimeout, handler)
+	handler = httphandlers.WithTracing(tp, validator.Operation, handler)
+	handler = httphandlers.WithLogging(logger, conf.Log.Access, validator.Operation, handler)
+	return m.Instrument(validator.Operation, handler), nil
+}
+
+func newGRPCServer(uc usecases, conf cfg.Config, logger *slog.Logger) *grpc.Server {
+	grpcUC := grpcapi.Usecases{
+		Users:   uc.user,
+		Forums:  uc.forum,
+		Threads: uc.thread,
+		Posts:   uc.post,
+		Service: uc.service,
+	}
+	if conf.Auth.Enabled {
+		grpcUC.Auth = uc.auth
+	}
+	return grpcapi.NewServer(grpcUC, conf.API.RequestTimeout, logger, conf.Log.Access)
+}
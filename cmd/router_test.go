@@ -0,0 +1,1335 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"park_db_course/cfg"
+	httphandlers "park_db_course/internal/api/http"
+	"park_db_course/internal/logging"
+	"park_db_course/internal/metrics"
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+
+	"github.com/fasthttp/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testAPI serves the real router over an in-memory listener, backed by the
+// in-memory repositories.
+type testAPI struct {
+	client *fasthttp.Client
+	dial   func() (net.Conn, error)
+	// spans are the spans of the requests served.
+	spans *tracetest.SpanRecorder
+	// logs are the JSON log lines of the requests served.
+	logs *syncBuffer
+}
+
+// syncBuffer is a buffer the handlers write to while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines decodes the lines logged so far.
+func (b *syncBuffer) lines(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(b.buf.Bytes()), []byte("\n")) {
+		var l map[string]interface{}
+		if err := json.Unmarshal(line, &l); err != nil {
+			t.Fatalf("log line %s: %v", line, err)
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	return serveTestAPI(t, newMemoryRepositories(nil), httphandlers.SocketAuth{})
+}
+
+// openModeration lets the tests without auth moderate.
+func openModeration(c *cfg.Config) {
+	c.Auth.OpenModeration = true
+}
+
+// serveTestAPI serves repos with the default config, changed by configure.
+func serveTestAPI(t *testing.T, repos repositories, auth httphandlers.SocketAuth, configure ...func(*cfg.Config)) *testAPI {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	return serveTestAPIOn(t, ln, ln.Dial, repos, auth, configure...)
+}
+
+// serveTestAPIOn is serveTestAPI on ln, which dial connects to.
+func serveTestAPIOn(t *testing.T, ln net.Listener, dial func() (net.Conn, error), repos repositories, auth httphandlers.SocketAuth, configure ...func(*cfg.Config)) *testAPI {
+	t.Helper()
+
+	conf := cfg.Default()
+	conf.API.RequestTimeout = time.Second
+	conf.Log.Format = cfg.LogJSON
+	conf.Auth.PasswordCost = bcrypt.MinCost
+	for _, c := range configure {
+		c(&conf)
+	}
+	m := metrics.New()
+	spans := tracetest.NewSpanRecorder()
+	logs := &syncBuffer{}
+	uc := newUsecases(instrumentRepositories(repos, m), conf)
+	handler, err := newRouter(uc, conf, auth, m, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)), logging.New(logs, conf.Log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	go uc.feed.Run(ctx)
+
+	srv := &fasthttp.Server{Handler: handler}
+	go srv.Serve(ln)
+	t.Cleanup(func() {
+		stop()
+		srv.Shutdown()
+	})
+
+	return &testAPI{
+		client: &fasthttp.Client{
+			Dial: func(string) (net.Conn, error) { return dial() },
+		},
+		dial:  dial,
+		spans: spans,
+		logs:  logs,
+	}
+}
+
+func (a *testAPI) do(t *testing.T, method, path, body string) (int, []byte) {
+	t.Helper()
+	return a.doAs(t, "", method, path, body)
+}
+
+// doAs sends the request with token as its bearer token, if any.
+func (a *testAPI) doAs(t *testing.T, token, method, path, body string) (int, []byte) {
+	t.Helper()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(method)
+	req.SetRequestURI("http://forum" + path)
+	if token != "" {
+		req.Header.Set(fasthttp.HeaderAuthorization, "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.SetContentType("application/json")
+		req.SetBodyString(body)
+	}
+	if err := a.client.Do(req, resp); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return resp.StatusCode(), append([]byte(nil), resp.Body()...)
+}
+
+// sseEvent is one server-sent event, without comments.
+type sseEvent struct {
+	id, event, data string
+}
+
+// stream opens an event stream and returns a function that reads the next
+// event, a zero one once the server ended the stream.
+func (a *testAPI) stream(t *testing.T, path, lastEventID string) func() sseEvent {
+	t.Helper()
+
+	conn, err := a.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest("GET", "http://forum"+path, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	if err = req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET %s: got status %d, content type %s", path, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	body := bufio.NewReader(resp.Body)
+	return func() sseEvent {
+		t.Helper()
+		var ev sseEvent
+		for {
+			line, err := body.ReadString('\n')
+			if errors.Is(err, io.EOF) && line == "" && ev.event == "" {
+				return ev
+			}
+			if err != nil {
+				t.Fatalf("GET %s: %v", path, err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" && ev.event != "" {
+				return ev
+			}
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				ev.id = value
+			case "event":
+				ev.event = value
+			case "data":
+				ev.data = value
+			}
+		}
+	}
+}
+
+// socket opens a forum activity socket. When the upgrade is refused it
+// returns nil and the status.
+func (a *testAPI) socket(t *testing.T, header http.Header) (*websocket.Conn, int) {
+	t.Helper()
+
+	dialer := websocket.Dialer{
+		NetDial:          func(string, string) (net.Conn, error) { return a.dial() },
+		HandshakeTimeout: 5 * time.Second,
+	}
+	conn, resp, err := dialer.Dial("ws://forum/api/forum/activity", header)
+	if err != nil {
+		if resp == nil {
+			t.Fatal(err)
+		}
+		return nil, resp.StatusCode
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, resp.StatusCode
+}
+
+// exchange sends msg on the socket, unless it is empty, and returns the
+// next message received.
+func exchange(t *testing.T, conn *websocket.Conn, msg string) map[string]interface{} {
+	t.Helper()
+	if msg != "" {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var got map[string]interface{}
+	if err := conn.ReadJSON(&got); err != nil {
+		t.Fatalf("after %s: %v", msg, err)
+	}
+	return got
+}
+
+// apiCase is one request and what to expect back. Cases of a table share
+// the API, so later cases see the effects of earlier ones.
+type apiCase struct {
+	name   string
+	method string
+	path   string
+	body   string
+	// token is sent as the bearer token.
+	token string
+
+	status int
+	// contains lists substrings the response body must have.
+	contains []string
+	// field and values check a field of every element of a JSON array
+	// response, in order.
+	field  string
+	values []interface{}
+}
+
+func runCases(t *testing.T, api *testAPI, cases []apiCase) {
+	t.Helper()
+	for _, c := range cases {
+		status, body := api.doAs(t, c.token, c.method, c.path, c.body)
+		if *contract {
+			for _, err := range contractErrors(apiSpec(t), c.method, c.path, c.body, status, body) {
+				t.Errorf("%s: contract: %v", c.name, err)
+			}
+		}
+		if status != c.status {
+			t.Errorf("%s: %s %s: got status %d, want %d, body %s", c.name, c.method, c.path, status, c.status, body)
+			continue
+		}
+		for _, sub := range c.contains {
+			if !strings.Contains(string(body), sub) {
+				t.Errorf("%s: body %s does not contain %s", c.name, body, sub)
+			}
+		}
+		if c.field != "" {
+			var items []map[string]interface{}
+			if err := json.Unmarshal(body, &items); err != nil {
+				t.Errorf("%s: body %s is not an array: %v", c.name, body, err)
+				continue
+			}
+			got := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				got = append(got, item[c.field])
+			}
+			if !reflect.DeepEqual(got, c.values) {
+				t.Errorf("%s: got %s %v, want %v", c.name, c.field, got, c.values)
+			}
+		}
+	}
+}
+
+// seed creates users alice and bob, forum "pirates" owned by alice and
+// thread "jolly" (id 1) started by bob with a small post tree:
+//
+//	1 root-a
+//	  3 child-a
+//	2 root-b
+//	  4 child-b
+func seed(t *testing.T, api *testAPI) {
+	t.Helper()
+	runCases(t, api, []apiCase{
+		{name: "seed alice", method: "POST", path: "/api/user/alice/create", body: `{"fullname":"Alice","about":"a","email":"alice@mail.ru"}`, status: http.StatusCreated},
+		{name: "seed bob", method: "POST", path: "/api/user/bob/create", body: `{"fullname":"Bob","about":"b","email":"bob@mail.ru"}`, status: http.StatusCreated},
+		{name: "seed forum", method: "POST", path: "/api/forum/create", body: `{"title":"Pirates","user":"alice","slug":"pirates"}`, status: http.StatusCreated},
+		{name: "seed thread", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"Jolly","author":"bob","message":"yo ho","slug":"jolly","created":"2020-01-01T00:00:00Z"}`, status: http.StatusCreated},
+		{name: "seed roots", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"alice","message":"root-a"},{"author":"bob","message":"root-b"}]`, status: http.StatusCreated},
+		{name: "seed children", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"bob","message":"child-a","parent":1},{"author":"alice","message":"child-b","parent":2}]`, status: http.StatusCreated},
+	})
+}
+
+func TestUserHandlers(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "create", method: "POST", path: "/api/user/carol/create", body: `{"fullname":"Carol","about":"c","email":"carol@mail.ru"}`,
+			status: http.StatusCreated, contains: []string{`"nickname":"carol"`, `"email":"carol@mail.ru"`}},
+		{name: "create bad json", method: "POST", path: "/api/user/dave/create", body: `{`,
+			status: http.StatusBadRequest},
+		{name: "create without email", method: "POST", path: "/api/user/dave/create", body: `{"fullname":"Dave"}`,
+			status: http.StatusBadRequest, contains: []string{`"field":"profile.email"`}},
+		{name: "create without body", method: "POST", path: "/api/user/dave/create",
+			status: http.StatusBadRequest, contains: []string{`"field":"profile"`}},
+		{name: "create nickname taken, other case", method: "POST", path: "/api/user/ALICE/create", body: `{"fullname":"A","about":"","email":"new@mail.ru"}`,
+			status: http.StatusConflict, field: "nickname", values: []interface{}{"alice"}},
+		{name: "create nickname and email of two users", method: "POST", path: "/api/user/alice/create", body: `{"fullname":"A","about":"","email":"BOB@mail.ru"}`,
+			status: http.StatusConflict, field: "nickname", values: []interface{}{"alice", "bob"}},
+		{name: "profile", method: "GET", path: "/api/user/Alice/profile",
+			status: http.StatusOK, contains: []string{`"nickname":"alice"`, `"fullname":"Alice"`}},
+		{name: "profile not found", method: "GET", path: "/api/user/nobody/profile",
+			status: http.StatusNotFound, contains: []string{`"message"`}},
+		{name: "update", method: "POST", path: "/api/user/alice/profile", body: `{"fullname":"Alice Liddell"}`,
+			status: http.StatusOK, contains: []string{`"fullname":"Alice Liddell"`, `"email":"alice@mail.ru"`}},
+		{name: "update not found", method: "POST", path: "/api/user/nobody/profile", body: `{"fullname":"X"}`,
+			status: http.StatusNotFound},
+		{name: "update bad json", method: "POST", path: "/api/user/alice/profile", body: `{`,
+			status: http.StatusBadRequest},
+		{name: "update email taken", method: "POST", path: "/api/user/alice/profile", body: `{"email":"bob@mail.ru"}`,
+			status: http.StatusConflict, contains: []string{`bob`}},
+	})
+}
+
+func TestForumHandlers(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "create", method: "POST", path: "/api/forum/create", body: `{"title":"Sailors","user":"BOB","slug":"sailors"}`,
+			status: http.StatusCreated, contains: []string{`"user":"bob"`, `"slug":"sailors"`}},
+		{name: "create bad json", method: "POST", path: "/api/forum/create", body: `{`,
+			status: http.StatusBadRequest},
+		{name: "create slug taken", method: "POST", path: "/api/forum/create", body: `{"title":"Other","user":"bob","slug":"PIRATES"}`,
+			status: http.StatusConflict, contains: []string{`"slug":"pirates"`, `"user":"alice"`}},
+		{name: "create unknown user", method: "POST", path: "/api/forum/create", body: `{"title":"X","user":"nobody","slug":"x"}`,
+			status: http.StatusNotFound, contains: []string{`Can't find user by nickname: nobody`}},
+		{name: "details", method: "GET", path: "/api/forum/Pirates/details",
+			status: http.StatusOK, contains: []string{`"posts":4`, `"threads":1`}},
+		{name: "details not found", method: "GET", path: "/api/forum/nope/details",
+			status: http.StatusNotFound, contains: []string{`Can't find forum by slug: nope`}},
+		{name: "create thread", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"Second","author":"alice","message":"m","slug":"second","created":"2021-01-01T00:00:00Z"}`,
+			status: http.StatusCreated, contains: []string{`"forum":"pirates"`, `"slug":"second"`}},
+		{name: "create thread without slug", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"Third","author":"bob","message":"m","created":"2022-01-01T00:00:00Z"}`,
+			status: http.StatusCreated, contains: []string{`"title":"Third"`}},
+		{name: "create thread unknown forum", method: "POST", path: "/api/forum/nope/create", body: `{"title":"T","author":"alice","message":"m"}`,
+			status: http.StatusNotFound},
+		{name: "create thread bad json", method: "POST", path: "/api/forum/pirates/create", body: `{`,
+			status: http.StatusBadRequest},
+		{name: "create thread slug taken", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"T","author":"alice","message":"m","slug":"JOLLY"}`,
+			status: http.StatusConflict, contains: []string{`"slug":"jolly"`}},
+		{name: "create thread unknown author", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"T","author":"nobody","message":"m"}`,
+			status: http.StatusNotFound},
+		{name: "threads", method: "GET", path: "/api/forum/pirates/threads",
+			status: http.StatusOK, field: "title", values: []interface{}{"Jolly", "Second", "Third"}},
+		{name: "threads desc limit", method: "GET", path: "/api/forum/pirates/threads?desc=true&limit=2",
+			status: http.StatusOK, field: "title", values: []interface{}{"Third", "Second"}},
+		{name: "threads since", method: "GET", path: "/api/forum/pirates/threads?since=2021-01-01T00:00:00Z",
+			status: http.StatusOK, field: "title", values: []interface{}{"Second", "Third"}},
+		{name: "threads bad limit", method: "GET", path: "/api/forum/pirates/threads?limit=x",
+			status: http.StatusBadRequest, contains: []string{`"field":"limit"`}},
+		{name: "threads limit out of range", method: "GET", path: "/api/forum/pirates/threads?limit=0",
+			status: http.StatusBadRequest, contains: []string{`"field":"limit"`}},
+		{name: "threads bad since", method: "GET", path: "/api/forum/pirates/threads?since=yesterday",
+			status: http.StatusBadRequest, contains: []string{`"field":"since"`}},
+		{name: "threads bad desc", method: "GET", path: "/api/forum/pirates/threads?desc=maybe",
+			status: http.StatusBadRequest, contains: []string{`"field":"desc"`}},
+		{name: "threads not found", method: "GET", path: "/api/forum/nope/threads",
+			status: http.StatusNotFound},
+		{name: "users", method: "GET", path: "/api/forum/pirates/users",
+			status: http.StatusOK, field: "nickname", values: []interface{}{"alice", "bob"}},
+		{name: "users desc since", method: "GET", path: "/api/forum/pirates/users?desc=true&since=bob",
+			status: http.StatusOK, field: "nickname", values: []interface{}{"alice"}},
+		{name: "users bad limit", method: "GET", path: "/api/forum/pirates/users?limit=x",
+			status: http.StatusBadRequest},
+		{name: "users not found", method: "GET", path: "/api/forum/nope/users",
+			status: http.StatusNotFound},
+	})
+}
+
+func TestThreadHandlers(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "details by slug", method: "GET", path: "/api/thread/JOLLY/details",
+			status: http.StatusOK, contains: []string{`"id":1`, `"slug":"jolly"`}},
+		{name: "details by id", method: "GET", path: "/api/thread/1/details",
+			status: http.StatusOK, contains: []string{`"slug":"jolly"`}},
+		{name: "details not found", method: "GET", path: "/api/thread/nope/details",
+			status: http.StatusNotFound},
+
+		{name: "create posts", method: "POST", path: "/api/thread/1/create", body: `[{"author":"alice","message":"grandchild","parent":3}]`,
+			status: http.StatusCreated, field: "id", values: []interface{}{5.0}},
+		{name: "create no posts", method: "POST", path: "/api/thread/jolly/create", body: `[]`,
+			status: http.StatusCreated},
+		{name: "create posts thread not found", method: "POST", path: "/api/thread/nope/create", body: `[{"author":"alice","message":"m"}]`,
+			status: http.StatusNotFound},
+		{name: "create posts bad json", method: "POST", path: "/api/thread/jolly/create", body: `{`,
+			status: http.StatusBadRequest},
+		{name: "create posts unknown author", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"alice","message":"m"},{"author":"nobody","message":"m"}]`,
+			status: http.StatusNotFound, contains: []string{`posts[1]`}},
+		{name: "create posts unknown parent", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"alice","message":"m","parent":100}]`,
+			status: http.StatusConflict, contains: []string{`posts[0]`}},
+		{name: "create posts without author", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"alice","message":"m"},{"message":"m"}]`,
+			status: http.StatusBadRequest, contains: []string{`"field":"posts.1.author"`}},
+		{name: "create posts not a list", method: "POST", path: "/api/thread/jolly/create", body: `{"author":"alice","message":"m"}`,
+			status: http.StatusBadRequest, contains: []string{`"field":"posts"`}},
+		{name: "rejected batches store nothing", method: "GET", path: "/api/service/status",
+			status: http.StatusOK, contains: []string{`"post":5`}},
+
+		{name: "posts flat", method: "GET", path: "/api/thread/jolly/posts?sort=flat",
+			status: http.StatusOK, field: "message", values: []interface{}{"root-a", "root-b", "child-a", "child-b", "grandchild"}},
+		{name: "posts default sort is flat", method: "GET", path: "/api/thread/jolly/posts?limit=2&desc=true",
+			status: http.StatusOK, field: "message", values: []interface{}{"grandchild", "child-b"}},
+		{name: "posts flat since", method: "GET", path: "/api/thread/jolly/posts?sort=flat&since=3",
+			status: http.StatusOK, field: "message", values: []interface{}{"child-b", "grandchild"}},
+		{name: "posts tree", method: "GET", path: "/api/thread/jolly/posts?sort=tree",
+			status: http.StatusOK, field: "message", values: []interface{}{"root-a", "child-a", "grandchild", "root-b", "child-b"}},
+		{name: "posts tree desc since", method: "GET", path: "/api/thread/jolly/posts?sort=tree&desc=true&since=2",
+			status: http.StatusOK, field: "message", values: []interface{}{"grandchild", "child-a", "root-a"}},
+		{name: "posts parent tree limits roots", method: "GET", path: "/api/thread/jolly/posts?sort=parent_tree&limit=1",
+			status: http.StatusOK, field: "message", values: []interface{}{"root-a", "child-a", "grandchild"}},
+		{name: "posts parent tree desc", method: "GET", path: "/api/thread/jolly/posts?sort=parent_tree&desc=true",
+			status: http.StatusOK, field: "message", values: []interface{}{"root-b", "child-b", "root-a", "child-a", "grandchild"}},
+		{name: "posts bad limit", method: "GET", path: "/api/thread/jolly/posts?limit=x",
+			status: http.StatusBadRequest},
+		{name: "posts bad sort", method: "GET", path: "/api/thread/jolly/posts?sort=random",
+			status: http.StatusBadRequest, contains: []string{`"field":"sort"`}},
+		{name: "posts bad since", method: "GET", path: "/api/thread/jolly/posts?since=first",
+			status: http.StatusBadRequest, contains: []string{`"field":"since"`}},
+		{name: "posts thread not found", method: "GET", path: "/api/thread/nope/posts",
+			status: http.StatusNotFound},
+
+		{name: "vote", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"alice","voice":1}`,
+			status: http.StatusOK, contains: []string{`"votes":1`}},
+		{name: "vote again, same voice", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"ALICE","voice":1}`,
+			status: http.StatusOK, contains: []string{`"votes":1`}},
+		{name: "vote flip", method: "POST", path: "/api/thread/1/vote", body: `{"nickname":"alice","voice":-1}`,
+			status: http.StatusOK, contains: []string{`"votes":-1`}},
+		{name: "vote second user", method: "POST", path: "/api/thread/1/vote", body: `{"nickname":"bob","voice":-1}`,
+			status: http.StatusOK, contains: []string{`"votes":-2`}},
+		{name: "vote thread not found", method: "POST", path: "/api/thread/nope/vote", body: `{"nickname":"alice","voice":1}`,
+			status: http.StatusNotFound},
+		{name: "vote bad json", method: "POST", path: "/api/thread/jolly/vote", body: `{`,
+			status: http.StatusBadRequest},
+		{name: "vote unknown user", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"nobody","voice":1}`,
+			status: http.StatusNotFound},
+		{name: "vote voice out of enum", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"alice","voice":5}`,
+			status: http.StatusBadRequest, contains: []string{`"field":"vote.voice"`}},
+
+		{name: "update", method: "POST", path: "/api/thread/jolly/details", body: `{"title":"Jolly Roger"}`,
+			status: http.StatusOK, contains: []string{`"title":"Jolly Roger"`, `"message":"yo ho"`}},
+		{name: "update nothing", method: "POST", path: "/api/thread/jolly/details", body: `{}`,
+			status: http.StatusOK, contains: []string{`"title":"Jolly Roger"`}},
+		{name: "update not found", method: "POST", path: "/api/thread/nope/details", body: `{"title":"T"}`,
+			status: http.StatusNotFound},
+		{name: "update bad json", method: "POST", path: "/api/thread/jolly/details", body: `{`,
+			status: http.StatusBadRequest},
+	})
+}
+
+func TestPostHandlers(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "details", method: "GET", path: "/api/post/3/details",
+			status: http.StatusOK, contains: []string{`"post":{"id":3,"parent":1`, `"message":"child-a"`}},
+		{name: "details related", method: "GET", path: "/api/post/3/details?related=user,forum,thread",
+			status: http.StatusOK, contains: []string{`"author":{"nickname":"bob"`, `"forum":{"title":"Pirates"`, `"thread":{"id":1`}},
+		{name: "details not found", method: "GET", path: "/api/post/100/details",
+			status: http.StatusNotFound, contains: []string{`100`}},
+		{name: "details bad id", method: "GET", path: "/api/post/x/details",
+			status: http.StatusBadRequest, contains: []string{`"field":"id"`}},
+		{name: "details unknown related", method: "GET", path: "/api/post/3/details?related=user,author",
+			status: http.StatusBadRequest, contains: []string{`"field":"related`}},
+		{name: "update", method: "POST", path: "/api/post/3/details", body: `{"message":"edited"}`,
+			status: http.StatusOK, contains: []string{`"message":"edited"`, `"isEdited":true`}},
+		{name: "update same message is not an edit", method: "POST", path: "/api/post/4/details", body: `{"message":"child-b"}`,
+			status: http.StatusOK, contains: []string{`"isEdited":false`}},
+		{name: "update empty message", method: "POST", path: "/api/post/4/details", body: `{}`,
+			status: http.StatusOK, contains: []string{`"message":"child-b"`, `"isEdited":false`}},
+		{name: "update not found", method: "POST", path: "/api/post/100/details", body: `{"message":"m"}`,
+			status: http.StatusNotFound},
+		{name: "update bad json", method: "POST", path: "/api/post/3/details", body: `{`,
+			status: http.StatusBadRequest},
+	})
+}
+
+func TestPostDeletion(t *testing.T) {
+	api := serveTestAPI(t, newMemoryRepositories(nil), httphandlers.SocketAuth{}, openModeration)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "grandchild", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"alice","message":"grandchild","parent":3}]`,
+			status: http.StatusCreated},
+		{name: "delete", method: "DELETE", path: "/api/post/3",
+			status: http.StatusOK, contains: []string{`"id":3`, `"author":"bob"`, `"message":""`, `"isDeleted":true`}},
+		{name: "counted off the forum", method: "GET", path: "/api/forum/pirates/details",
+			status: http.StatusOK, contains: []string{`"posts":4`}},
+		{name: "placeholder keeps its place", method: "GET", path: "/api/thread/jolly/posts?sort=tree",
+			status: http.StatusOK, field: "message", values: []interface{}{"root-a", "", "grandchild", "root-b", "child-b"}},
+		{name: "parent tree", method: "GET", path: "/api/thread/jolly/posts?sort=parent_tree&limit=1",
+			status: http.StatusOK, field: "id", values: []interface{}{1.0, 3.0, 5.0}},
+		{name: "delete again", method: "DELETE", path: "/api/post/3",
+			status: http.StatusOK, contains: []string{`"isDeleted":true`}},
+		{name: "counted once", method: "GET", path: "/api/forum/pirates/details",
+			status: http.StatusOK, contains: []string{`"posts":4`}},
+		{name: "edit the placeholder", method: "POST", path: "/api/post/3/details", body: `{"message":"back"}`,
+			status: http.StatusConflict},
+		{name: "delete hiding the author", method: "DELETE", path: "/api/post/4?hideAuthor=true",
+			status: http.StatusOK, contains: []string{`"author":""`, `"isDeleted":true`}},
+		{name: "hidden author is not related", method: "GET", path: "/api/post/4/details?related=user,thread",
+			status: http.StatusOK, contains: []string{`"author":""`, `"thread":{"id":1`}},
+		{name: "delete subtree", method: "DELETE", path: "/api/post/1?subtree=true",
+			status: http.StatusNoContent},
+		{name: "subtree is gone", method: "GET", path: "/api/thread/jolly/posts?sort=flat",
+			status: http.StatusOK, field: "id", values: []interface{}{2.0, 4.0}},
+		{name: "reply is gone", method: "GET", path: "/api/post/5/details",
+			status: http.StatusNotFound},
+		{name: "counters reconciled", method: "GET", path: "/api/forum/pirates/details",
+			status: http.StatusOK, contains: []string{`"posts":1`}},
+		{name: "delete subtree not found", method: "DELETE", path: "/api/post/100?subtree=true",
+			status: http.StatusNotFound},
+		{name: "delete not found", method: "DELETE", path: "/api/post/100",
+			status: http.StatusNotFound},
+		{name: "delete bad id", method: "DELETE", path: "/api/post/x",
+			status: http.StatusBadRequest},
+	})
+}
+
+func TestClosedModeration(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "delete subtree", method: "DELETE", path: "/api/post/1?subtree=true",
+			status: http.StatusForbidden, contains: []string{`auth.open_moderation`}},
+		{name: "close", method: "POST", path: "/api/thread/jolly/state", body: `{"closed":true}`,
+			status: http.StatusForbidden},
+		{name: "delete thread", method: "DELETE", path: "/api/thread/jolly",
+			status: http.StatusForbidden},
+		{name: "thread stays", method: "GET", path: "/api/thread/jolly/details",
+			status: http.StatusOK, contains: []string{`"slug":"jolly"`}},
+		{name: "plain delete stays open", method: "DELETE", path: "/api/post/1",
+			status: http.StatusOK},
+	})
+}
+
+func TestThreadModeration(t *testing.T) {
+	api := serveTestAPI(t, newMemoryRepositories(nil), httphandlers.SocketAuth{}, openModeration)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "older thread", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"Old","author":"alice","message":"m","slug":"old","created":"2019-01-01T00:00:00Z"}`,
+			status: http.StatusCreated},
+		{name: "newer thread", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"New","author":"alice","message":"m","slug":"new","created":"2021-01-01T00:00:00Z"}`,
+			status: http.StatusCreated},
+		{name: "pin", method: "POST", path: "/api/thread/jolly/state", body: `{"pinned":true}`,
+			status: http.StatusOK, contains: []string{`"slug":"jolly"`, `"isPinned":true`}},
+		{name: "pinned first", method: "GET", path: "/api/forum/pirates/threads",
+			status: http.StatusOK, field: "slug", values: []interface{}{"jolly", "old", "new"}},
+		{name: "pinned first desc", method: "GET", path: "/api/forum/pirates/threads?desc=true",
+			status: http.StatusOK, field: "slug", values: []interface{}{"jolly", "new", "old"}},
+		{name: "pinned not paged", method: "GET", path: "/api/forum/pirates/threads?since=2019-01-01T00:00:00Z",
+			status: http.StatusOK, field: "slug", values: []interface{}{"old", "new"}},
+
+		{name: "close", method: "POST", path: "/api/thread/jolly/state", body: `{"closed":true}`,
+			status: http.StatusOK, contains: []string{`"isClosed":true`, `"isPinned":true`}},
+		{name: "state unchanged", method: "POST", path: "/api/thread/jolly/state", body: `{}`,
+			status: http.StatusOK, contains: []string{`"isClosed":true`}},
+		{name: "post to closed", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"alice","message":"late"}]`,
+			status: http.StatusConflict, contains: []string{`closed`}},
+		{name: "vote in closed", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"alice","voice":1}`,
+			status: http.StatusOK, contains: []string{`"votes":1`}},
+		{name: "reopen", method: "POST", path: "/api/thread/1/state", body: `{"closed":false,"pinned":false}`,
+			status: http.StatusOK},
+		{name: "post to reopened", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"alice","message":"late"}]`,
+			status: http.StatusCreated},
+		{name: "state not found", method: "POST", path: "/api/thread/nope/state", body: `{"closed":true}`,
+			status: http.StatusNotFound},
+
+		{name: "counted", method: "GET", path: "/api/forum/pirates/details",
+			status: http.StatusOK, contains: []string{`"threads":3`, `"posts":5`}},
+		{name: "delete", method: "DELETE", path: "/api/thread/jolly",
+			status: http.StatusNoContent},
+		{name: "thread is gone", method: "GET", path: "/api/thread/jolly/details",
+			status: http.StatusNotFound},
+		{name: "posts are gone", method: "GET", path: "/api/post/1/details",
+			status: http.StatusNotFound},
+		{name: "counters reconciled", method: "GET", path: "/api/forum/pirates/details",
+			status: http.StatusOK, contains: []string{`"threads":2`, `"posts":0`}},
+		{name: "listed no more", method: "GET", path: "/api/forum/pirates/threads",
+			status: http.StatusOK, field: "slug", values: []interface{}{"old", "new"}},
+		{name: "delete again", method: "DELETE", path: "/api/thread/jolly",
+			status: http.StatusNotFound},
+	})
+}
+
+func TestClosedVotes(t *testing.T) {
+	api := serveTestAPI(t, newMemoryRepositories(nil), httphandlers.SocketAuth{}, func(c *cfg.Config) {
+		c.Threads.ClosedVotes = false
+		c.Auth.OpenModeration = true
+	})
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "close", method: "POST", path: "/api/thread/jolly/state", body: `{"closed":true}`,
+			status: http.StatusOK},
+		{name: "vote in closed", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"alice","voice":1}`,
+			status: http.StatusConflict},
+		{name: "no vote counted", method: "GET", path: "/api/thread/jolly/details",
+			status: http.StatusOK, contains: []string{`"votes":0`}},
+	})
+}
+
+func TestServiceHandlers(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "status", method: "GET", path: "/api/service/status",
+			status: http.StatusOK, contains: []string{`"user":2`, `"forum":1`, `"thread":1`, `"post":4`}},
+		{name: "pool", method: "GET", path: "/api/service/pool",
+			status: http.StatusOK, contains: []string{`"max_conns":0`}},
+		{name: "clear", method: "POST", path: "/api/service/clear",
+			status: http.StatusOK},
+		{name: "status after clear", method: "GET", path: "/api/service/status",
+			status: http.StatusOK, contains: []string{`"user":0`, `"forum":0`, `"thread":0`, `"post":0`}},
+		{name: "nickname is free after clear", method: "POST", path: "/api/user/alice/create", body: `{"fullname":"Alice","about":"a","email":"alice@mail.ru"}`,
+			status: http.StatusCreated},
+	})
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "thread with posts and authors", method: "POST", path: "/api/graphql",
+			body:     `{"query":"query($t: String!) { thread(slugOrId: $t) { title posts(limit: 2, sort: TREE) { message author { fullname } } } }","variables":{"t":"jolly"}}`,
+			status:   http.StatusOK,
+			contains: []string{`{"data":{"thread":{"title":"Jolly","posts":[{"message":"root-a","author":{"fullname":"Alice"}},{"message":"child-a","author":{"fullname":"Bob"}}]}}}`}},
+		{name: "unknown field", method: "POST", path: "/api/graphql", body: `{"query":"{ thread(slugOrId: \"jolly\") { rating } }"}`,
+			status: http.StatusOK, contains: []string{`Cannot query field \"rating\" on type \"Thread\"`, `"GRAPHQL_VALIDATION_FAILED"`}},
+		{name: "vote mutation", method: "POST", path: "/api/graphql", body: `{"query":"mutation { vote(thread: \"jolly\", vote: {nickname: \"alice\", voice: 1}) { votes } }"}`,
+			status: http.StatusOK, contains: []string{`{"data":{"vote":{"votes":1}}}`}},
+		{name: "no query", method: "POST", path: "/api/graphql", body: `{"variables":{}}`,
+			status: http.StatusBadRequest, contains: []string{`"field":"request.query"`}},
+	})
+}
+
+func TestThreadStream(t *testing.T) {
+	api := serveTestAPI(t, newMemoryRepositories(nil), httphandlers.SocketAuth{}, openModeration)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "unknown thread", method: "GET", path: "/api/thread/nope/stream",
+			status: http.StatusNotFound},
+	})
+
+	// Posts 1 to 4 exist, the stream resumes after post 2.
+	next := api.stream(t, "/api/thread/jolly/stream", "2")
+	expect := func(step, event, id string, contains ...string) {
+		t.Helper()
+		ev := next()
+		if ev.event != event || ev.id != id {
+			t.Errorf("%s: got event %q id %q, want %q id %q", step, ev.event, ev.id, event, id)
+		}
+		for _, sub := range contains {
+			if !strings.Contains(ev.data, sub) {
+				t.Errorf("%s: data %s does not contain %s", step, ev.data, sub)
+			}
+		}
+	}
+	expect("snapshot", "thread", "", `"title":"Jolly"`, `"votes":0`)
+	expect("replay", "post", "3", `"message":"child-a"`)
+	expect("replay", "post", "4", `"message":"child-b"`)
+
+	// a batch is announced once and delivered post by post
+	runCases(t, api, []apiCase{{name: "add batch", method: "POST", path: "/api/thread/jolly/create",
+		body: `[{"author":"alice","message":"batch-a"},{"author":"bob","message":"batch-b"}]`, status: http.StatusCreated}})
+	expect("batch", "post", "5", `"message":"batch-a"`)
+	expect("batch", "post", "6", `"message":"batch-b"`)
+
+	// Rows are read when a change is delivered, so each one is awaited
+	// before the next is made.
+	steps := []struct {
+		apiCase
+		event, id string
+		contains  []string
+	}{
+		{apiCase{name: "add post", method: "POST", path: "/api/thread/1/create", body: `[{"author":"alice","message":"live"}]`, status: http.StatusCreated},
+			"post", "7", []string{`"message":"live"`}},
+		{apiCase{name: "edit post", method: "POST", path: "/api/post/7/details", body: `{"message":"edited"}`, status: http.StatusOK},
+			"post_edit", "", []string{`"message":"edited"`, `"isEdited":true`}},
+		{apiCase{name: "vote", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"alice","voice":1}`, status: http.StatusOK},
+			"votes", "", []string{`"votes":1`}},
+		{apiCase{name: "update thread", method: "POST", path: "/api/thread/jolly/details", body: `{"title":"Jolly Roger"}`, status: http.StatusOK},
+			"thread", "", []string{`"title":"Jolly Roger"`}},
+		{apiCase{name: "close thread", method: "POST", path: "/api/thread/jolly/state", body: `{"closed":true}`, status: http.StatusOK},
+			"thread", "", []string{`"isClosed":true`}},
+		{apiCase{name: "delete thread", method: "DELETE", path: "/api/thread/jolly", status: http.StatusNoContent},
+			"thread_delete", "", []string{`"id":1`, `"forum":"pirates"`}},
+	}
+	for _, step := range steps {
+		runCases(t, api, []apiCase{step.apiCase})
+		expect(step.name, step.event, step.id, step.contains...)
+	}
+	expect("ended by the deletion", "", "")
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI("http://forum/api/thread/jolly/stream")
+	req.Header.Set("Last-Event-ID", "-1")
+	if err := api.client.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != http.StatusBadRequest || !strings.Contains(string(resp.Body()), `"field":"Last-Event-ID"`) {
+		t.Errorf("bad Last-Event-ID: got %d %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+	runCases(t, api, []apiCase{
+		{name: "vote", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"alice","voice":1}`, status: http.StatusOK},
+		{name: "unknown thread", method: "GET", path: "/api/thread/nope/details", status: http.StatusNotFound},
+	})
+	if status, _ := api.do(t, "GET", "/nope", ""); status != http.StatusNotFound {
+		t.Fatalf("unknown route: got status %d", status)
+	}
+
+	status, body := api.do(t, "GET", "/metrics", "")
+	if status != http.StatusOK {
+		t.Fatalf("GET /metrics: got status %d", status)
+	}
+	for _, want := range []string{
+		`forum_http_requests_total{code="201",method="POST",route="postsCreate"} 2`,
+		`forum_http_requests_total{code="404",method="GET",route="threadGetOne"} 1`,
+		`forum_http_requests_total{code="404",method="GET",route="unmatched"} 1`,
+		`forum_http_request_duration_seconds_count{route="threadVote"} 1`,
+		`forum_repository_call_duration_seconds_count{method="CreatePosts",repository="thread"} 2`,
+		// creating jolly checked that its slug was free
+		`forum_repository_errors_total{kind="not_found",method="GetBySlugOrId",repository="thread"} 2`,
+		`forum_posts_created_total 4`,
+		`forum_threads_created_total 1`,
+		`forum_votes_cast_total 1`,
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI("http://forum/api/thread/jolly/details")
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	if err := api.client.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	var span sdktrace.ReadOnlySpan
+	for _, s := range api.spans.Ended() {
+		if s.SpanContext().TraceID().String() == traceID {
+			span = s
+		}
+	}
+	if span == nil {
+		t.Fatal("no span continues the traceparent")
+	}
+	if span.Name() != "threadGetOne" || span.Parent().SpanID().String() != spanID {
+		t.Errorf("got span %s with parent %s, want threadGetOne with parent %s", span.Name(), span.Parent().SpanID(), spanID)
+	}
+	got := map[string]string{}
+	for _, a := range span.Attributes() {
+		got[string(a.Key)] = a.Value.Emit()
+	}
+	for key, want := range map[string]string{
+		"http.route":                "/api/thread/{slug_or_id}/details",
+		"http.request.method":       "GET",
+		"http.response.status_code": "200",
+		"slug_or_id":                "jolly",
+	} {
+		if got[key] != want {
+			t.Errorf("span attribute %s: got %q, want %q", key, got[key], want)
+		}
+	}
+}
+
+func TestLogging(t *testing.T) {
+	api := newTestAPI(t)
+	seed(t, api)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	get := func(requestID string) string {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI("http://forum/api/thread/jolly/details")
+		req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		if requestID != "" {
+			req.Header.Set(logging.RequestIDHeader, requestID)
+		}
+		if err := api.client.Do(req, resp); err != nil {
+			t.Fatal(err)
+		}
+		return string(resp.Header.Peek(logging.RequestIDHeader))
+	}
+
+	if got := get("probe-1"); got != "probe-1" {
+		t.Errorf("%s = %q, want the one sent", logging.RequestIDHeader, got)
+	}
+	generated := get("not an id")
+	if len(generated) != 32 {
+		t.Errorf("%s = %q, want a generated one", logging.RequestIDHeader, generated)
+	}
+
+	access := map[string]map[string]interface{}{}
+	for _, l := range api.logs.lines(t) {
+		if id, ok := l["request_id"].(string); ok && l["msg"] == "request" {
+			access[id] = l
+		}
+	}
+	for _, id := range []string{"probe-1", generated} {
+		l := access[id]
+		if l == nil {
+			t.Errorf("no access line with request_id %q", id)
+			continue
+		}
+		for key, want := range map[string]interface{}{
+			"level":    "INFO",
+			"method":   "GET",
+			"path":     "/api/thread/jolly/details",
+			"route":    "threadGetOne",
+			"status":   float64(http.StatusOK),
+			"trace_id": traceID,
+		} {
+			if l[key] != want {
+				t.Errorf("access line of %s: %s = %v, want %v", id, key, l[key], want)
+			}
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	api := serveTestAPI(t, newMemoryRepositories(nil), httphandlers.SocketAuth{}, func(c *cfg.Config) {
+		c.Auth.Enabled = true
+		c.Auth.Secret = strings.Repeat("s", cfg.MinSecret)
+	})
+	runCases(t, api, []apiCase{
+		{name: "register alice", method: "POST", path: "/api/user/alice/create", body: `{"fullname":"Alice","about":"a","email":"alice@mail.ru","password":"alice-pw"}`,
+			status: http.StatusCreated},
+		{name: "register without password", method: "POST", path: "/api/user/bob/create", body: `{"fullname":"Bob","about":"b","email":"bob@mail.ru"}`,
+			status: http.StatusBadRequest, contains: []string{`without a password`}},
+		{name: "register bob", method: "POST", path: "/api/user/bob/create", body: `{"fullname":"Bob","about":"b","email":"bob@mail.ru","password":"bob-pw"}`,
+			status: http.StatusCreated},
+		{name: "wrong password", method: "POST", path: "/api/auth/login", body: `{"nickname":"alice","password":"bob-pw"}`,
+			status: http.StatusUnauthorized, contains: []string{`wrong nickname or password`}},
+		{name: "unknown user", method: "POST", path: "/api/auth/login", body: `{"nickname":"carol","password":"bob-pw"}`,
+			status: http.StatusUnauthorized, contains: []string{`wrong nickname or password`}},
+	})
+
+	alice, bob := login(t, api, "ALICE", "alice-pw"), login(t, api, "bob", "bob-pw")
+
+	runCases(t, api, []apiCase{
+		{name: "anonymous forum", method: "POST", path: "/api/forum/create", body: `{"title":"Pirates","user":"alice","slug":"pirates"}`,
+			status: http.StatusUnauthorized, contains: []string{`log in`}},
+		{name: "forged token", method: "POST", path: "/api/forum/create", body: `{"title":"Pirates","user":"alice","slug":"pirates"}`, token: alice + "x",
+			status: http.StatusUnauthorized, contains: []string{`invalid token`}},
+		{name: "forum as the caller", method: "POST", path: "/api/forum/create", body: `{"title":"Pirates","user":"bob","slug":"pirates"}`, token: alice,
+			status: http.StatusCreated, contains: []string{`"user":"alice"`}},
+		{name: "thread as the caller", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"Jolly","author":"alice","message":"m","slug":"jolly"}`, token: bob,
+			status: http.StatusCreated, contains: []string{`"author":"bob"`}},
+		{name: "posts as the caller", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"bob","message":"a"},{"author":"nobody","message":"b"}]`, token: alice,
+			status: http.StatusCreated, field: "author", values: []interface{}{"alice", "alice"}},
+		{name: "anonymous vote", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"alice","voice":1}`,
+			status: http.StatusUnauthorized},
+		{name: "vote as the caller", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"alice","voice":-1}`, token: bob,
+			status: http.StatusOK, contains: []string{`"votes":-1`}},
+		{name: "vote again as alice", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"bob","voice":-1}`, token: alice,
+			status: http.StatusOK, contains: []string{`"votes":-2`}},
+		{name: "profile of another user", method: "POST", path: "/api/user/bob/profile", body: `{"about":"pwned"}`, token: alice,
+			status: http.StatusForbidden},
+		{name: "own profile", method: "POST", path: "/api/user/bob/profile", body: `{"about":"sailor"}`, token: bob,
+			status: http.StatusOK, contains: []string{`"about":"sailor"`}},
+		{name: "reads stay open", method: "GET", path: "/api/thread/jolly/details",
+			status: http.StatusOK},
+	})
+}
+
+// login returns the token of a user registered with password.
+func login(t *testing.T, api *testAPI, nickname, password string) string {
+	t.Helper()
+	status, body := api.do(t, "POST", "/api/auth/login", fmt.Sprintf(`{"nickname":%q,"password":%q}`, nickname, password))
+	var token models.Token
+	if status != http.StatusOK || token.UnmarshalJSON(body) != nil || token.Token == "" {
+		t.Fatalf("login %s: %d %s", nickname, status, body)
+	}
+	return token.Token
+}
+
+func TestRoles(t *testing.T) {
+	repos := newMemoryRepositories(nil)
+	api := serveTestAPI(t, repos, httphandlers.SocketAuth{}, func(c *cfg.Config) {
+		c.Auth.Enabled = true
+		c.Auth.Secret = strings.Repeat("s", cfg.MinSecret)
+	})
+	tokens := map[string]string{}
+	register := func(nicknames ...string) {
+		for _, nickname := range nicknames {
+			status, body := api.do(t, "POST", "/api/user/"+nickname+"/create",
+				fmt.Sprintf(`{"fullname":%q,"about":"a","email":"%s@mail.ru","password":"pw"}`, nickname, nickname))
+			if status != http.StatusCreated {
+				t.Fatalf("register %s: %d %s", nickname, status, body)
+			}
+			tokens[nickname] = login(t, api, nickname, "pw")
+		}
+	}
+	register("root", "alice", "bob", "carol")
+	// jack is configured but registers only after startup, by anyone
+	if err := grantAdmins(context.Background(), repos.user, []string{"Root", "jack"}, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	register("jack")
+	root, alice, bob, carol, jack := tokens["root"], tokens["alice"], tokens["bob"], tokens["carol"], tokens["jack"]
+
+	runCases(t, api, []apiCase{
+		{name: "forum of alice", method: "POST", path: "/api/forum/create", body: `{"title":"Pirates","user":"alice","slug":"pirates"}`, token: alice,
+			status: http.StatusCreated},
+		{name: "thread of carol", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"Jolly","author":"carol","message":"m","slug":"jolly"}`, token: carol,
+			status: http.StatusCreated},
+		{name: "post of carol", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"carol","message":"ahoy"}]`, token: carol,
+			status: http.StatusCreated},
+
+		{name: "stranger edits the post", method: "POST", path: "/api/post/1/details", body: `{"message":"pwned"}`, token: bob,
+			status: http.StatusForbidden},
+		{name: "stranger edits the thread", method: "POST", path: "/api/thread/jolly/details", body: `{"title":"pwned"}`, token: bob,
+			status: http.StatusForbidden},
+		{name: "anonymous edit", method: "POST", path: "/api/post/1/details", body: `{"message":"pwned"}`,
+			status: http.StatusUnauthorized},
+		{name: "author edits the post", method: "POST", path: "/api/post/1/details", body: `{"message":"ahoy!"}`, token: carol,
+			status: http.StatusOK, contains: []string{`"isEdited":true`}},
+		{name: "stranger appoints himself", method: "PUT", path: "/api/forum/pirates/moderators/bob", token: bob,
+			status: http.StatusForbidden},
+		{name: "owner appoints bob", method: "PUT", path: "/api/forum/pirates/moderators/BOB", token: alice,
+			status: http.StatusOK, field: "nickname", values: []interface{}{"bob"}},
+		{name: "appoint again", method: "PUT", path: "/api/forum/pirates/moderators/bob", token: alice,
+			status: http.StatusOK, field: "nickname", values: []interface{}{"bob"}},
+		{name: "admin appoints carol", method: "PUT", path: "/api/forum/pirates/moderators/carol", token: root,
+			status: http.StatusOK, field: "nickname", values: []interface{}{"bob", "carol"}},
+		{name: "moderators are public", method: "GET", path: "/api/forum/pirates/moderators",
+			status: http.StatusOK, field: "nickname", values: []interface{}{"bob", "carol"}},
+		{name: "moderator edits the post", method: "POST", path: "/api/post/1/details", body: `{"message":"moderated"}`, token: bob,
+			status: http.StatusOK, contains: []string{`"message":"moderated"`, `"author":"carol"`}},
+		{name: "moderator edits the thread", method: "POST", path: "/api/thread/jolly/details", body: `{"title":"Moderated"}`, token: bob,
+			status: http.StatusOK, contains: []string{`"title":"Moderated"`, `"author":"carol"`}},
+		{name: "moderator appoints", method: "PUT", path: "/api/forum/pirates/moderators/root", token: bob,
+			status: http.StatusForbidden},
+		{name: "owner dismisses carol", method: "DELETE", path: "/api/forum/pirates/moderators/carol", token: alice,
+			status: http.StatusOK, field: "nickname", values: []interface{}{"bob"}},
+		{name: "dismiss again", method: "DELETE", path: "/api/forum/pirates/moderators/carol", token: alice,
+			status: http.StatusNotFound},
+		{name: "unknown forum", method: "GET", path: "/api/forum/nope/moderators",
+			status: http.StatusNotFound},
+
+		{name: "member grants a role", method: "PUT", path: "/api/user/carol/role", body: `{"role":"admin"}`, token: carol,
+			status: http.StatusForbidden},
+		{name: "unknown role", method: "PUT", path: "/api/user/carol/role", body: `{"role":"owner"}`, token: root,
+			status: http.StatusBadRequest},
+		{name: "admin bans carol", method: "PUT", path: "/api/user/CAROL/role", body: `{"role":"banned"}`, token: root,
+			status: http.StatusOK, contains: []string{`"nickname":"carol"`, `"role":"banned"`}},
+		{name: "banned vote", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"carol","voice":1}`, token: carol,
+			status: http.StatusForbidden, contains: []string{`banned`}},
+		{name: "banned edit", method: "POST", path: "/api/post/1/details", body: `{"message":"again"}`, token: carol,
+			status: http.StatusForbidden},
+		{name: "admin grants admin", method: "PUT", path: "/api/user/alice/role", body: `{"role":"admin"}`, token: root,
+			status: http.StatusOK},
+		{name: "granted admin unbans", method: "PUT", path: "/api/user/carol/role", body: `{"role":"member"}`, token: alice,
+			status: http.StatusOK},
+		{name: "unbanned vote", method: "POST", path: "/api/thread/jolly/vote", body: `{"nickname":"carol","voice":1}`, token: carol,
+			status: http.StatusOK},
+
+		{name: "anonymous delete", method: "DELETE", path: "/api/post/1",
+			status: http.StatusUnauthorized},
+		{name: "moderator deletes the post", method: "DELETE", path: "/api/post/1", token: bob,
+			status: http.StatusOK, contains: []string{`"author":"carol"`, `"isDeleted":true`}},
+		{name: "author deletes a subtree", method: "DELETE", path: "/api/post/1?subtree=true", token: carol,
+			status: http.StatusForbidden},
+		{name: "admin deletes a subtree", method: "DELETE", path: "/api/post/1?subtree=true", token: root,
+			status: http.StatusNoContent},
+
+		{name: "anonymous clear", method: "POST", path: "/api/service/clear",
+			status: http.StatusUnauthorized},
+		{name: "member clear", method: "POST", path: "/api/service/clear", token: carol,
+			status: http.StatusForbidden},
+		{name: "admin by name only", method: "PUT", path: "/api/user/carol/role", body: `{"role":"banned"}`, token: jack,
+			status: http.StatusForbidden},
+
+		{name: "author closes the thread", method: "POST", path: "/api/thread/jolly/state", body: `{"closed":true}`, token: carol,
+			status: http.StatusForbidden},
+		{name: "moderator closes the thread", method: "POST", path: "/api/thread/jolly/state", body: `{"closed":true}`, token: bob,
+			status: http.StatusOK, contains: []string{`"isClosed":true`}},
+		{name: "author deletes the thread", method: "DELETE", path: "/api/thread/jolly", token: carol,
+			status: http.StatusForbidden},
+		{name: "anonymous deletes the thread", method: "DELETE", path: "/api/thread/jolly",
+			status: http.StatusUnauthorized},
+		{name: "owner deletes the thread", method: "DELETE", path: "/api/thread/jolly", token: alice,
+			status: http.StatusNoContent},
+	})
+}
+
+func TestMemoryAdmins(t *testing.T) {
+	api := serveTestAPI(t, newMemoryRepositories([]string{"Root"}), httphandlers.SocketAuth{}, func(c *cfg.Config) {
+		c.Auth.Enabled = true
+		c.Auth.Secret = strings.Repeat("s", cfg.MinSecret)
+	})
+	tokens := map[string]string{}
+	for _, nickname := range []string{"root", "carol"} {
+		status, body := api.do(t, "POST", "/api/user/"+nickname+"/create",
+			fmt.Sprintf(`{"fullname":%q,"about":"a","email":"%s@mail.ru","password":"pw"}`, nickname, nickname))
+		if status != http.StatusCreated {
+			t.Fatalf("register %s: %d %s", nickname, status, body)
+		}
+		tokens[nickname] = login(t, api, nickname, "pw")
+	}
+
+	runCases(t, api, []apiCase{
+		{name: "member grants a role", method: "PUT", path: "/api/user/root/role", body: `{"role":"banned"}`, token: tokens["carol"],
+			status: http.StatusForbidden},
+		{name: "admin on registration", method: "PUT", path: "/api/user/carol/role", body: `{"role":"banned"}`, token: tokens["root"],
+			status: http.StatusOK, contains: []string{`"role":"banned"`}},
+	})
+}
+
+func TestSetPassword(t *testing.T) {
+	repos := newMemoryRepositories([]string{"root"})
+	api := serveTestAPI(t, repos, httphandlers.SocketAuth{}, func(c *cfg.Config) {
+		c.Auth.Enabled = true
+		c.Auth.Secret = strings.Repeat("s", cfg.MinSecret)
+	})
+	tokens := map[string]string{}
+	for _, nickname := range []string{"root", "carol"} {
+		status, body := api.do(t, "POST", "/api/user/"+nickname+"/create",
+			fmt.Sprintf(`{"fullname":%q,"about":"a","email":"%s@mail.ru","password":"pw"}`, nickname, nickname))
+		if status != http.StatusCreated {
+			t.Fatalf("register %s: %d %s", nickname, status, body)
+		}
+		tokens[nickname] = login(t, api, nickname, "pw")
+	}
+	// registered while auth was disabled
+	if _, err := repos.user.Create(context.Background(), models.User{Nickname: "bob", Fullname: "Bob", Email: "bob@mail.ru"}); err != nil {
+		t.Fatal(err)
+	}
+
+	runCases(t, api, []apiCase{
+		{name: "no password to log in with", method: "POST", path: "/api/auth/login", body: `{"nickname":"bob","password":"pearl"}`,
+			status: http.StatusUnauthorized},
+		{name: "anonymous", method: "POST", path: "/api/user/bob/password", body: `{"password":"pearl"}`,
+			status: http.StatusUnauthorized},
+		{name: "stranger", method: "POST", path: "/api/user/bob/password", body: `{"password":"pearl"}`, token: tokens["carol"],
+			status: http.StatusForbidden},
+		{name: "empty", method: "POST", path: "/api/user/carol/password", body: `{"password":""}`, token: tokens["carol"],
+			status: http.StatusBadRequest},
+		{name: "admin sets it", method: "POST", path: "/api/user/BOB/password", body: `{"password":"pearl"}`, token: tokens["root"],
+			status: http.StatusNoContent},
+		{name: "own", method: "POST", path: "/api/user/carol/password", body: `{"password":"pw2"}`, token: tokens["carol"],
+			status: http.StatusNoContent},
+		{name: "old password", method: "POST", path: "/api/auth/login", body: `{"nickname":"carol","password":"pw"}`,
+			status: http.StatusUnauthorized},
+		{name: "unknown user", method: "POST", path: "/api/user/nobody/password", body: `{"password":"pearl"}`, token: tokens["root"],
+			status: http.StatusNotFound},
+	})
+	login(t, api, "bob", "pearl")
+	login(t, api, "carol", "pw2")
+
+	api = newTestAPI(t)
+	seed(t, api)
+	runCases(t, api, []apiCase{
+		{name: "without auth", method: "POST", path: "/api/user/alice/password", body: `{"password":"pearl"}`,
+			status: http.StatusForbidden, contains: []string{`password command`}},
+	})
+}
+
+func TestForumActivity(t *testing.T) {
+	api := serveTestAPI(t, newMemoryRepositories(nil), httphandlers.SocketAuth{}, openModeration)
+	seed(t, api)
+
+	runCases(t, api, []apiCase{
+		{name: "no upgrade", method: "GET", path: "/api/forum/activity",
+			status: http.StatusBadRequest, contains: []string{`expected a websocket upgrade`}},
+		{name: "seed carol", method: "POST", path: "/api/user/carol/create", body: `{"fullname":"Carol","about":"c","email":"carol@mail.ru"}`,
+			status: http.StatusCreated},
+	})
+
+	conn, _ := api.socket(t, nil)
+	replies := []struct {
+		name, send string
+		want       map[string]interface{}
+	}{
+		{"unknown forum", `{"type":"subscribe","forum":"nope"}`,
+			map[string]interface{}{"type": "error", "forum": "nope", "status": 404.0, "message": "Can't find forum by slug: nope"}},
+		{"unknown type", `{"type":"dance"}`,
+			map[string]interface{}{"type": "error", "status": 400.0, "message": `unknown message type "dance", want subscribe or unsubscribe`}},
+		{"subscribe", `{"type":"subscribe","forum":"PIRATES"}`,
+			map[string]interface{}{"type": "subscribed", "forum": "pirates"}},
+	}
+	for _, r := range replies {
+		if got := exchange(t, conn, r.send); !reflect.DeepEqual(got, r.want) {
+			t.Errorf("%s: got %v, want %v", r.name, got, r.want)
+		}
+	}
+
+	// Rows are read when a change is delivered, so each one is awaited
+	// before the next is made.
+	steps := []struct {
+		apiCase
+		// want are the types and a field of the messages that follow.
+		want [][3]string
+	}{
+		{apiCase{name: "new thread", method: "POST", path: "/api/forum/pirates/create", body: `{"title":"Roger","author":"alice","message":"m"}`, status: http.StatusCreated},
+			[][3]string{{"new_thread", "thread", "Roger"}}},
+		{apiCase{name: "new member", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"carol","message":"ahoy"}]`, status: http.StatusCreated},
+			[][3]string{{"member", "user", "carol"}, {"post", "post", "ahoy"}}},
+		{apiCase{name: "old member", method: "POST", path: "/api/thread/jolly/create", body: `[{"author":"carol","message":"again"}]`, status: http.StatusCreated},
+			[][3]string{{"post", "post", "again"}}},
+		{apiCase{name: "pin thread", method: "POST", path: "/api/thread/jolly/state", body: `{"pinned":true}`, status: http.StatusOK},
+			[][3]string{{"thread", "thread", "isPinned:true"}}},
+		{apiCase{name: "delete thread", method: "DELETE", path: "/api/thread/2", status: http.StatusNoContent},
+			[][3]string{{"thread_delete", "thread", "id:2"}}},
+	}
+	for _, step := range steps {
+		runCases(t, api, []apiCase{step.apiCase})
+		for _, want := range step.want {
+			got := exchange(t, conn, "")
+			if got["type"] != want[0] || got["forum"] != "pirates" || !strings.Contains(fmt.Sprint(got[want[1]]), want[2]) {
+				t.Errorf("%s: got %v, want %s with %s %s", step.name, got, want[0], want[1], want[2])
+			}
+		}
+	}
+
+	if got := exchange(t, conn, `{"type":"unsubscribe","forum":"pirates"}`); got["type"] != "unsubscribed" {
+		t.Errorf("unsubscribe: got %v", got)
+	}
+}
+
+func TestSocketAuth(t *testing.T) {
+	api := serveTestAPI(t, newMemoryRepositories(nil), httphandlers.SocketAuth{
+		Connect: func(ctx *fasthttp.RequestCtx) (string, error) {
+			user := string(ctx.Request.Header.Peek("X-Forum-User"))
+			if user == "" {
+				return "", models.Validation("who are you?")
+			}
+			return user, nil
+		},
+		Subscribe: func(_ context.Context, user string, forum models.Forum) error {
+			if !strings.EqualFold(user, forum.User) {
+				return models.Validation("only %s follows %s", forum.User, forum.Slug)
+			}
+			return nil
+		},
+	})
+	seed(t, api)
+
+	if _, status := api.socket(t, nil); status != http.StatusBadRequest {
+		t.Errorf("anonymous: got status %d, want %d", status, http.StatusBadRequest)
+	}
+	subscribe := `{"type":"subscribe","forum":"pirates"}`
+	bob, _ := api.socket(t, http.Header{"X-Forum-User": {"bob"}})
+	if got := exchange(t, bob, subscribe); got["type"] != "error" || got["message"] != "only alice follows pirates" {
+		t.Errorf("bob: got %v", got)
+	}
+	alice, _ := api.socket(t, http.Header{"X-Forum-User": {"alice"}})
+	if got := exchange(t, alice, subscribe); got["type"] != "subscribed" {
+		t.Errorf("alice: got %v", got)
+	}
+}
+
+// downThreads and downService answer like a postgres that went away,
+// brokenPosts fails in a way no error kind describes.
+type downThreads struct{ repository.ThreadRepoI }
+
+func (downThreads) GetBySlugOrId(context.Context, string) (models.Thread, error) {
+	return models.Thread{}, models.Unavailable(errors.New("dial tcp: connection refused"))
+}
+
+type downService struct{ repository.ServiceRepoI }
+
+func (downService) Status(context.Context) (models.Status, error) {
+	return models.Status{}, models.Unavailable(errors.New("dial tcp: connection refused"))
+}
+
+type brokenPosts struct{ repository.PostRepoI }
+
+func (brokenPosts) Get(context.Context, int, []string) (models.PostFull, error) {
+	return models.PostFull{}, errors.New("can't scan into dest[3]")
+}
+
+func TestStorageErrors(t *testing.T) {
+	repos := newMemoryRepositories(nil)
+	repos.thread = downThreads{repos.thread}
+	repos.service = downService{repos.service}
+	repos.post = brokenPosts{repos.post}
+	api := serveTestAPI(t, repos, httphandlers.SocketAuth{})
+
+	runCases(t, api, []apiCase{
+		{name: "thread while database is down", method: "GET", path: "/api/thread/jolly/details",
+			status: http.StatusServiceUnavailable, contains: []string{`database is unavailable`}},
+		{name: "status while database is down", method: "GET", path: "/api/service/status",
+			status: http.StatusServiceUnavailable, contains: []string{`database is unavailable`}},
+		{name: "unknown error does not leak", method: "GET", path: "/api/post/1/details",
+			status: http.StatusInternalServerError, contains: []string{`"message":"internal server error"`}},
+	})
+}
+
+// stuckPosts holds GetThreadPosts until its context ends, reporting why
+// on ended.
+type stuckPosts struct {
+	repository.ThreadRepoI
+	started chan struct{}
+	ended   chan error
+}
+
+func (r stuckPosts) GetThreadPosts(ctx context.Context, _ models.Thread, _, _ string, _ int, _ bool) ([]models.Post, error) {
+	r.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+		r.ended <- ctx.Err()
+		return nil, ctx.Err()
+	case <-time.After(5 * time.Second):
+		r.ended <- nil
+		return []models.Post{}, nil
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("disconnects are noticed on unix only")
+	}
+	repos := newMemoryRepositories(nil)
+	stuck := stuckPosts{repos.thread, make(chan struct{}, 1), make(chan error, 1)}
+	repos.thread = stuck
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := serveTestAPIOn(t, ln, func() (net.Conn, error) { return net.Dial("tcp", ln.Addr().String()) },
+		repos, httphandlers.SocketAuth{}, func(c *cfg.Config) { c.API.RequestTimeout = time.Minute })
+	seed(t, api)
+
+	conn, err := api.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write([]byte("GET /api/thread/jolly/posts HTTP/1.1\r\nHost: forum\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stuck.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("posts were not read")
+	}
+	conn.Close()
+
+	select {
+	case err = <-stuck.ended:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("repository call ended with %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("repository call still runs after the client went away")
+	}
+
+	// the watch leaves served connections usable
+	runCases(t, api, []apiCase{
+		{name: "keep-alive after a watched request", method: "GET", path: "/api/thread/jolly/details",
+			status: http.StatusOK},
+		{name: "same connection", method: "GET", path: "/api/forum/pirates/details",
+			status: http.StatusOK},
+	})
+}
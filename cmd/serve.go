@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
+)
+
+var errForcedShutdown = errors.New("shutdown deadline exceeded, in-flight requests were dropped")
+
+// serve runs srv on addr until ctx is cancelled. It then stops accepting
+// connections and waits up to drainTimeout for in-flight requests to finish.
+func serve(ctx context.Context, logger *slog.Logger, srv *fasthttp.Server, addr string, drainTimeout time.Duration) error {
+	ln, err := net.Listen("tcp4", addr)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err = <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("draining in-flight requests", "deadline", drainTimeout)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err = srv.ShutdownWithContext(drainCtx)
+	// Serve may not have registered the listener yet when the signal came in.
+	_ = ln.Close()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errForcedShutdown
+	}
+	return err
+}
+
+// serveGRPC is serve for the grpc server: after ctx is cancelled it stops
+// taking new calls and waits up to drainTimeout for running ones, streams
+// included, before cutting them off.
+func serveGRPC(ctx context.Context, logger *slog.Logger, srv *grpc.Server, addr string, drainTimeout time.Duration) error {
+	ln, err := net.Listen("tcp4", addr)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err = <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("draining in-flight grpc calls", "deadline", drainTimeout)
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		return nil
+	case <-timer.C:
+		srv.Stop()
+		return errForcedShutdown
+	}
+}
+
+// serveAll runs every server until ctx is cancelled or one of them fails,
+// which stops the others as well. It returns the errors of all of them.
+func serveAll(ctx context.Context, servers ...func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, run := range servers {
+		wg.Add(1)
+		go func(i int, run func(context.Context) error) {
+			defer wg.Done()
+			if errs[i] = run(ctx); errs[i] != nil {
+				cancel()
+			}
+		}(i, run)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"park_db_course/cfg"
+	"park_db_course/internal/metrics"
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+	"park_db_course/internal/repository/memory"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// repositories is everything the handlers need from a storage backend.
+type repositories struct {
+	user    repository.UserRepoI
+	forum   repository.ForumRepoI
+	thread  repository.ThreadRepoI
+	post    repository.PostRepoI
+	service repository.ServiceRepoI
+	events  repository.EventRepoI
+}
+
+func newPostgresRepositories(db *pgxpool.Pool, conf cfg.DB) repositories {
+	return repositories{
+		user:    repository.NewUserRepo(db),
+		forum:   repository.NewForumRepo(db),
+		thread:  repository.NewThreadRepo(db, conf.CopyThreshold),
+		post:    repository.NewPostRepo(db),
+		service: repository.NewServiceRepo(db),
+		events:  repository.NewEventRepo(db),
+	}
+}
+
+// newMemoryRepositories makes admins the users registering with one of
+// those nicknames: the store starts empty, there is nobody to grant them
+// to at startup.
+func newMemoryRepositories(admins []string) repositories {
+	store := memory.NewStore()
+	store.SetAdmins(admins)
+	return repositories{
+		user:    memory.NewUserRepo(store),
+		forum:   memory.NewForumRepo(store),
+		thread:  memory.NewThreadRepo(store),
+		post:    memory.NewPostRepo(store),
+		service: memory.NewServiceRepo(store),
+		events:  memory.NewEventRepo(store),
+	}
+}
+
+// instrumentRepositories times every repository call in m. Events are
+// one long call and stay as they are.
+func instrumentRepositories(repos repositories, m *metrics.Metrics) repositories {
+	return repositories{
+		user:    m.UserRepo(repos.user),
+		forum:   m.ForumRepo(repos.forum),
+		thread:  m.ThreadRepo(repos.thread),
+		post:    m.PostRepo(repos.post),
+		service: m.ServiceRepo(repos.service),
+		events:  repos.events,
+	}
+}
+
+// grantAdmins gives the admin role to the users named in auth.admins. A
+// nickname nobody registered yet is skipped: granting it by name would
+// hand the site to whoever registers it first. Register the admins, then
+// restart.
+func grantAdmins(ctx context.Context, users repository.UserRepoI, nicknames []string, logger *slog.Logger) error {
+	for _, nickname := range nicknames {
+		_, err := users.SetRole(ctx, nickname, models.RoleAdmin)
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			logger.Warn("admin is not registered, not granted", "nickname", nickname)
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
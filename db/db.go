@@ -0,0 +1,10 @@
+// Package db holds the database schema as numbered migrations.
+//
+// Files are named NNNN_name.up.sql / NNNN_name.down.sql and applied in
+// version order by internal/migrate, see `./main migrate -h`.
+package db
+
+import "embed"
+
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS vote, post, thread, forum_user, forum, "user" CASCADE;
+
+DROP FUNCTION IF EXISTS thread_vote();
+DROP FUNCTION IF EXISTS thread_vote_UPDATE();
+DROP FUNCTION IF EXISTS create_post();
+DROP FUNCTION IF EXISTS create_thread();
@@ -70,6 +70,7 @@ BEGIN
 end;
 $$ language plpgsql;
 
+DROP TRIGGER IF EXISTS "vote_insert" ON "vote";
 CREATE TRIGGER "vote_insert"
     AFTER INSERT
     ON "vote"
@@ -86,6 +87,7 @@ BEGIN
 END;
 $$ language plpgsql;
 
+DROP TRIGGER IF EXISTS "vote_update" ON "vote";
 CREATE TRIGGER "vote_update"
     AFTER UPDATE
     ON "vote"
@@ -113,6 +115,7 @@ BEGIN
 END
 $$ language plpgsql;
 
+DROP TRIGGER IF EXISTS create_post ON post;
 CREATE TRIGGER create_post
     BEFORE INSERT
     ON post
@@ -139,42 +142,28 @@ BEGIN
 END
 $$ language plpgsql;
 
+DROP TRIGGER IF EXISTS create_thread ON thread;
 CREATE TRIGGER create_thread
     BEFORE INSERT
     ON thread
     FOR EACH ROW
 EXECUTE PROCEDURE create_thread();
 
-DROP INDEX IF EXISTS user_nickname_idx;
 CREATE INDEX IF NOT EXISTS user_nickname_idx ON "user" (nickname);
-DROP INDEX IF EXISTS user_info_idx;
 CREATE INDEX IF NOT EXISTS user_info_idx on "user" (nickname, fullname, about, email);
 
-DROP INDEX IF EXISTS forum_slug_idx;
 CREATE INDEX IF NOT EXISTS forum_slug_idx ON forum ("slug");
-DROP INDEX IF EXISTS forum_user_idx;
-CREATE INDEX IF NOT EXISTS forum_user_idx ON forum ("user");
-
-DROP INDEX IF EXISTS forum_user_idx;
 CREATE INDEX IF NOT EXISTS forum_user_idx ON forum_user (forum, "user");
 
-DROP INDEX IF EXISTS post_thread_idx;
 CREATE INDEX IF NOT EXISTS post_thread_idx ON post (thread);
-DROP INDEX IF EXISTS  post_thread_path_idx;
 CREATE INDEX IF NOT EXISTS post_thread_path_idx ON post (thread, path);
-DROP INDEX IF EXISTS  post_path_parent_idx;
 CREATE INDEX IF NOT EXISTS post_path_parent_idx ON post (thread, id, (path[1]), parent);
 
-DROP INDEX IF EXISTS thread_slug_idx;
 CREATE INDEX IF NOT EXISTS thread_slug_idx ON thread (slug);
-DROP INDEX IF EXISTS thread_author_idx;
 CREATE INDEX IF NOT EXISTS thread_author_idx ON thread (author);
-DROP INDEX IF EXISTS thread_forum_idx;
 CREATE INDEX IF NOT EXISTS thread_forum_idx ON thread (forum);
-DROP INDEX IF EXISTS thread_created_idx;
 CREATE INDEX IF NOT EXISTS thread_created_idx ON thread (created);
 
-DROP INDEX IF EXISTS vote_user_thread_idx;
 CREATE INDEX IF NOT EXISTS vote_user_thread_idx ON vote ("user", thread);
 
-VACUUM ANALYSE;
+ANALYSE;
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS notify_post_insert ON post;
+DROP TRIGGER IF EXISTS notify_post_update ON post;
+DROP TRIGGER IF EXISTS notify_thread ON thread;
+
+DROP FUNCTION IF EXISTS notify_post();
+DROP FUNCTION IF EXISTS notify_thread();
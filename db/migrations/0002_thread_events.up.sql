@@ -0,0 +1,50 @@
+-- Changes to threads are announced on the thread_events channel, so every
+-- API instance can push them to its watchers. Payloads carry ids only, as
+-- messages may exceed the 8000 byte limit of NOTIFY.
+
+CREATE OR REPLACE FUNCTION notify_post() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_notify('thread_events', json_build_object(
+            'kind', CASE TG_OP WHEN 'INSERT' THEN 'post' ELSE 'post_edit' END,
+            'thread', new.thread,
+            'post', new.id)::text);
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+DROP TRIGGER IF EXISTS notify_post_insert ON post;
+CREATE TRIGGER notify_post_insert
+    AFTER INSERT
+    ON post
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_post();
+
+DROP TRIGGER IF EXISTS notify_post_update ON post;
+CREATE TRIGGER notify_post_update
+    AFTER UPDATE OF message
+    ON post
+    FOR EACH ROW
+    WHEN (old.message IS DISTINCT FROM new.message)
+EXECUTE PROCEDURE notify_post();
+
+-- Fires for the votes updates of thread_vote and thread_vote_UPDATE too.
+CREATE OR REPLACE FUNCTION notify_thread() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF old.title IS DISTINCT FROM new.title OR old.message IS DISTINCT FROM new.message THEN
+        PERFORM pg_notify('thread_events', json_build_object('kind', 'thread', 'thread', new.id)::text);
+    END IF;
+    IF old.votes IS DISTINCT FROM new.votes THEN
+        PERFORM pg_notify('thread_events', json_build_object('kind', 'votes', 'thread', new.id)::text);
+    END IF;
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+DROP TRIGGER IF EXISTS notify_thread ON thread;
+CREATE TRIGGER notify_thread
+    AFTER UPDATE OF title, message, votes
+    ON thread
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_thread();
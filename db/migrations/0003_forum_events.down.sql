@@ -0,0 +1,28 @@
+DROP TRIGGER IF EXISTS notify_forum_user ON forum_user;
+DROP TRIGGER IF EXISTS notify_thread_insert ON thread;
+DROP FUNCTION IF EXISTS notify_forum_user();
+
+-- the functions of 0002_thread_events
+CREATE OR REPLACE FUNCTION notify_post() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_notify('thread_events', json_build_object(
+            'kind', CASE TG_OP WHEN 'INSERT' THEN 'post' ELSE 'post_edit' END,
+            'thread', new.thread,
+            'post', new.id)::text);
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_thread() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF old.title IS DISTINCT FROM new.title OR old.message IS DISTINCT FROM new.message THEN
+        PERFORM pg_notify('thread_events', json_build_object('kind', 'thread', 'thread', new.id)::text);
+    END IF;
+    IF old.votes IS DISTINCT FROM new.votes THEN
+        PERFORM pg_notify('thread_events', json_build_object('kind', 'votes', 'thread', new.id)::text);
+    END IF;
+    RETURN NULL;
+END
+$$ language plpgsql;
@@ -0,0 +1,61 @@
+-- Forum activity joins the thread changes on one channel, forum_events:
+-- post payloads carry the forum, new threads and first posts of a user in
+-- a forum are announced as well.
+
+CREATE OR REPLACE FUNCTION notify_post() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_notify('forum_events', json_build_object(
+            'kind', CASE TG_OP WHEN 'INSERT' THEN 'post' ELSE 'post_edit' END,
+            'forum', new.forum,
+            'thread', new.thread,
+            'post', new.id)::text);
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_thread() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'new_thread', 'forum', new.forum, 'thread', new.id)::text);
+        RETURN NULL;
+    END IF;
+    IF old.title IS DISTINCT FROM new.title OR old.message IS DISTINCT FROM new.message THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'thread', 'forum', new.forum, 'thread', new.id)::text);
+    END IF;
+    IF old.votes IS DISTINCT FROM new.votes THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'votes', 'forum', new.forum, 'thread', new.id)::text);
+    END IF;
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+DROP TRIGGER IF EXISTS notify_thread_insert ON thread;
+CREATE TRIGGER notify_thread_insert
+    AFTER INSERT
+    ON thread
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_thread();
+
+-- create_post and create_thread add a forum_user row for every post and
+-- thread, only the first one of a user in a forum makes a member.
+CREATE OR REPLACE FUNCTION notify_forum_user() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF NOT EXISTS(SELECT 1 FROM forum_user WHERE forum = new.forum AND "user" = new."user" AND id <> new.id) THEN
+        PERFORM pg_notify('forum_events', json_build_object(
+                'kind', 'member',
+                'forum', (SELECT slug FROM forum WHERE id = new.forum),
+                'user', (SELECT nickname FROM "user" WHERE id = new."user"))::text);
+    END IF;
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+DROP TRIGGER IF EXISTS notify_forum_user ON forum_user;
+CREATE TRIGGER notify_forum_user
+    AFTER INSERT
+    ON forum_user
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_forum_user();
@@ -0,0 +1,4 @@
+-- Users registered with a password can log in and act with a bearer token,
+-- see auth in the config. The bcrypt hash stays NULL for the others.
+
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS password_hash text;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS forum_moderator;
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS role;
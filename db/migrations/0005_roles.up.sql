@@ -0,0 +1,15 @@
+-- Site roles and forum moderators, consulted with auth enabled: admins may
+-- do anything, banned users nothing; the owner of a forum ("user" of forum)
+-- and its moderators edit every thread and post in it.
+
+ALTER TABLE "user"
+    ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'member'
+        CHECK (role IN ('admin', 'member', 'banned'));
+
+CREATE UNLOGGED TABLE IF NOT EXISTS forum_moderator
+(
+    forum   bigint REFERENCES forum (id) ON DELETE CASCADE  NOT NULL,
+    "user"  bigint REFERENCES "user" (id) ON DELETE CASCADE NOT NULL,
+    created timestamptz DEFAULT now()                       NOT NULL,
+    PRIMARY KEY (forum, "user")
+);
@@ -0,0 +1,6 @@
+-- Deleted posts stay in the tree as placeholders: their message, and the
+-- author if asked, are cleared and is_deleted set, so path and the children
+-- below them are untouched. They no longer count in forum.posts.
+
+ALTER TABLE post
+    ADD COLUMN IF NOT EXISTS is_deleted bool NOT NULL DEFAULT false;
@@ -0,0 +1,32 @@
+DROP TRIGGER IF EXISTS notify_thread_delete ON thread;
+
+-- the trigger of 0002_thread_events and the function of 0003_forum_events
+DROP TRIGGER IF EXISTS notify_thread ON thread;
+CREATE TRIGGER notify_thread
+    AFTER UPDATE OF title, message, votes
+    ON thread
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_thread();
+
+CREATE OR REPLACE FUNCTION notify_thread() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'new_thread', 'forum', new.forum, 'thread', new.id)::text);
+        RETURN NULL;
+    END IF;
+    IF old.title IS DISTINCT FROM new.title OR old.message IS DISTINCT FROM new.message THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'thread', 'forum', new.forum, 'thread', new.id)::text);
+    END IF;
+    IF old.votes IS DISTINCT FROM new.votes THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'votes', 'forum', new.forum, 'thread', new.id)::text);
+    END IF;
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+DROP INDEX IF EXISTS thread_forum_pinned_created_idx;
+
+ALTER TABLE thread
+    DROP COLUMN IF EXISTS is_closed,
+    DROP COLUMN IF EXISTS is_pinned;
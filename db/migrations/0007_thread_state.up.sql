@@ -0,0 +1,49 @@
+-- Moderators close threads, which then take no posts, and pin them, which
+-- lists them first in their forum.
+
+ALTER TABLE thread
+    ADD COLUMN IF NOT EXISTS is_closed bool NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS is_pinned bool NOT NULL DEFAULT false;
+
+-- Forum listings read the pinned threads and the others apart, each in the
+-- order of this index either way.
+CREATE INDEX IF NOT EXISTS thread_forum_pinned_created_idx ON thread (forum, is_pinned, created);
+
+-- Closing and pinning are announced as thread changes, deleting a thread
+-- as thread_delete: its watchers stop waiting for it. The posts deleted
+-- with it are not announced one by one.
+CREATE OR REPLACE FUNCTION notify_thread() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'new_thread', 'forum', new.forum, 'thread', new.id)::text);
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'thread_delete', 'forum', old.forum, 'thread', old.id)::text);
+        RETURN NULL;
+    END IF;
+    IF old.title IS DISTINCT FROM new.title OR old.message IS DISTINCT FROM new.message
+        OR old.is_closed IS DISTINCT FROM new.is_closed OR old.is_pinned IS DISTINCT FROM new.is_pinned THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'thread', 'forum', new.forum, 'thread', new.id)::text);
+    END IF;
+    IF old.votes IS DISTINCT FROM new.votes THEN
+        PERFORM pg_notify('forum_events', json_build_object('kind', 'votes', 'forum', new.forum, 'thread', new.id)::text);
+    END IF;
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+DROP TRIGGER IF EXISTS notify_thread ON thread;
+CREATE TRIGGER notify_thread
+    AFTER UPDATE OF title, message, votes, is_closed, is_pinned
+    ON thread
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_thread();
+
+DROP TRIGGER IF EXISTS notify_thread_delete ON thread;
+CREATE TRIGGER notify_thread_delete
+    AFTER DELETE
+    ON thread
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_thread();
@@ -0,0 +1,10 @@
+-- the row trigger of 0002_thread_events, notify_post is still there for
+-- the updates
+DROP TRIGGER IF EXISTS notify_post_insert ON post;
+CREATE TRIGGER notify_post_insert
+    AFTER INSERT
+    ON post
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_post();
+
+DROP FUNCTION IF EXISTS notify_post_batch();
@@ -0,0 +1,29 @@
+-- New posts are announced once per statement instead of once per row, a
+-- COPY of thousands of posts sent as many notifications. The payload of
+-- each thread in the statement carries the first and the last id of its
+-- posts, listeners read the posts of the thread in that range created at
+-- the time of the first one: the ids of concurrent batches may interleave,
+-- their creation times do not.
+
+CREATE OR REPLACE FUNCTION notify_post_batch() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_notify('forum_events', json_build_object(
+            'kind', 'post',
+            'forum', p.forum,
+            'thread', p.thread,
+            'post', min(p.id),
+            'last_post', max(p.id))::text)
+    FROM new_posts p
+    GROUP BY p.forum, p.thread;
+    RETURN NULL;
+END
+$$ language plpgsql;
+
+DROP TRIGGER IF EXISTS notify_post_insert ON post;
+CREATE TRIGGER notify_post_insert
+    AFTER INSERT
+    ON post
+    REFERENCING NEW TABLE AS new_posts
+    FOR EACH STATEMENT
+EXECUTE PROCEDURE notify_post_batch();
@@ -0,0 +1,11 @@
+// Package doc embeds the OpenAPI description of the API and the GraphQL
+// schema.
+package doc
+
+import _ "embed"
+
+//go:embed swagger.yml
+var Swagger []byte
+
+//go:embed schema.graphql
+var GraphQLSchema string
@@ -0,0 +1,74 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"park_db_course/internal/logging"
+	"park_db_course/internal/models"
+)
+
+// Error is an entry of the errors list of a response. Extensions carry a
+// machine readable code, see codes below.
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Error codes, in extensions.code.
+const (
+	CodeParseFailed      = "GRAPHQL_PARSE_FAILED"
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	CodeTooComplex       = "QUERY_TOO_COMPLEX"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeBadUserInput     = "BAD_USER_INPUT"
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeUnavailable      = "UNAVAILABLE"
+	CodeTimeout          = "TIMEOUT"
+	CodeInternal         = "INTERNAL_SERVER_ERROR"
+)
+
+func withCode(e *Error, code string) *Error {
+	e.Extensions = map[string]interface{}{"code": code}
+	return e
+}
+
+func validationError(loc Location, format string, args ...interface{}) *Error {
+	return withCode(&Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}}, CodeValidationFailed)
+}
+
+// fieldError is the GraphQL counterpart of writeError in the http package:
+// the kind of the domain error decides the code. Errors of no known kind
+// are logged with the logger of ctx and do not leak driver details.
+func fieldError(ctx context.Context, err error, loc Location, path []interface{}) *Error {
+	e := &Error{Message: err.Error(), Locations: []Location{loc}, Path: path}
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return withCode(e, CodeNotFound)
+	case errors.Is(err, models.ErrConflict):
+		return withCode(e, CodeConflict)
+	case errors.Is(err, models.ErrValidation):
+		return withCode(e, CodeBadUserInput)
+	case errors.Is(err, models.ErrUnauthorized):
+		return withCode(e, CodeUnauthenticated)
+	case errors.Is(err, models.ErrForbidden):
+		return withCode(e, CodeForbidden)
+	case errors.Is(err, models.ErrUnavailable):
+		return withCode(e, CodeUnavailable)
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Message = "request timed out"
+		return withCode(e, CodeTimeout)
+	}
+	logging.FromContext(ctx).ErrorContext(ctx, "field failed", "error", err, "path", path)
+	e.Message = "internal server error"
+	return withCode(e, CodeInternal)
+}
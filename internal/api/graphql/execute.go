@@ -0,0 +1,631 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// Request is a GraphQL request as posted over HTTP.
+type Request struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+}
+
+// Response is the result of a request. Data is left out when the request
+// failed before execution: on a syntax error, an invalid query or a query
+// over the limits.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Limits bound the work a single query may ask for. Zero disables a limit.
+type Limits struct {
+	// MaxDepth is the deepest nesting of fields, { thread { posts { id } } }
+	// is 3 deep.
+	MaxDepth int
+	// MaxComplexity is the number of fields the query may resolve: every
+	// field costs 1 and the selection of a list field counts limit times.
+	MaxComplexity int
+}
+
+// Executor runs queries against the forum schema.
+type Executor struct {
+	schema *Schema
+	uc     Usecases
+	limits Limits
+}
+
+func NewExecutor(uc Usecases, limits Limits) *Executor {
+	return &Executor{schema: newSchema(), uc: uc, limits: limits}
+}
+
+// execution is the state of one request. Fields are resolved one after
+// another, so nothing here needs locking.
+type execution struct {
+	uc      Usecases
+	doc     *document
+	vars    *variables
+	args    map[*field]map[string]interface{}
+	loaders *loaders
+	errors  []*Error
+}
+
+type variables struct {
+	types      map[string]*typeRef
+	values     map[string]interface{} // provided or defaulted only
+	hasDefault map[string]bool
+}
+
+func (v *variables) provided(name string) bool {
+	_, ok := v.values[name]
+	return ok
+}
+
+// rootValue is the source object of the Query and Mutation fields.
+type rootValue struct{}
+
+// Execute runs the operation of req. Execution errors are reported in
+// the response next to the data of the fields that succeeded.
+func (e *Executor) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		var gqlErr *Error
+		if !errors.As(err, &gqlErr) {
+			gqlErr = &Error{Message: err.Error()}
+		}
+		return &Response{Errors: []*Error{withCode(gqlErr, CodeParseFailed)}}
+	}
+
+	op, gqlErr := doc.operation(req.OperationName)
+	if gqlErr != nil {
+		return &Response{Errors: []*Error{gqlErr}}
+	}
+	root := e.schema.query
+	if op.kind == "mutation" {
+		root = e.schema.mutation
+	}
+
+	ex := &execution{
+		uc:      e.uc,
+		doc:     doc,
+		args:    map[*field]map[string]interface{}{},
+		loaders: newLoaders(e.uc),
+	}
+	if ex.vars, gqlErr = e.schema.coerceVariables(op, req.Variables); gqlErr != nil {
+		return &Response{Errors: []*Error{gqlErr}}
+	}
+
+	v := &validator{ex: ex, schema: e.schema}
+	complexity, depth := v.selectionSet(root, op.selections, nil)
+	if len(v.errors) > 0 {
+		return &Response{Errors: v.errors}
+	}
+	if e.limits.MaxDepth > 0 && depth > e.limits.MaxDepth {
+		return &Response{Errors: []*Error{withCode(&Error{
+			Message: fmt.Sprintf("Query depth %d exceeds the maximum of %d.", depth, e.limits.MaxDepth),
+		}, CodeTooComplex)}}
+	}
+	if e.limits.MaxComplexity > 0 && complexity > e.limits.MaxComplexity {
+		return &Response{Errors: []*Error{withCode(&Error{
+			Message: fmt.Sprintf("Query complexity %d exceeds the maximum of %d.", complexity, e.limits.MaxComplexity),
+		}, CodeTooComplex)}}
+	}
+
+	data := &object{}
+	ex.executeSelections(ctx, root, []*objectResult{{src: rootValue{}, out: data}}, op.selections)
+	return &Response{Data: data, Errors: ex.errors}
+}
+
+func (d *document) operation(name string) (*operation, *Error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, withCode(&Error{Message: "Must provide operation name if query contains multiple operations."}, CodeValidationFailed)
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, withCode(&Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}, CodeValidationFailed)
+}
+
+// coerceVariables checks the variables of the request against the
+// definitions of op.
+func (s *Schema) coerceVariables(op *operation, raw json.RawMessage) (*variables, *Error) {
+	provided := map[string]interface{}{}
+	if len(raw) > 0 && string(raw) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&provided); err != nil {
+			return nil, withCode(&Error{Message: "Variables must be a JSON object."}, CodeBadUserInput)
+		}
+	}
+
+	vars := &variables{
+		types:      map[string]*typeRef{},
+		values:     map[string]interface{}{},
+		hasDefault: map[string]bool{},
+	}
+	for _, vd := range op.vars {
+		t, err := s.inputType(vd.typ)
+		if err != nil {
+			return nil, validationError(vd.loc, "Variable \"$%s\" %v.", vd.name, err)
+		}
+		vars.types[vd.name] = t
+
+		val, ok := provided[vd.name]
+		switch {
+		case ok:
+			if vars.values[vd.name], err = coerceValue(t, val); err != nil {
+				return nil, withCode(&Error{
+					Message:   fmt.Sprintf("Variable \"$%s\" got invalid value %s; %v.", vd.name, jsonString(val), err),
+					Locations: []Location{vd.loc},
+				}, CodeBadUserInput)
+			}
+		case vd.def != nil:
+			vars.hasDefault[vd.name] = true
+			if vars.values[vd.name], err = coerceLiteral(t, vd.def, nil); err != nil {
+				return nil, validationError(vd.loc, "Variable \"$%s\" has invalid default value: %v.", vd.name, err)
+			}
+		case t.nonNull:
+			return nil, withCode(&Error{
+				Message:   fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", vd.name, t),
+				Locations: []Location{vd.loc},
+			}, CodeBadUserInput)
+		}
+	}
+	return vars, nil
+}
+
+func (s *Schema) inputType(t *astType) (*typeRef, error) {
+	if t.elem != nil {
+		elem, err := s.inputType(t.elem)
+		if err != nil {
+			return nil, err
+		}
+		return &typeRef{elem: elem, nonNull: t.nonNull}, nil
+	}
+	nt := s.lookup(t.name)
+	if nt == nil {
+		return nil, fmt.Errorf("has unknown type %q", t.name)
+	}
+	if nt.kind == kindObject {
+		return nil, fmt.Errorf("cannot be non-input type %q", t)
+	}
+	return &typeRef{named: nt, nonNull: t.nonNull}, nil
+}
+
+// validator checks the selected operation against the schema and
+// measures it. Arguments are coerced on the way and kept for execution.
+type validator struct {
+	ex     *execution
+	schema *Schema
+	errors []*Error
+}
+
+func (v *validator) fail(e *Error) {
+	for _, prev := range v.errors {
+		if prev.Message == e.Message && slices.Equal(prev.Locations, e.Locations) {
+			return
+		}
+	}
+	v.errors = append(v.errors, e)
+}
+
+// selectionSet returns the complexity and the depth of sels on t. spreads
+// are the fragments being expanded, to catch fragments spreading
+// themselves.
+func (v *validator) selectionSet(t *namedType, sels []selection, spreads []string) (complexity, depth int) {
+	for _, sel := range sels {
+		include, err := v.ex.include(directivesOf(sel))
+		if err != nil {
+			v.fail(err)
+			continue
+		}
+		if !include {
+			continue
+		}
+
+		var c, d int
+		switch s := sel.(type) {
+		case *field:
+			c, d = v.field(t, s, spreads)
+		case *fragmentSpread:
+			frag := v.ex.doc.fragments[s.name]
+			if frag == nil {
+				v.fail(validationError(s.loc, "Unknown fragment %q.", s.name))
+				continue
+			}
+			if slices.Contains(spreads, s.name) {
+				v.fail(validationError(s.loc, "Cannot spread fragment %q within itself.", s.name))
+				continue
+			}
+			if !v.typeCondition(t, frag.on, s.loc) {
+				continue
+			}
+			c, d = v.selectionSet(t, frag.selections, append(spreads[:len(spreads):len(spreads)], s.name))
+		case *inlineFragment:
+			if s.on != "" && !v.typeCondition(t, s.on, s.loc) {
+				continue
+			}
+			c, d = v.selectionSet(t, s.selections, spreads)
+		}
+		complexity = addCapped(complexity, c)
+		depth = max(depth, d)
+	}
+
+	v.checkMerge(t, sels)
+	return complexity, depth
+}
+
+func (v *validator) typeCondition(t *namedType, on string, loc Location) bool {
+	nt := v.schema.lookup(on)
+	switch {
+	case nt == nil:
+		v.fail(validationError(loc, "Unknown type %q.", on))
+		return false
+	case nt != t:
+		v.fail(validationError(loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", t.name, on))
+		return false
+	}
+	return true
+}
+
+func (v *validator) field(t *namedType, f *field, spreads []string) (complexity, depth int) {
+	if f.name == "__typename" {
+		if len(f.args) > 0 || f.selections != nil {
+			v.fail(validationError(f.loc, "Field \"__typename\" takes no arguments and has no subfields."))
+		}
+		return 0, 1
+	}
+
+	fd := t.field(f.name)
+	if fd == nil {
+		v.fail(validationError(f.loc, "Cannot query field %q on type %q.", f.name, t.name))
+		return 0, 0
+	}
+	args := v.arguments(t, fd, f)
+	v.ex.args[f] = args
+
+	inner := fd.typ.innermost()
+	if inner.isLeaf() {
+		if f.selections != nil {
+			v.fail(validationError(f.loc, "Field %q must not have a selection since type %q has no subfields.", f.name, fd.typ))
+		}
+		return 1, 1
+	}
+	if f.selections == nil {
+		v.fail(validationError(f.loc, "Field %q of type %q must have a selection of subfields.", f.name, fd.typ))
+		return 1, 1
+	}
+
+	c, d := v.selectionSet(inner, f.selections, spreads)
+	if limit, ok := args["limit"].(int); ok && fd.typ.elem != nil {
+		c = mulCapped(c, limit)
+	}
+	return addCapped(1, c), d + 1
+}
+
+func (v *validator) arguments(t *namedType, fd *fieldDef, f *field) map[string]interface{} {
+	for _, a := range f.args {
+		if fd.arg(a.name) == nil {
+			v.fail(validationError(a.loc, "Unknown argument %q on field \"%s.%s\".", a.name, t.name, fd.name))
+		}
+	}
+
+	args := map[string]interface{}{}
+	for _, ad := range fd.args {
+		var a *argument
+		for _, fa := range f.args {
+			if fa.name == ad.name {
+				a = fa
+			}
+		}
+		// A variable that was declared but not given leaves the argument
+		// unset, so that its default applies.
+		if a != nil && a.value.kind == valVariable && v.ex.vars.types[a.value.raw] != nil && !v.ex.vars.provided(a.value.raw) {
+			a = nil
+		}
+
+		var val interface{}
+		var err error
+		switch {
+		case a != nil:
+			val, err = coerceLiteral(ad.typ, a.value, v.ex.vars)
+		case ad.def != nil:
+			val, err = coerceLiteral(ad.typ, ad.def, nil)
+		case ad.typ.nonNull:
+			v.fail(validationError(f.loc, "Field %q argument %q of type %q is required, but it was not provided.", fd.name, ad.name, ad.typ))
+			continue
+		default:
+			continue
+		}
+		if err == nil && ad.check != nil && val != nil {
+			err = ad.check(val)
+		}
+		if err != nil {
+			loc := f.loc
+			if a != nil {
+				loc = a.loc
+			}
+			v.fail(validationError(loc, "Argument %q has invalid value: %v.", ad.name, err))
+			continue
+		}
+		args[ad.name] = val
+	}
+	return args
+}
+
+// checkMerge rejects selections that put two different fields under one
+// response key.
+func (v *validator) checkMerge(t *namedType, sels []selection) {
+	for _, g := range v.ex.collectFields(t, sels, nil, map[string]bool{}) {
+		first := g.fields[0]
+		for _, f := range g.fields[1:] {
+			switch {
+			case f.name != first.name:
+				v.fail(validationError(f.loc, "Fields %q conflict because %q and %q are different fields. Use different aliases on the fields to fetch both if this was intentional.", g.key, first.name, f.name))
+			case !reflect.DeepEqual(v.ex.args[f], v.ex.args[first]):
+				v.fail(validationError(f.loc, "Fields %q conflict because they have differing arguments. Use different aliases on the fields to fetch both if this was intentional.", g.key))
+			}
+		}
+	}
+}
+
+func directivesOf(sel selection) []*directive {
+	switch s := sel.(type) {
+	case *field:
+		return s.directives
+	case *fragmentSpread:
+		return s.directives
+	case *inlineFragment:
+		return s.directives
+	}
+	return nil
+}
+
+// include evaluates @skip and @include.
+func (ex *execution) include(dirs []*directive) (bool, *Error) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			return false, validationError(d.loc, "Unknown directive \"@%s\".", d.name)
+		}
+		if len(d.args) != 1 || d.args[0].name != "if" {
+			return false, validationError(d.loc, "Directive \"@%s\" takes one argument \"if\" of type \"Boolean!\".", d.name)
+		}
+		val, err := coerceLiteral(required(booleanType), d.args[0].value, ex.vars)
+		if err != nil {
+			return false, validationError(d.loc, "Directive \"@%s\" argument \"if\" has invalid value: %v.", d.name, err)
+		}
+		if val.(bool) == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+func (g *fieldGroup) selections() []selection {
+	var sels []selection
+	for _, f := range g.fields {
+		sels = append(sels, f.selections...)
+	}
+	return sels
+}
+
+// collectFields groups the fields of sels on t by response key, in the
+// order they appear, expanding fragments.
+func (ex *execution) collectFields(t *namedType, sels []selection, groups []*fieldGroup, visited map[string]bool) []*fieldGroup {
+	for _, sel := range sels {
+		if ok, _ := ex.include(directivesOf(sel)); !ok {
+			continue
+		}
+		switch s := sel.(type) {
+		case *field:
+			i := slices.IndexFunc(groups, func(g *fieldGroup) bool { return g.key == s.responseKey() })
+			if i < 0 {
+				groups = append(groups, &fieldGroup{key: s.responseKey()})
+				i = len(groups) - 1
+			}
+			groups[i].fields = append(groups[i].fields, s)
+		case *fragmentSpread:
+			frag := ex.doc.fragments[s.name]
+			if visited[s.name] || frag == nil || frag.on != t.name {
+				continue
+			}
+			visited[s.name] = true
+			groups = ex.collectFields(t, frag.selections, groups, visited)
+		case *inlineFragment:
+			if s.on != "" && s.on != t.name {
+				continue
+			}
+			groups = ex.collectFields(t, s.selections, groups, visited)
+		}
+	}
+	return groups
+}
+
+// object is a JSON object that keeps the order of the selection set.
+type object struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *object) set(key string, v interface{}) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, v)
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		b.Write(key)
+		b.WriteByte(':')
+		val, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type objectResult struct {
+	src  interface{}
+	out  *object
+	path []interface{}
+}
+
+// executeSelections resolves sels for all items at once, breadth first:
+// each field is resolved for every item before the next field, and the
+// objects it returns are completed together. A field under a list thus
+// costs one resolver call however long the list is.
+func (ex *execution) executeSelections(ctx context.Context, t *namedType, items []*objectResult, sels []selection) {
+	for _, g := range ex.collectFields(t, sels, nil, map[string]bool{}) {
+		f := g.fields[0]
+		if f.name == "__typename" {
+			for _, it := range items {
+				it.out.set(g.key, t.name)
+			}
+			continue
+		}
+
+		fd := t.field(f.name)
+		sources := make([]interface{}, len(items))
+		for i, it := range items {
+			sources[i] = it.src
+		}
+		results := fd.resolve(ctx, ex, sources, ex.args[f])
+
+		var ok []int
+		var values []interface{}
+		var paths [][]interface{}
+		for i, r := range results {
+			path := appendPath(items[i].path, g.key)
+			if r.err != nil {
+				ex.errors = append(ex.errors, fieldError(ctx, r.err, f.loc, path))
+				continue
+			}
+			ok = append(ok, i)
+			values = append(values, r.value)
+			paths = append(paths, path)
+		}
+
+		completed := ex.complete(ctx, fd.typ, values, paths, g)
+		out := make([]interface{}, len(items))
+		for j, i := range ok {
+			out[i] = completed[j]
+		}
+		for i, it := range items {
+			it.out.set(g.key, out[i])
+		}
+	}
+}
+
+// complete turns resolved values of type t into JSON values. Null does not
+// propagate to the parent: fields that can fail are nullable in the schema,
+// a null in a non-null field is reported and left as null.
+func (ex *execution) complete(ctx context.Context, t *typeRef, values []interface{}, paths [][]interface{}, g *fieldGroup) []interface{} {
+	out := make([]interface{}, len(values))
+	var live []int
+	for i, v := range values {
+		if isNil(v) {
+			if t.nonNull {
+				ex.errors = append(ex.errors, &Error{
+					Message:   fmt.Sprintf("Cannot return null for non-nullable field %q.", g.key),
+					Locations: []Location{g.fields[0].loc},
+					Path:      paths[i],
+				})
+			}
+			continue
+		}
+		live = append(live, i)
+	}
+
+	switch {
+	case t.elem != nil:
+		var items []interface{}
+		var itemPaths [][]interface{}
+		for _, i := range live {
+			list := reflect.ValueOf(values[i])
+			for j := 0; j < list.Len(); j++ {
+				items = append(items, list.Index(j).Interface())
+				itemPaths = append(itemPaths, appendPath(paths[i], j))
+			}
+		}
+		done := ex.complete(ctx, t.elem, items, itemPaths, g)
+		for _, i := range live {
+			n := reflect.ValueOf(values[i]).Len()
+			out[i] = append(make([]interface{}, 0, n), done[:n]...)
+			done = done[n:]
+		}
+	case t.named.kind == kindEnum:
+		for _, i := range live {
+			out[i] = values[i]
+		}
+	case t.named.kind == kindScalar:
+		for _, i := range live {
+			v, err := t.named.serialize(values[i])
+			if err != nil {
+				ex.errors = append(ex.errors, fieldError(ctx, err, g.fields[0].loc, paths[i]))
+				continue
+			}
+			out[i] = v
+		}
+	default:
+		objs := make([]*objectResult, 0, len(live))
+		for _, i := range live {
+			o := &objectResult{src: values[i], out: &object{}, path: paths[i]}
+			objs = append(objs, o)
+			out[i] = o.out
+		}
+		ex.executeSelections(ctx, t.named, objs, g.selections())
+	}
+	return out
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Slice) && rv.IsNil()
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	return append(path[:len(path):len(path)], elem)
+}
+
+func addCapped(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func mulCapped(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"park_db_course/doc"
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository/memory"
+	"park_db_course/internal/usecase"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// countingUsers counts the batch lookups that reach the use case.
+type countingUsers struct {
+	usecase.UserUsecaseI
+	calls int
+}
+
+func (u *countingUsers) GetMany(ctx context.Context, nicknames []string) ([]models.User, error) {
+	u.calls++
+	return u.UserUsecaseI.GetMany(ctx, nicknames)
+}
+
+// newTestExecutor returns an executor over a fresh in-memory store with
+// users alice and bob, forum pirates and thread jolly holding posts
+// alternately written by alice and bob.
+func newTestExecutor(t *testing.T, limits Limits) (*Executor, *countingUsers) {
+	t.Helper()
+
+	s := memory.NewStore()
+	userRepo, forumRepo, threadRepo := memory.NewUserRepo(s), memory.NewForumRepo(s), memory.NewThreadRepo(s)
+	authz := usecase.NewAuthorizer(userRepo, forumRepo, false)
+	users := &countingUsers{UserUsecaseI: usecase.NewUserUsecase(userRepo, bcrypt.MinCost, authz)}
+	uc := Usecases{
+		Users:   users,
+		Forums:  usecase.NewForumUsecase(forumRepo, userRepo, threadRepo, authz),
+		Threads: usecase.NewThreadUsecase(threadRepo, userRepo, authz, true),
+		Posts:   usecase.NewPostUsecase(memory.NewPostRepo(s), authz),
+	}
+
+	ctx := context.Background()
+	for _, u := range []models.User{
+		{Nickname: "alice", Fullname: "Alice", Email: "alice@mail.ru"},
+		{Nickname: "bob", Fullname: "Bob", Email: "bob@mail.ru"},
+	} {
+		if _, _, err := uc.Users.Create(ctx, u, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := uc.Forums.Create(ctx, models.ForumReq{Title: "Pirates", User: "alice", Slug: "pirates"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.Forums.CreateThread(ctx, "pirates", models.ThreadsReq{Title: "Jolly", Author: "alice", Message: "m", Slug: "jolly"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.Threads.AddPosts(ctx, "jolly", []models.PostReq{
+		{Author: "alice", Message: "1"}, {Author: "BOB", Message: "2"}, {Author: "alice", Message: "3"}, {Author: "bob", Message: "4"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return NewExecutor(uc, limits), users
+}
+
+func execute(t *testing.T, e *Executor, query, variables string) (string, []*Error) {
+	t.Helper()
+	resp := e.Execute(context.Background(), Request{Query: query, Variables: json.RawMessage(variables)})
+	if resp.Data == nil {
+		return "", resp.Errors
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), resp.Errors
+}
+
+func TestQuery(t *testing.T) {
+	e, _ := newTestExecutor(t, Limits{})
+
+	cases := []struct {
+		name, query, variables, want string
+	}{
+		{
+			name:  "nested",
+			query: `{ forum(slug: "PIRATES") { title user { nickname } threads { slug author { fullname } } } }`,
+			want:  `{"forum":{"title":"Pirates","user":{"nickname":"alice"},"threads":[{"slug":"jolly","author":{"fullname":"Alice"}}]}}`,
+		},
+		{
+			name:      "variables and defaults",
+			query:     `query Posts($limit: Int = 2, $desc: Boolean!) { thread(slugOrId: "1") { posts(limit: $limit, desc: $desc) { message parent } } }`,
+			variables: `{"desc": true}`,
+			want:      `{"thread":{"posts":[{"message":"4","parent":null},{"message":"3","parent":null}]}}`,
+		},
+		{
+			name: "aliases, fragments and directives",
+			query: `query($skip: Boolean!) { a: user(nickname: "alice") { ...names } b: user(nickname: "bob") { ... on User { nickname } email @skip(if: $skip) __typename } }
+				fragment names on User { nickname fullname }`,
+			variables: `{"skip": true}`,
+			want:      `{"a":{"nickname":"alice","fullname":"Alice"},"b":{"nickname":"bob","__typename":"User"}}`,
+		},
+		{
+			name:  "unknown objects are null",
+			query: `{ forum(slug: "nope") { slug } thread(slugOrId: "nope") { id } post(id: 100) { id } }`,
+			want:  `{"forum":null,"thread":null,"post":null}`,
+		},
+	}
+	for _, c := range cases {
+		data, errs := execute(t, e, c.query, c.variables)
+		if len(errs) > 0 {
+			t.Errorf("%s: errors %v", c.name, errs)
+		}
+		if data != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, data, c.want)
+		}
+	}
+}
+
+func TestReferencesAreBatched(t *testing.T) {
+	e, users := newTestExecutor(t, Limits{})
+
+	data, errs := execute(t, e, `{ thread(slugOrId: "jolly") { author { nickname } posts { author { nickname } thread { forum { user { email } } } } } }`, "")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if strings.Count(data, `"nickname"`) != 5 || strings.Count(data, `"alice@mail.ru"`) != 4 {
+		t.Errorf("unexpected data %s", data)
+	}
+	// thread.author loads alice, the posts load bob in one batch, the
+	// forum owners come from the cache.
+	if users.calls != 2 {
+		t.Errorf("users were fetched %d times, want 2", users.calls)
+	}
+}
+
+func TestMutation(t *testing.T) {
+	e, _ := newTestExecutor(t, Limits{})
+
+	data, errs := execute(t, e, `mutation($v: VoteInput!) { vote(thread: "jolly", vote: $v) { votes } }`, `{"v": {"nickname": "bob", "voice": -1}}`)
+	if len(errs) > 0 || data != `{"vote":{"votes":-1}}` {
+		t.Errorf("got %s %v", data, errs)
+	}
+
+	data, errs = execute(t, e, `mutation { vote(thread: "jolly", vote: {nickname: "carol", voice: 1}) { votes } }`, "")
+	if data != `{"vote":null}` || len(errs) != 1 || errs[0].Extensions["code"] != CodeNotFound || errs[0].Path[0] != "vote" {
+		t.Errorf("got %s %+v", data, errs)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	e, _ := newTestExecutor(t, Limits{MaxDepth: 4, MaxComplexity: 1000})
+
+	cases := []struct {
+		name, query, variables, code, message string
+	}{
+		{"syntax", `{ thread(slugOrId: "jolly") { title }`, "", CodeParseFailed, "Unexpected <EOF>"},
+		{"unknown field", `{ thread(slugOrId: "jolly") { rating } }`, "", CodeValidationFailed, `Cannot query field "rating" on type "Thread"`},
+		{"missing argument", `{ thread { id } }`, "", CodeValidationFailed, `argument "slugOrId" of type "String!" is required`},
+		{"leaf with selection", `{ thread(slugOrId: "jolly") { id { x } } }`, "", CodeValidationFailed, "must not have a selection"},
+		{"object without selection", `{ thread(slugOrId: "jolly") }`, "", CodeValidationFailed, "must have a selection of subfields"},
+		{"limit out of range", `{ thread(slugOrId: "jolly") { posts(limit: 0) { id } } }`, "", CodeValidationFailed, "limit must be between 1 and 10000"},
+		{"bad voice", `mutation { vote(thread: "jolly", vote: {nickname: "bob", voice: 2}) { id } }`, "", CodeValidationFailed, "voice must be 1 or -1"},
+		{"fragment cycle", `{ user(nickname: "bob") { ...a } } fragment a on User { ...a }`, "", CodeValidationFailed, `Cannot spread fragment "a" within itself`},
+		{"wrong fragment type", `{ user(nickname: "bob") { ...f } } fragment f on Post { id }`, "", CodeValidationFailed, "can never be of type"},
+		{"variable type", `query($id: String!) { post(id: $id) { id } }`, `{"id": "1"}`, CodeValidationFailed, "used in position expecting type"},
+		{"missing variable", `query($t: String!) { thread(slugOrId: $t) { id } }`, "", CodeBadUserInput, "was not provided"},
+		{"invalid variable", `query($l: Int) { thread(slugOrId: "jolly") { posts(limit: $l) { id } } }`, `{"l": "ten"}`, CodeBadUserInput, `got invalid value "ten"`},
+		{"conflicting aliases", `{ user(nickname: "bob") { x: nickname x: email } }`, "", CodeValidationFailed, `Fields "x" conflict`},
+		{"too deep", `{ thread(slugOrId: "jolly") { posts { thread { forum { user { nickname } } } } } }`, "", CodeTooComplex, "depth 6 exceeds the maximum of 4"},
+		{"too complex", `{ thread(slugOrId: "jolly") { posts(limit: 500) { id message } } }`, "", CodeTooComplex, "complexity 1002 exceeds the maximum of 1000"},
+	}
+	for _, c := range cases {
+		data, errs := execute(t, e, c.query, c.variables)
+		if data != "" {
+			t.Errorf("%s: executed with data %s", c.name, data)
+		}
+		if len(errs) == 0 {
+			t.Errorf("%s: no errors", c.name)
+			continue
+		}
+		if errs[0].Extensions["code"] != c.code || !strings.Contains(errs[0].Message, c.message) {
+			t.Errorf("%s: got %s %q, want %s %q", c.name, errs[0].Extensions["code"], errs[0].Message, c.code, c.message)
+		}
+	}
+}
+
+func TestSchemaDoc(t *testing.T) {
+	if doc.GraphQLSchema != SDL() {
+		t.Errorf("doc/schema.graphql is out of date, it should be:\n%s", SDL())
+	}
+}
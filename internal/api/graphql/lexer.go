@@ -0,0 +1,222 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	loc   Location
+}
+
+// Location is a position in the query, both counted from 1.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// lexer splits a query into tokens. Commas, whitespace and comments are
+// insignificant in GraphQL and are skipped.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, col: 1}
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n; i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.advance(1)
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	loc := Location{Line: l.line, Column: l.col}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, loc: loc}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.advance(3)
+		return token{kind: tokPunct, value: "...", loc: loc}, nil
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.advance(1)
+		return token{kind: tokPunct, value: string(c), loc: loc}, nil
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.advance(1)
+		}
+		return token{kind: tokName, value: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString(loc)
+		}
+		return l.string(loc)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, &Error{Message: fmt.Sprintf("Syntax Error: Unexpected character %q.", r), Locations: []Location{loc}}
+}
+
+func (l *lexer) number(loc Location) (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.advance(1)
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.advance(1)
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, syntaxError(loc, "Invalid number.")
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokFloat
+		l.advance(1)
+		if digits() == 0 {
+			return token{}, syntaxError(loc, "Invalid number.")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokFloat
+		l.advance(1)
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.advance(1)
+		}
+		if digits() == 0 {
+			return token{}, syntaxError(loc, "Invalid number.")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || l.src[l.pos] == '.') {
+		return token{}, syntaxError(loc, "Invalid number.")
+	}
+	return token{kind: kind, value: l.src[start:l.pos], loc: loc}, nil
+}
+
+func (l *lexer) string(loc Location) (token, error) {
+	l.advance(1)
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.advance(1)
+			return token{kind: tokString, value: b.String(), loc: loc}, nil
+		case c == '\n' || c == '\r':
+			return token{}, syntaxError(loc, "Unterminated string.")
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, syntaxError(loc, "Unterminated string.")
+			}
+			esc := l.src[l.pos+1]
+			if esc == 'u' {
+				if l.pos+6 > len(l.src) {
+					return token{}, syntaxError(loc, "Invalid Unicode escape sequence.")
+				}
+				r, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+				if err != nil {
+					return token{}, syntaxError(loc, "Invalid Unicode escape sequence.")
+				}
+				b.WriteRune(rune(r))
+				l.advance(6)
+				continue
+			}
+			unescaped, ok := map[byte]byte{'"': '"', '\\': '\\', '/': '/', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t'}[esc]
+			if !ok {
+				return token{}, syntaxError(loc, fmt.Sprintf("Invalid character escape sequence: \\%c.", esc))
+			}
+			b.WriteByte(unescaped)
+			l.advance(2)
+		default:
+			b.WriteByte(c)
+			l.advance(1)
+		}
+	}
+	return token{}, syntaxError(loc, "Unterminated string.")
+}
+
+// blockString reads a """triple quoted""" string. Common indentation is
+// not stripped, block strings are only expected in descriptions and
+// comments of hand written queries.
+func (l *lexer) blockString(loc Location) (token, error) {
+	l.advance(3)
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		switch rest := l.src[l.pos:]; {
+		case strings.HasPrefix(rest, `\"""`):
+			b.WriteString(`"""`)
+			l.advance(4)
+		case strings.HasPrefix(rest, `"""`):
+			l.advance(3)
+			return token{kind: tokString, value: b.String(), loc: loc}, nil
+		default:
+			b.WriteByte(rest[0])
+			l.advance(1)
+		}
+	}
+	return token{}, syntaxError(loc, "Unterminated string.")
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func syntaxError(loc Location, message string) *Error {
+	return &Error{Message: "Syntax Error: " + message, Locations: []Location{loc}}
+}
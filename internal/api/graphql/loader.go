@@ -0,0 +1,85 @@
+package graphql
+
+import (
+	"context"
+	"strings"
+
+	"park_db_course/internal/models"
+)
+
+// loader batches lookups by key and caches them for one request, like the
+// dataloader of graphql-js. Batches are not collected over a tick: the
+// executor resolves a field for every source object at once, so the keys
+// of a batch are all known when loadMany is called.
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) ([]V, error)
+	key   func(v *V) K
+	// norm maps keys that find the same row to one cache entry, e.g. case
+	// insensitive nicknames. Nil keeps keys as they are.
+	norm  func(k K) K
+	cache map[K]*V
+}
+
+func newLoader[K comparable, V any](fetch func(context.Context, []K) ([]V, error), key func(*V) K, norm func(K) K) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, key: key, norm: norm, cache: map[K]*V{}}
+}
+
+func (l *loader[K, V]) normalize(k K) K {
+	if l.norm == nil {
+		return k
+	}
+	return l.norm(k)
+}
+
+// loadMany returns the values of keys in order, nil where nothing was
+// found. Only keys missing from the cache are fetched, with one call.
+func (l *loader[K, V]) loadMany(ctx context.Context, keys []K) ([]*V, error) {
+	var missing []K
+	seen := map[K]bool{}
+	for _, k := range keys {
+		k = l.normalize(k)
+		if _, ok := l.cache[k]; !ok && !seen[k] {
+			seen[k] = true
+			missing = append(missing, k)
+		}
+	}
+
+	if len(missing) > 0 {
+		found, err := l.fetch(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range missing {
+			l.cache[k] = nil
+		}
+		for _, v := range found {
+			l.prime(v)
+		}
+	}
+
+	res := make([]*V, len(keys))
+	for i, k := range keys {
+		res[i] = l.cache[l.normalize(k)]
+	}
+	return res, nil
+}
+
+// prime stores a value loaded by other means, e.g. the threads of a
+// forum listing, so that references to it are not fetched again.
+func (l *loader[K, V]) prime(v V) {
+	l.cache[l.normalize(l.key(&v))] = &v
+}
+
+type loaders struct {
+	users   *loader[string, models.User]
+	forums  *loader[string, models.Forum]
+	threads *loader[int, models.Thread]
+}
+
+func newLoaders(uc Usecases) *loaders {
+	return &loaders{
+		users:   newLoader(uc.Users.GetMany, func(u *models.User) string { return u.Nickname }, strings.ToLower),
+		forums:  newLoader(uc.Forums.GetMany, func(f *models.Forum) string { return f.Slug }, strings.ToLower),
+		threads: newLoader(uc.Threads.GetMany, func(t *models.Thread) int { return t.Id }, nil),
+	}
+}
@@ -0,0 +1,524 @@
+package graphql
+
+import "fmt"
+
+// The AST of an executable document: operations and fragments. Type
+// system definitions (SDL) are not accepted in requests.
+
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // query or mutation
+	name       string
+	vars       []*varDef
+	selections []selection
+	loc        Location
+}
+
+type varDef struct {
+	name string
+	typ  *astType
+	def  *value
+	loc  Location
+}
+
+// astType is a type reference as written in a variable definition.
+type astType struct {
+	name    string
+	elem    *astType // list of elem when set
+	nonNull bool
+}
+
+func (t *astType) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+type fragment struct {
+	name       string
+	on         string
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+// selection is one of *field, *fragmentSpread or *inlineFragment.
+type selection interface {
+	location() Location
+}
+
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	on         string
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+func (f *field) location() Location          { return f.loc }
+func (f *fragmentSpread) location() Location { return f.loc }
+func (f *inlineFragment) location() Location { return f.loc }
+
+type directive struct {
+	name string
+	args []*argument
+	loc  Location
+}
+
+type argument struct {
+	name  string
+	value *value
+	loc   Location
+}
+
+type valueKind int
+
+const (
+	valVariable valueKind = iota
+	valInt
+	valFloat
+	valString
+	valBoolean
+	valNull
+	valEnum
+	valList
+	valObject
+)
+
+type value struct {
+	kind   valueKind
+	raw    string // variable name, literal or enum name
+	list   []*value
+	fields []*argument // object fields
+	loc    Location
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// parse reads a query document.
+func parse(src string) (*document, error) {
+	p := &parser{lex: newLexer(src)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek("{") || p.peekName("query") || p.peekName("mutation"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peekName("fragment"):
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, &Error{Message: fmt.Sprintf("There can be only one fragment named %q.", f.name), Locations: []Location{f.loc}}
+			}
+			doc.fragments[f.name] = f
+		case p.peekName("subscription"):
+			return nil, &Error{Message: "Subscriptions are not supported.", Locations: []Location{p.tok.loc}}
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "The document does not contain an operation."}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.value == punct
+}
+
+func (p *parser) peekName(name string) bool {
+	return p.tok.kind == tokName && p.tok.value == name
+}
+
+func (p *parser) unexpected() error {
+	what := fmt.Sprintf("%q", p.tok.value)
+	if p.tok.kind == tokEOF {
+		what = "<EOF>"
+	}
+	return syntaxError(p.tok.loc, "Unexpected "+what+".")
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		if p.tok.kind == tokEOF {
+			return syntaxError(p.tok.loc, fmt.Sprintf("Expected %q, found <EOF>.", punct))
+		}
+		return syntaxError(p.tok.loc, fmt.Sprintf("Expected %q, found %q.", punct, p.tok.value))
+	}
+	return p.advance()
+}
+
+// skip advances past punct if it is the current token.
+func (p *parser) skip(punct string) (bool, error) {
+	if !p.peek(punct) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected()
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: "query", loc: p.tok.loc}
+	if p.peek("{") {
+		sels, err := p.selectionSet()
+		op.selections = sels
+		return op, err
+	}
+
+	op.kind = p.tok.value
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName {
+		op.name = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peek(")") {
+			v, err := p.varDef()
+			if err != nil {
+				return nil, err
+			}
+			op.vars = append(op.vars, v)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	op.selections, err = p.selectionSet()
+	return op, err
+}
+
+func (p *parser) varDef() (*varDef, error) {
+	v := &varDef{loc: p.tok.loc}
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	var err error
+	if v.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	if v.typ, err = p.typeRef(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip("="); err != nil {
+		return nil, err
+	} else if ok {
+		if v.def, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (p *parser) typeRef() (*astType, error) {
+	t := &astType{}
+	if ok, err := p.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		if t.elem, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else if t.name, err = p.name(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	t.nonNull, err = p.skip("!")
+	return t, err
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	f := &fragment{loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.name == "on" {
+		return nil, syntaxError(f.loc, `Unexpected Name "on".`)
+	}
+	if !p.peekName("on") {
+		return nil, p.unexpected()
+	}
+	if err = p.advance(); err != nil {
+		return nil, err
+	}
+	if f.on, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	f.selections, err = p.selectionSet()
+	return f, err
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []selection
+	for !p.peek("}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, syntaxError(p.tok.loc, "Selection set must not be empty.")
+	}
+	return sels, p.advance()
+}
+
+func (p *parser) selection() (selection, error) {
+	loc := p.tok.loc
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.kind == tokName && p.tok.value != "on" {
+			spread := &fragmentSpread{name: p.tok.value, loc: loc}
+			if err = p.advance(); err != nil {
+				return nil, err
+			}
+			spread.directives, err = p.directives()
+			return spread, err
+		}
+
+		inline := &inlineFragment{loc: loc}
+		if p.peekName("on") {
+			if err = p.advance(); err != nil {
+				return nil, err
+			}
+			if inline.on, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if inline.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		inline.selections, err = p.selectionSet()
+		return inline, err
+	}
+
+	f := &field{loc: loc}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = f.name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.args, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		f.selections, err = p.selectionSet()
+	}
+	return f, err
+}
+
+func (p *parser) arguments(constant bool) ([]*argument, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+	var args []*argument
+	for !p.peek(")") {
+		arg, err := p.argument(constant)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range args {
+			if a.name == arg.name {
+				return nil, &Error{Message: fmt.Sprintf("There can be only one argument named %q.", arg.name), Locations: []Location{arg.loc}}
+			}
+		}
+		args = append(args, arg)
+	}
+	return args, p.advance()
+}
+
+func (p *parser) argument(constant bool) (*argument, error) {
+	arg := &argument{loc: p.tok.loc}
+	var err error
+	if arg.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	arg.value, err = p.value(constant)
+	return arg, err
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var dirs []*directive
+	for p.peek("@") {
+		d := &directive{loc: p.tok.loc}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		if d.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if d.args, err = p.arguments(false); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// value reads a literal. Variables are not allowed in constant values,
+// i.e. variable defaults.
+func (p *parser) value(constant bool) (*value, error) {
+	v := &value{loc: p.tok.loc, raw: p.tok.value}
+	switch p.tok.kind {
+	case tokInt:
+		v.kind = valInt
+	case tokFloat:
+		v.kind = valFloat
+	case tokString:
+		v.kind = valString
+	case tokName:
+		switch p.tok.value {
+		case "true", "false":
+			v.kind = valBoolean
+		case "null":
+			v.kind = valNull
+		default:
+			v.kind = valEnum
+		}
+	case tokPunct:
+		switch p.tok.value {
+		case "$":
+			if constant {
+				return nil, p.unexpected()
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			var err error
+			v.kind = valVariable
+			v.raw, err = p.name()
+			return v, err
+		case "[":
+			v.kind = valList
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			for !p.peek("]") {
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				v.list = append(v.list, item)
+			}
+			return v, p.advance()
+		case "{":
+			v.kind = valObject
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			for !p.peek("}") {
+				f, err := p.argument(constant)
+				if err != nil {
+					return nil, err
+				}
+				v.fields = append(v.fields, f)
+			}
+			return v, p.advance()
+		default:
+			return nil, p.unexpected()
+		}
+	default:
+		return nil, p.unexpected()
+	}
+	return v, p.advance()
+}
@@ -0,0 +1,349 @@
+// Package graphql serves nested reads of the forum in one round trip, e.g.
+// a thread with its first posts and their authors, see doc/schema.graphql.
+//
+// The executor is small and specific to this schema: queries and
+// mutations over object types, fragments, variables, @skip and @include,
+// no introspection besides __typename. It resolves queries breadth first,
+// so the authors of a hundred posts are loaded with one query, and it
+// rejects queries over the depth and complexity limits before running
+// them.
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/usecase"
+)
+
+// Usecases is everything the resolvers call.
+type Usecases struct {
+	Users   usecase.UserUsecaseI
+	Forums  usecase.ForumUsecaseI
+	Threads usecase.ThreadUsecaseI
+	Posts   usecase.PostUsecaseI
+}
+
+// listing limits of swagger.yml
+const (
+	defaultLimit = 100
+	maxLimit     = 10000
+)
+
+func newSchema() *Schema {
+	user := &namedType{name: "User", kind: kindObject, description: "A forum member."}
+	forum := &namedType{name: "Forum", kind: kindObject, description: "A forum, a set of threads."}
+	thread := &namedType{name: "Thread", kind: kindObject, description: "A thread of a forum."}
+	post := &namedType{name: "Post", kind: kindObject, description: "A message in a thread."}
+	postSort := &namedType{
+		name:        "PostSort",
+		kind:        kindEnum,
+		description: "Order of the posts of a thread, as the sort parameter of GET /thread/{slug_or_id}/posts.",
+		enumValues:  []string{"FLAT", "TREE", "PARENT_TREE"},
+	}
+	voteInput := &namedType{
+		name:        "VoteInput",
+		kind:        kindInputObject,
+		description: "A vote of a user for a thread.",
+		inputs: []*argDef{
+			{name: "nickname", typ: required(stringType)},
+			{name: "voice", typ: required(intType), description: "1 or -1.", check: checkVoice},
+		},
+	}
+
+	user.fields = []*fieldDef{
+		{name: "nickname", typ: required(stringType), resolve: prop(func(u *models.User) interface{} { return u.Nickname })},
+		{name: "fullname", typ: required(stringType), resolve: prop(func(u *models.User) interface{} { return u.Fullname })},
+		{name: "about", typ: required(stringType), resolve: prop(func(u *models.User) interface{} { return u.About })},
+		{name: "email", typ: required(stringType), resolve: prop(func(u *models.User) interface{} { return u.Email })},
+	}
+
+	forum.fields = []*fieldDef{
+		{name: "slug", typ: required(stringType), resolve: prop(func(f *models.Forum) interface{} { return f.Slug })},
+		{name: "title", typ: required(stringType), resolve: prop(func(f *models.Forum) interface{} { return f.Title })},
+		{name: "user", typ: named(user), description: "The owner.",
+			resolve: load(pickUsers, func(f *models.Forum) string { return f.User })},
+		{name: "postCount", typ: required(intType), resolve: prop(func(f *models.Forum) interface{} { return f.Posts })},
+		{name: "threadCount", typ: required(intType), resolve: prop(func(f *models.Forum) interface{} { return f.Threads })},
+		{
+			name:        "threads",
+			description: "Threads by creation time, as GET /forum/{slug}/threads.",
+			typ:         listOf(nonNull(named(thread))),
+			args: []*argDef{
+				limitArg(),
+				{name: "since", typ: named(dateTimeType), description: "Threads created at or after since, at or before with desc."},
+				descArg(),
+			},
+			resolve: each(func(ctx context.Context, ex *execution, f *models.Forum, args map[string]interface{}) (interface{}, error) {
+				var since string
+				if t, ok := args["since"].(time.Time); ok {
+					since = t.Format(time.RFC3339Nano)
+				}
+				threads, err := ex.uc.Forums.Threads(ctx, f.Slug, since, args["limit"].(int), args["desc"].(bool))
+				for _, t := range threads {
+					ex.loaders.threads.prime(t)
+				}
+				return pointers(threads), err
+			}),
+		},
+		{
+			name:        "users",
+			description: "Users that opened a thread or posted in the forum, by nickname, as GET /forum/{slug}/users.",
+			typ:         listOf(nonNull(named(user))),
+			args: []*argDef{
+				limitArg(),
+				{name: "since", typ: named(stringType), description: "Nicknames after since, before with desc."},
+				descArg(),
+			},
+			resolve: each(func(ctx context.Context, ex *execution, f *models.Forum, args map[string]interface{}) (interface{}, error) {
+				since, _ := args["since"].(string)
+				users, err := ex.uc.Forums.Users(ctx, f.Slug, since, args["limit"].(int), args["desc"].(bool))
+				for _, u := range users {
+					ex.loaders.users.prime(u)
+				}
+				return pointers(users), err
+			}),
+		},
+	}
+
+	thread.fields = []*fieldDef{
+		{name: "id", typ: required(intType), resolve: prop(func(t *models.Thread) interface{} { return t.Id })},
+		{name: "slug", typ: named(stringType), resolve: prop(func(t *models.Thread) interface{} {
+			if t.Slug == "" {
+				return nil
+			}
+			return t.Slug
+		})},
+		{name: "title", typ: required(stringType), resolve: prop(func(t *models.Thread) interface{} { return t.Title })},
+		{name: "message", typ: required(stringType), resolve: prop(func(t *models.Thread) interface{} { return t.Message })},
+		{name: "votes", typ: required(intType), resolve: prop(func(t *models.Thread) interface{} { return t.Votes })},
+		{name: "isClosed", typ: required(booleanType), description: "True for a closed thread, it takes no new posts.",
+			resolve: prop(func(t *models.Thread) interface{} { return t.IsClosed })},
+		{name: "isPinned", typ: required(booleanType), description: "True for a pinned thread, listed first in its forum.",
+			resolve: prop(func(t *models.Thread) interface{} { return t.IsPinned })},
+		{name: "created", typ: required(dateTimeType), resolve: prop(func(t *models.Thread) interface{} { return t.Created })},
+		{name: "author", typ: named(user), resolve: load(pickUsers, func(t *models.Thread) string { return t.Author })},
+		{name: "forum", typ: named(forum), resolve: load(pickForums, func(t *models.Thread) string { return t.Forum })},
+		{
+			name:        "posts",
+			description: "Posts of the thread, as GET /thread/{slug_or_id}/posts.",
+			typ:         listOf(nonNull(named(post))),
+			args: []*argDef{
+				limitArg(),
+				{name: "since", typ: named(intType), description: "Posts after the post with this id, before with desc."},
+				{name: "sort", typ: required(postSort), def: &value{kind: valEnum, raw: "FLAT"}},
+				descArg(),
+			},
+			resolve: each(func(ctx context.Context, ex *execution, t *models.Thread, args map[string]interface{}) (interface{}, error) {
+				var since string
+				if id, ok := args["since"].(int); ok {
+					since = strconv.Itoa(id)
+				}
+				sort := strings.ToLower(args["sort"].(string))
+				posts, err := ex.uc.Threads.Posts(ctx, strconv.Itoa(t.Id), since, sort, args["limit"].(int), args["desc"].(bool))
+				return pointers(posts), err
+			}),
+		},
+	}
+
+	post.fields = []*fieldDef{
+		{name: "id", typ: required(intType), resolve: prop(func(p *models.Post) interface{} { return p.Id })},
+		{name: "parent", typ: named(intType), description: "Id of the post this one answers, null for top level posts.",
+			resolve: prop(func(p *models.Post) interface{} {
+				if p.Parent == 0 {
+					return nil
+				}
+				return p.Parent
+			})},
+		{name: "message", typ: required(stringType), resolve: prop(func(p *models.Post) interface{} { return p.Message })},
+		{name: "isEdited", typ: required(booleanType), resolve: prop(func(p *models.Post) interface{} { return p.IsEdited })},
+		{name: "isDeleted", typ: required(booleanType), description: "True for the placeholder of a deleted post, its message is empty and its author may be null.",
+			resolve: prop(func(p *models.Post) interface{} { return p.IsDeleted })},
+		{name: "created", typ: required(dateTimeType), resolve: prop(func(p *models.Post) interface{} { return p.Created })},
+		{name: "author", typ: named(user), resolve: load(pickUsers, func(p *models.Post) string { return p.Author })},
+		{name: "forum", typ: named(forum), resolve: load(pickForums, func(p *models.Post) string { return p.Forum })},
+		{name: "thread", typ: named(thread), resolve: load(pickThreads, func(p *models.Post) int { return int(p.Thread) })},
+	}
+
+	query := &namedType{name: "Query", kind: kindObject, fields: []*fieldDef{
+		{
+			name:        "forum",
+			description: "The forum with this slug, null if there is none.",
+			typ:         named(forum),
+			args:        []*argDef{{name: "slug", typ: required(stringType)}},
+			resolve: each(func(ctx context.Context, ex *execution, _ rootValue, args map[string]interface{}) (interface{}, error) {
+				return loadOne(ctx, ex.loaders.forums, args["slug"].(string))
+			}),
+		},
+		{
+			name:        "thread",
+			description: "The thread with this slug or id, null if there is none.",
+			typ:         named(thread),
+			args:        []*argDef{{name: "slugOrId", typ: required(stringType)}},
+			resolve: each(func(ctx context.Context, ex *execution, _ rootValue, args map[string]interface{}) (interface{}, error) {
+				t, err := ex.uc.Threads.Get(ctx, args["slugOrId"].(string))
+				if errors.Is(err, models.ErrNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				ex.loaders.threads.prime(t)
+				return &t, nil
+			}),
+		},
+		{
+			name:        "post",
+			description: "The post with this id, null if there is none.",
+			typ:         named(post),
+			args:        []*argDef{{name: "id", typ: required(intType)}},
+			resolve: each(func(ctx context.Context, ex *execution, _ rootValue, args map[string]interface{}) (interface{}, error) {
+				info, err := ex.uc.Posts.Get(ctx, args["id"].(int), nil)
+				if errors.Is(err, models.ErrNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				return info.Post, nil
+			}),
+		},
+		{
+			name:        "user",
+			description: "The user with this nickname, null if there is none.",
+			typ:         named(user),
+			args:        []*argDef{{name: "nickname", typ: required(stringType)}},
+			resolve: each(func(ctx context.Context, ex *execution, _ rootValue, args map[string]interface{}) (interface{}, error) {
+				return loadOne(ctx, ex.loaders.users, args["nickname"].(string))
+			}),
+		},
+	}}
+
+	mutation := &namedType{name: "Mutation", kind: kindObject, fields: []*fieldDef{
+		{
+			name:        "vote",
+			description: "Votes for the thread with this slug or id, as POST /thread/{slug_or_id}/vote. A second vote of the same user replaces the first.",
+			typ:         named(thread),
+			args: []*argDef{
+				{name: "thread", typ: required(stringType)},
+				{name: "vote", typ: required(voteInput)},
+			},
+			resolve: each(func(ctx context.Context, ex *execution, _ rootValue, args map[string]interface{}) (interface{}, error) {
+				vote := args["vote"].(map[string]interface{})
+				t, err := ex.uc.Threads.Vote(ctx, args["thread"].(string), models.VoteRequest{
+					Nickname: vote["nickname"].(string),
+					Voice:    vote["voice"].(int),
+				})
+				if err != nil {
+					return nil, err
+				}
+				ex.loaders.threads.prime(t)
+				return &t, nil
+			}),
+		},
+	}}
+
+	return &Schema{
+		query:    query,
+		mutation: mutation,
+		types:    []*namedType{query, mutation, forum, thread, post, user, postSort, voteInput, dateTimeType, intType, stringType, booleanType},
+	}
+}
+
+func limitArg() *argDef {
+	return &argDef{
+		name:        "limit",
+		typ:         required(intType),
+		def:         &value{kind: valInt, raw: strconv.Itoa(defaultLimit)},
+		description: "At most this many, from 1 to 10000.",
+		check: func(v interface{}) error {
+			if n := v.(int); n < 1 || n > maxLimit {
+				return fmt.Errorf("limit must be between 1 and %d, got %d", maxLimit, n)
+			}
+			return nil
+		},
+	}
+}
+
+func descArg() *argDef {
+	return &argDef{name: "desc", typ: required(booleanType), def: &value{kind: valBoolean, raw: "false"}}
+}
+
+func checkVoice(v interface{}) error {
+	if n := v.(int); n != 1 && n != -1 {
+		return fmt.Errorf("voice must be 1 or -1, got %d", n)
+	}
+	return nil
+}
+
+// prop resolves a field the source object already holds.
+func prop[S any](get func(S) interface{}) resolveFunc {
+	return func(_ context.Context, _ *execution, sources []interface{}, _ map[string]interface{}) []result {
+		res := make([]result, len(sources))
+		for i, src := range sources {
+			res[i].value = get(src.(S))
+		}
+		return res
+	}
+}
+
+// each resolves a field source by source, for fields that take their own
+// query, e.g. the first posts of each thread.
+func each[S any](resolve func(ctx context.Context, ex *execution, src S, args map[string]interface{}) (interface{}, error)) resolveFunc {
+	return func(ctx context.Context, ex *execution, sources []interface{}, args map[string]interface{}) []result {
+		res := make([]result, len(sources))
+		for i, src := range sources {
+			res[i].value, res[i].err = resolve(ctx, ex, src.(S), args)
+		}
+		return res
+	}
+}
+
+// load resolves a reference to another object through a loader, with one
+// query for all sources.
+func load[S any, K comparable, V any](pick func(*loaders) *loader[K, V], ref func(S) K) resolveFunc {
+	return func(ctx context.Context, ex *execution, sources []interface{}, _ map[string]interface{}) []result {
+		keys := make([]K, len(sources))
+		for i, src := range sources {
+			keys[i] = ref(src.(S))
+		}
+		found, err := pick(ex.loaders).loadMany(ctx, keys)
+
+		res := make([]result, len(sources))
+		for i := range res {
+			switch {
+			case err != nil:
+				res[i].err = err
+			case found[i] != nil:
+				res[i].value = found[i]
+			}
+		}
+		return res
+	}
+}
+
+func pickUsers(l *loaders) *loader[string, models.User]   { return l.users }
+func pickForums(l *loaders) *loader[string, models.Forum] { return l.forums }
+func pickThreads(l *loaders) *loader[int, models.Thread]  { return l.threads }
+
+func loadOne[K comparable, V any](ctx context.Context, l *loader[K, V], key K) (interface{}, error) {
+	found, err := l.loadMany(ctx, []K{key})
+	if err != nil || found[0] == nil {
+		return nil, err
+	}
+	return found[0], nil
+}
+
+// pointers turns a listing into the list value of a field.
+func pointers[T any](items []T) []interface{} {
+	res := make([]interface{}, len(items))
+	for i := range items {
+		res[i] = &items[i]
+	}
+	return res
+}
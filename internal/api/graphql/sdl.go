@@ -0,0 +1,91 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SDL prints the schema in the GraphQL schema definition language. It is
+// kept in doc/schema.graphql for clients, as there is no introspection.
+func SDL() string {
+	return newSchema().sdl()
+}
+
+func (s *Schema) sdl() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "schema {\n  query: %s\n  mutation: %s\n}\n", s.query.name, s.mutation.name)
+	for _, t := range s.types {
+		if t == intType || t == stringType || t == booleanType {
+			continue
+		}
+		b.WriteString("\n")
+		description(&b, "", t.description)
+		switch t.kind {
+		case kindScalar:
+			fmt.Fprintf(&b, "scalar %s\n", t.name)
+		case kindEnum:
+			fmt.Fprintf(&b, "enum %s {\n", t.name)
+			for _, v := range t.enumValues {
+				fmt.Fprintf(&b, "  %s\n", v)
+			}
+			b.WriteString("}\n")
+		case kindInputObject:
+			fmt.Fprintf(&b, "input %s {\n", t.name)
+			for _, a := range t.inputs {
+				description(&b, "  ", a.description)
+				fmt.Fprintf(&b, "  %s\n", inputValue(a))
+			}
+			b.WriteString("}\n")
+		case kindObject:
+			fmt.Fprintf(&b, "type %s {\n", t.name)
+			for _, f := range t.fields {
+				description(&b, "  ", f.description)
+				fmt.Fprintf(&b, "  %s", f.name)
+				arguments(&b, f.args)
+				fmt.Fprintf(&b, ": %s\n", f.typ)
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
+
+// arguments prints the arguments on one line, or one per line when some
+// of them are described.
+func arguments(b *strings.Builder, args []*argDef) {
+	if len(args) == 0 {
+		return
+	}
+	described := false
+	for _, a := range args {
+		described = described || a.description != ""
+	}
+	if !described {
+		list := make([]string, 0, len(args))
+		for _, a := range args {
+			list = append(list, inputValue(a))
+		}
+		fmt.Fprintf(b, "(%s)", strings.Join(list, ", "))
+		return
+	}
+	b.WriteString("(\n")
+	for _, a := range args {
+		description(b, "    ", a.description)
+		fmt.Fprintf(b, "    %s\n", inputValue(a))
+	}
+	b.WriteString("  )")
+}
+
+func description(b *strings.Builder, indent, text string) {
+	if text != "" {
+		fmt.Fprintf(b, "%s%q\n", indent, text)
+	}
+}
+
+func inputValue(a *argDef) string {
+	s := a.name + ": " + a.typ.String()
+	if a.def != nil {
+		s += " = " + a.def.String()
+	}
+	return s
+}
@@ -0,0 +1,441 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type typeKind int
+
+const (
+	kindScalar typeKind = iota
+	kindEnum
+	kindObject
+	kindInputObject
+)
+
+// namedType is a scalar, enum, object or input object of the schema.
+// There are no interfaces and unions: every selection set is on one
+// concrete object type, which keeps the executor simple.
+type namedType struct {
+	name        string
+	kind        typeKind
+	description string
+
+	// scalars: serialize turns a resolved value into JSON, parseValue reads
+	// a variable decoded from JSON and parseLiteral a literal of the query.
+	serialize    func(v interface{}) (interface{}, error)
+	parseValue   func(v interface{}) (interface{}, bool)
+	parseLiteral func(v *value) (interface{}, bool)
+
+	enumValues []string
+	fields     []*fieldDef // objects
+	inputs     []*argDef   // input objects
+}
+
+func (t *namedType) field(name string) *fieldDef {
+	for _, f := range t.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *namedType) isLeaf() bool {
+	return t.kind == kindScalar || t.kind == kindEnum
+}
+
+// typeRef wraps a named type into lists and non-null.
+type typeRef struct {
+	named   *namedType
+	elem    *typeRef
+	nonNull bool
+}
+
+func named(t *namedType) *typeRef    { return &typeRef{named: t} }
+func listOf(t *typeRef) *typeRef     { return &typeRef{elem: t} }
+func nonNull(t *typeRef) *typeRef    { return &typeRef{named: t.named, elem: t.elem, nonNull: true} }
+func required(t *namedType) *typeRef { return nonNull(named(t)) }
+
+// innermost returns the named type under every wrapper.
+func (t *typeRef) innermost() *namedType {
+	for t.elem != nil {
+		t = t.elem
+	}
+	return t.named
+}
+
+func (t *typeRef) String() string {
+	s := ""
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	} else {
+		s = t.named.name
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// result is what a resolver produced for one source object.
+type result struct {
+	value interface{}
+	err   error
+}
+
+// resolveFunc resolves a field for every source object of a selection set
+// at once, so a field of a list of posts costs one call, not one per post.
+type resolveFunc func(ctx context.Context, ex *execution, sources []interface{}, args map[string]interface{}) []result
+
+type fieldDef struct {
+	name        string
+	description string
+	typ         *typeRef
+	args        []*argDef
+	resolve     resolveFunc
+}
+
+func (f *fieldDef) arg(name string) *argDef {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+type argDef struct {
+	name        string
+	description string
+	typ         *typeRef
+	def         *value // default literal, nil for none
+	// check rejects coerced values outside the allowed range.
+	check func(v interface{}) error
+}
+
+// Schema is a set of types with the root operation types.
+type Schema struct {
+	query    *namedType
+	mutation *namedType
+	types    []*namedType
+}
+
+func (s *Schema) lookup(name string) *namedType {
+	for _, t := range s.types {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Built-in scalars.
+var (
+	intType = &namedType{
+		name: "Int",
+		kind: kindScalar,
+		serialize: func(v interface{}) (interface{}, error) {
+			switch n := v.(type) {
+			case int:
+				return n, nil
+			case int32:
+				return n, nil
+			case int64:
+				return n, nil
+			}
+			return nil, fmt.Errorf("Int cannot represent %v", v)
+		},
+		parseValue: func(v interface{}) (interface{}, bool) {
+			n, ok := v.(json.Number)
+			if !ok {
+				return nil, false
+			}
+			i, err := strconv.ParseInt(string(n), 10, 32)
+			return int(i), err == nil
+		},
+		parseLiteral: func(v *value) (interface{}, bool) {
+			if v.kind != valInt {
+				return nil, false
+			}
+			i, err := strconv.ParseInt(v.raw, 10, 32)
+			return int(i), err == nil
+		},
+	}
+	stringType = &namedType{
+		name: "String",
+		kind: kindScalar,
+		serialize: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent %v", v)
+		},
+		parseValue: func(v interface{}) (interface{}, bool) {
+			s, ok := v.(string)
+			return s, ok
+		},
+		parseLiteral: func(v *value) (interface{}, bool) {
+			return v.raw, v.kind == valString
+		},
+	}
+	booleanType = &namedType{
+		name: "Boolean",
+		kind: kindScalar,
+		serialize: func(v interface{}) (interface{}, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %v", v)
+		},
+		parseValue: func(v interface{}) (interface{}, bool) {
+			b, ok := v.(bool)
+			return b, ok
+		},
+		parseLiteral: func(v *value) (interface{}, bool) {
+			return v.raw == "true", v.kind == valBoolean
+		},
+	}
+	// dateTimeType is a timestamp in RFC 3339, the format of the REST API.
+	dateTimeType = &namedType{
+		name:        "DateTime",
+		kind:        kindScalar,
+		description: "A timestamp in RFC 3339 format, e.g. 2019-06-24T15:04:05.000Z.",
+		serialize: func(v interface{}) (interface{}, error) {
+			if t, ok := v.(time.Time); ok {
+				return t.Format(time.RFC3339Nano), nil
+			}
+			return nil, fmt.Errorf("DateTime cannot represent %v", v)
+		},
+		parseValue: func(v interface{}) (interface{}, bool) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			t, err := time.Parse(time.RFC3339Nano, s)
+			return t, err == nil
+		},
+		parseLiteral: func(v *value) (interface{}, bool) {
+			if v.kind != valString {
+				return nil, false
+			}
+			t, err := time.Parse(time.RFC3339Nano, v.raw)
+			return t, err == nil
+		},
+	}
+)
+
+// coerceLiteral turns an argument literal into a Go value of type t.
+// Variables are taken from vars, which are already coerced; defaults are
+// coerced with nil vars.
+func coerceLiteral(t *typeRef, v *value, vars *variables) (interface{}, error) {
+	if v.kind == valVariable {
+		if vars == nil {
+			return nil, fmt.Errorf("unexpected variable \"$%s\"", v.raw)
+		}
+		vt, ok := vars.types[v.raw]
+		if !ok {
+			return nil, fmt.Errorf("variable \"$%s\" is not defined", v.raw)
+		}
+		if !allowedIn(vt, t, vars.hasDefault[v.raw]) {
+			return nil, fmt.Errorf("variable \"$%s\" of type %q used in position expecting type %q", v.raw, vt, t)
+		}
+		val := vars.values[v.raw]
+		if val == nil && t.nonNull {
+			return nil, fmt.Errorf("variable \"$%s\" must not be null", v.raw)
+		}
+		return val, nil
+	}
+	if v.kind == valNull {
+		if t.nonNull {
+			return nil, fmt.Errorf("expected value of type %q, found null", t)
+		}
+		return nil, nil
+	}
+
+	if t.elem != nil {
+		if v.kind != valList {
+			item, err := coerceLiteral(t.elem, v, vars)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		items := make([]interface{}, 0, len(v.list))
+		for _, it := range v.list {
+			item, err := coerceLiteral(t.elem, it, vars)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	switch nt := t.named; nt.kind {
+	case kindScalar:
+		if val, ok := nt.parseLiteral(v); ok {
+			return val, nil
+		}
+	case kindEnum:
+		if v.kind == valEnum && nt.hasEnumValue(v.raw) {
+			return v.raw, nil
+		}
+	case kindInputObject:
+		if v.kind == valObject {
+			fields := map[string]*value{}
+			for _, f := range v.fields {
+				fields[f.name] = f.value
+			}
+			return coerceInput(nt, fields, func(a *argDef, fv *value) (interface{}, error) {
+				return coerceLiteral(a.typ, fv, vars)
+			})
+		}
+	}
+	return nil, fmt.Errorf("expected value of type %q, found %s", t, v)
+}
+
+// coerceValue turns a variable decoded from JSON (numbers as json.Number)
+// into a Go value of type t.
+func coerceValue(t *typeRef, v interface{}) (interface{}, error) {
+	if v == nil {
+		if t.nonNull {
+			return nil, fmt.Errorf("expected value of type %q, found null", t)
+		}
+		return nil, nil
+	}
+
+	if t.elem != nil {
+		list, ok := v.([]interface{})
+		if !ok {
+			item, err := coerceValue(t.elem, v)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		items := make([]interface{}, 0, len(list))
+		for _, it := range list {
+			item, err := coerceValue(t.elem, it)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	switch nt := t.named; nt.kind {
+	case kindScalar:
+		if val, ok := nt.parseValue(v); ok {
+			return val, nil
+		}
+	case kindEnum:
+		if s, ok := v.(string); ok && nt.hasEnumValue(s) {
+			return s, nil
+		}
+	case kindInputObject:
+		if obj, ok := v.(map[string]interface{}); ok {
+			return coerceInput(nt, obj, func(a *argDef, fv interface{}) (interface{}, error) {
+				return coerceValue(a.typ, fv)
+			})
+		}
+	}
+	return nil, fmt.Errorf("expected value of type %q, found %s", t, jsonString(v))
+}
+
+func (t *namedType) hasEnumValue(s string) bool {
+	for _, e := range t.enumValues {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// coerceInput checks the fields of an input object: unknown fields are
+// rejected, missing ones get their default or must be nullable.
+func coerceInput[V any](t *namedType, fields map[string]V, coerce func(*argDef, V) (interface{}, error)) (map[string]interface{}, error) {
+	for name := range fields {
+		if inputField(t, name) == nil {
+			return nil, fmt.Errorf("field %q is not defined by type %q", name, t.name)
+		}
+	}
+	res := make(map[string]interface{}, len(t.inputs))
+	for _, a := range t.inputs {
+		fv, ok := fields[a.name]
+		var err error
+		switch {
+		case ok:
+			res[a.name], err = coerce(a, fv)
+			if err == nil && a.check != nil && res[a.name] != nil {
+				err = a.check(res[a.name])
+			}
+		case a.def != nil:
+			res[a.name], err = coerceLiteral(a.typ, a.def, nil)
+		case a.typ.nonNull:
+			err = fmt.Errorf("field \"%s.%s\" of required type %q was not provided", t.name, a.name, a.typ)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// allowedIn reports whether a variable of type varType may be passed where
+// t is expected. A nullable variable with a default fits a non-null
+// position.
+func allowedIn(varType, t *typeRef, hasDefault bool) bool {
+	if t.nonNull && !varType.nonNull && !hasDefault {
+		return false
+	}
+	if t.elem != nil {
+		return varType.elem != nil && allowedIn(varType.elem, t.elem, false)
+	}
+	return varType.elem == nil && varType.named == t.named
+}
+
+func inputField(t *namedType, name string) *argDef {
+	for _, a := range t.inputs {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func (v *value) String() string {
+	switch v.kind {
+	case valVariable:
+		return "$" + v.raw
+	case valString:
+		return strconv.Quote(v.raw)
+	case valList:
+		items := make([]string, 0, len(v.list))
+		for _, it := range v.list {
+			items = append(items, it.String())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case valObject:
+		fields := make([]string, 0, len(v.fields))
+		for _, f := range v.fields {
+			fields = append(fields, f.name+": "+f.value.String())
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return v.raw
+}
@@ -0,0 +1,61 @@
+package grpc
+
+import (
+	"park_db_course/internal/api/grpc/forumpb"
+	"park_db_course/internal/models"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func userPb(u models.User) *forumpb.User {
+	return &forumpb.User{
+		Nickname: u.Nickname,
+		Fullname: u.Fullname,
+		About:    u.About,
+		Email:    u.Email,
+	}
+}
+
+func forumPb(f models.Forum) *forumpb.Forum {
+	return &forumpb.Forum{
+		Title:   f.Title,
+		User:    f.User,
+		Slug:    f.Slug,
+		Posts:   int64(f.Posts),
+		Threads: int64(f.Threads),
+	}
+}
+
+func threadPb(t models.Thread) *forumpb.Thread {
+	return &forumpb.Thread{
+		Id:      int64(t.Id),
+		Title:   t.Title,
+		Author:  t.Author,
+		Forum:   t.Forum,
+		Message: t.Message,
+		Votes:   int64(t.Votes),
+		Slug:    t.Slug,
+		Created: timestamppb.New(t.Created),
+	}
+}
+
+func postPb(p models.Post) *forumpb.Post {
+	return &forumpb.Post{
+		Id:       p.Id,
+		Parent:   p.Parent,
+		Author:   p.Author,
+		Message:  p.Message,
+		IsEdited: p.IsEdited,
+		Forum:    p.Forum,
+		Thread:   int64(p.Thread),
+		Created:  timestamppb.New(p.Created),
+	}
+}
+
+func postsPb(posts []models.Post) []*forumpb.Post {
+	res := make([]*forumpb.Post, 0, len(posts))
+	for _, p := range posts {
+		res = append(res, postPb(p))
+	}
+	return res
+}
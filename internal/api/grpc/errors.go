@@ -0,0 +1,62 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"park_db_course/internal/logging"
+	"park_db_course/internal/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// statusError is the gRPC counterpart of writeError in the http package:
+// the kind of the domain error decides the code. Errors of no known kind
+// are logged with the logger of ctx and do not leak driver details.
+func statusError(ctx context.Context, err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, models.ErrPostParentNotFound):
+		code = codes.FailedPrecondition
+	case errors.Is(err, models.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, models.ErrConflict):
+		code = codes.AlreadyExists
+	case errors.Is(err, models.ErrValidation):
+		code = codes.InvalidArgument
+	case errors.Is(err, models.ErrUnauthorized):
+		code = codes.Unauthenticated
+	case errors.Is(err, models.ErrForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, models.ErrUnavailable):
+		var domainErr *models.Error
+		if errors.As(err, &domainErr) && domainErr.Err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "storage unavailable", "error", err, "cause", domainErr.Err)
+		}
+		code = codes.Unavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		logging.FromContext(ctx).ErrorContext(ctx, "call failed", "error", err)
+		return status.Error(codes.Internal, "internal server error")
+	}
+	return status.Error(code, err.Error())
+}
+
+// conflictError answers ALREADY_EXISTS with the objects that are in the
+// way, as REST answers 409 with them in the body.
+func conflictError(ctx context.Context, err error, existing ...protoadapt.MessageV1) error {
+	st, detailsErr := status.New(codes.AlreadyExists, err.Error()).WithDetails(existing...)
+	if detailsErr != nil {
+		return statusError(ctx, err)
+	}
+	return st.Err()
+}
+
+func invalidArgument(message string) error {
+	return status.Error(codes.InvalidArgument, message)
+}
@@ -0,0 +1,120 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"park_db_course/internal/api/grpc/forumpb"
+	"park_db_course/internal/models"
+	"park_db_course/internal/usecase"
+
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// listing limits of swagger.yml
+const (
+	defaultLimit = 100
+	maxLimit     = 10000
+)
+
+type forumServer struct {
+	forumpb.UnimplementedForumServiceServer
+	forums usecase.ForumUsecaseI
+}
+
+func (s *forumServer) CreateForum(ctx context.Context, req *forumpb.CreateForumRequest) (*forumpb.Forum, error) {
+	if req.Title == "" || (claimsIdentity(ctx) && req.User == "") || req.Slug == "" {
+		return nil, invalidArgument("title, user and slug are required")
+	}
+
+	forum, err := s.forums.Create(ctx, models.ForumReq{Title: req.Title, User: req.User, Slug: req.Slug})
+	if errors.Is(err, models.ErrConflict) && forum.Slug != "" {
+		return nil, conflictError(ctx, err, protoadapt.MessageV1Of(forumPb(forum)))
+	}
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return forumPb(forum), nil
+}
+
+func (s *forumServer) GetForum(ctx context.Context, req *forumpb.GetForumRequest) (*forumpb.Forum, error) {
+	forum, err := s.forums.Get(ctx, req.Slug)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return forumPb(forum), nil
+}
+
+func (s *forumServer) CreateThread(ctx context.Context, req *forumpb.CreateThreadRequest) (*forumpb.Thread, error) {
+	if req.Title == "" || (claimsIdentity(ctx) && req.Author == "") || req.Message == "" {
+		return nil, invalidArgument("title, author and message are required")
+	}
+
+	created := time.Now()
+	if req.Created != nil {
+		created = req.Created.AsTime()
+	}
+	thread, err := s.forums.CreateThread(ctx, req.Forum, models.ThreadsReq{
+		Title:   req.Title,
+		Author:  req.Author,
+		Message: req.Message,
+		Slug:    req.Slug,
+		Created: created,
+	})
+	if errors.Is(err, models.ErrConflict) && thread.Id != 0 {
+		return nil, conflictError(ctx, err, protoadapt.MessageV1Of(threadPb(thread)))
+	}
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return threadPb(thread), nil
+}
+
+func (s *forumServer) ListThreads(ctx context.Context, req *forumpb.ListThreadsRequest) (*forumpb.ListThreadsResponse, error) {
+	limit, err := listLimit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	var since string
+	if req.Since != nil {
+		since = req.Since.AsTime().Format(time.RFC3339Nano)
+	}
+
+	threads, err := s.forums.Threads(ctx, req.Forum, since, limit, req.Desc)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	res := &forumpb.ListThreadsResponse{Threads: make([]*forumpb.Thread, 0, len(threads))}
+	for _, t := range threads {
+		res.Threads = append(res.Threads, threadPb(t))
+	}
+	return res, nil
+}
+
+func (s *forumServer) ListUsers(ctx context.Context, req *forumpb.ListUsersRequest) (*forumpb.ListUsersResponse, error) {
+	limit, err := listLimit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.forums.Users(ctx, req.Forum, req.Since, limit, req.Desc)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	res := &forumpb.ListUsersResponse{Users: make([]*forumpb.User, 0, len(users))}
+	for _, u := range users {
+		res.Users = append(res.Users, userPb(u))
+	}
+	return res, nil
+}
+
+func listLimit(limit int32) (int, error) {
+	switch {
+	case limit == 0:
+		return defaultLimit, nil
+	case limit < 0 || limit > maxLimit:
+		return 0, invalidArgument("limit must be between 1 and 10000")
+	}
+	return int(limit), nil
+}
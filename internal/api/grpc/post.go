@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"context"
+
+	"park_db_course/internal/api/grpc/forumpb"
+	"park_db_course/internal/models"
+	"park_db_course/internal/usecase"
+)
+
+var postRelated = map[forumpb.GetPostRequest_Related]string{
+	forumpb.GetPostRequest_USER:   "user",
+	forumpb.GetPostRequest_FORUM:  "forum",
+	forumpb.GetPostRequest_THREAD: "thread",
+}
+
+type postServer struct {
+	forumpb.UnimplementedPostServiceServer
+	posts usecase.PostUsecaseI
+}
+
+func (s *postServer) GetPost(ctx context.Context, req *forumpb.GetPostRequest) (*forumpb.GetPostResponse, error) {
+	related := make([]string, 0, len(req.Related))
+	for _, r := range req.Related {
+		name, ok := postRelated[r]
+		if !ok {
+			return nil, invalidArgument("unknown related " + r.String())
+		}
+		related = append(related, name)
+	}
+
+	info, err := s.posts.Get(ctx, int(req.Id), related)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	res := &forumpb.GetPostResponse{Post: postPb(*info.Post)}
+	if info.Author != nil {
+		res.Author = userPb(*info.Author)
+	}
+	if info.Thread != nil {
+		res.Thread = threadPb(*info.Thread)
+	}
+	if info.Forum != nil {
+		res.Forum = forumPb(*info.Forum)
+	}
+	return res, nil
+}
+
+func (s *postServer) UpdatePost(ctx context.Context, req *forumpb.UpdatePostRequest) (*forumpb.Post, error) {
+	post, err := s.posts.Update(ctx, int(req.Id), models.PostUpdateReq{Message: req.Message})
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return postPb(post), nil
+}
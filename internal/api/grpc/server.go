@@ -0,0 +1,139 @@
+// Package grpc serves the forum over gRPC, see doc/forum.proto. It runs on
+// its own port next to the fasthttp router and calls the same use cases, so
+// both transports share the rules and the database pool.
+package grpc
+
+//go:generate protoc -I ../../../doc --go_out=../../.. --go_opt=module=park_db_course --go-grpc_out=../../.. --go-grpc_opt=module=park_db_course forum.proto
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"park_db_course/internal/api/grpc/forumpb"
+	"park_db_course/internal/auth"
+	"park_db_course/internal/logging"
+	"park_db_course/internal/usecase"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Usecases is everything the gRPC services call.
+type Usecases struct {
+	Users   usecase.UserUsecaseI
+	Forums  usecase.ForumUsecaseI
+	Threads usecase.ThreadUsecaseI
+	Posts   usecase.PostUsecaseI
+	Service usecase.ServiceUsecaseI
+	// Auth authenticates the bearer token in the authorization metadata
+	// of every call, as WithAuthentication does for HTTP. nil disables
+	// auth.
+	Auth usecase.AuthUsecaseI
+}
+
+// NewServer registers every service of doc/forum.proto. Like WithTimeout
+// for HTTP, requestTimeout bounds the database work of a single call. Like
+// WithLogging every call gets a request id, the x-request-id of its
+// metadata when usable, sent back in its header, and is logged by logger
+// with access set.
+func NewServer(uc Usecases, requestTimeout time.Duration, logger *slog.Logger, access bool) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (_ interface{}, err error) {
+			ctx = withRequestID(ctx, logger)
+			defer logCall(ctx, logger, access, info.FullMethod, time.Now(), &err)
+
+			if ctx, err = authenticate(ctx, uc.Auth); err != nil {
+				return nil, err
+			}
+			ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+			defer cancel()
+			return next(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) (err error) {
+			ctx := withRequestID(ss.Context(), logger)
+			defer logCall(ctx, logger, access, info.FullMethod, time.Now(), &err)
+
+			if ctx, err = authenticate(ctx, uc.Auth); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+			defer cancel()
+			return next(srv, &timeoutStream{ServerStream: ss, ctx: ctx})
+		}),
+	)
+
+	forumpb.RegisterUserServiceServer(srv, &userServer{users: uc.Users})
+	forumpb.RegisterForumServiceServer(srv, &forumServer{forums: uc.Forums})
+	forumpb.RegisterThreadServiceServer(srv, &threadServer{threads: uc.Threads})
+	forumpb.RegisterPostServiceServer(srv, &postServer{posts: uc.Posts})
+	forumpb.RegisterDatabaseServiceServer(srv, &databaseServer{service: uc.Service})
+	if uc.Auth != nil {
+		forumpb.RegisterAuthServiceServer(srv, &authServer{auth: uc.Auth})
+	}
+	return srv
+}
+
+// requestIDKey is logging.RequestIDHeader as grpc metadata keys are spelled.
+var requestIDKey = strings.ToLower(logging.RequestIDHeader)
+
+// withRequestID returns ctx carrying the request id of the call and logger,
+// and sends the id back in the response header.
+func withRequestID(ctx context.Context, logger *slog.Logger) context.Context {
+	var id string
+	if ids := metadata.ValueFromIncomingContext(ctx, requestIDKey); len(ids) > 0 {
+		id = ids[0]
+	}
+	id = logging.RequestIDOrNew(id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return logging.NewContext(logging.WithRequestID(ctx, id), logger)
+}
+
+// authenticate returns ctx carrying the caller of the bearer token of the
+// call, anonymous without one. A token that does not verify fails the call.
+func authenticate(ctx context.Context, a usecase.AuthUsecaseI) (context.Context, error) {
+	if a == nil {
+		return ctx, nil
+	}
+	var caller auth.Caller
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		if token := auth.BearerToken(values[0]); token != "" {
+			var err error
+			if caller, err = a.Authenticate(token); err != nil {
+				return ctx, statusError(ctx, err)
+			}
+		}
+	}
+	return auth.WithCaller(ctx, caller), nil
+}
+
+// logCall is deferred by the interceptors with the time the call started and
+// its named error.
+func logCall(ctx context.Context, logger *slog.Logger, access bool, method string, start time.Time, err *error) {
+	if !access {
+		return
+	}
+	code := status.Code(*err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+	logger.LogAttrs(ctx, level, "call",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+type timeoutStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *timeoutStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,261 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"park_db_course/internal/api/grpc/forumpb"
+	"park_db_course/internal/auth"
+	"park_db_course/internal/logging"
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository/memory"
+	"park_db_course/internal/usecase"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testClients struct {
+	auth    forumpb.AuthServiceClient
+	users   forumpb.UserServiceClient
+	forums  forumpb.ForumServiceClient
+	threads forumpb.ThreadServiceClient
+	posts   forumpb.PostServiceClient
+	db      forumpb.DatabaseServiceClient
+}
+
+// serveTestGRPC runs NewServer over a fresh in-memory store on an
+// in-process listener.
+func serveTestGRPC(t *testing.T) testClients {
+	t.Helper()
+	return serveTestGRPCWith(t, false)
+}
+
+// serveTestGRPCWith is serveTestGRPC with auth enabled when withAuth is
+// set. Users is registered with password "pw" first then.
+func serveTestGRPCWith(t *testing.T, withAuth bool, users ...string) testClients {
+	t.Helper()
+
+	s := memory.NewStore()
+	userRepo, forumRepo, threadRepo := memory.NewUserRepo(s), memory.NewForumRepo(s), memory.NewThreadRepo(s)
+	authz := usecase.NewAuthorizer(userRepo, forumRepo, false)
+	uc := Usecases{
+		Users:   usecase.NewUserUsecase(userRepo, bcrypt.MinCost, authz),
+		Forums:  usecase.NewForumUsecase(forumRepo, userRepo, threadRepo, authz),
+		Threads: usecase.NewThreadUsecase(threadRepo, userRepo, authz, true),
+		Posts:   usecase.NewPostUsecase(memory.NewPostRepo(s), authz),
+		Service: usecase.NewServiceUsecase(memory.NewServiceRepo(s), authz),
+	}
+	if withAuth {
+		uc.Auth = usecase.NewAuthUsecase(userRepo, auth.NewSigner([]byte(strings.Repeat("s", 32)), time.Hour))
+		for _, nickname := range users {
+			if _, _, err := uc.Users.Create(context.Background(), models.User{Nickname: nickname, Email: nickname + "@mail.ru"}, "pw"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	srv := NewServer(uc, time.Second, logging.Discard(), false)
+
+	ln := bufconn.Listen(1 << 20)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return ln.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return testClients{
+		auth:    forumpb.NewAuthServiceClient(conn),
+		users:   forumpb.NewUserServiceClient(conn),
+		forums:  forumpb.NewForumServiceClient(conn),
+		threads: forumpb.NewThreadServiceClient(conn),
+		posts:   forumpb.NewPostServiceClient(conn),
+		db:      forumpb.NewDatabaseServiceClient(conn),
+	}
+}
+
+func wantCode(t *testing.T, err error, code codes.Code) *status.Status {
+	t.Helper()
+	st, _ := status.FromError(err)
+	if st.Code() != code {
+		t.Fatalf("got %v, want code %s", err, code)
+	}
+	return st
+}
+
+func TestForumFlow(t *testing.T) {
+	c := serveTestGRPC(t)
+	ctx := context.Background()
+
+	if _, err := c.users.CreateUser(ctx, &forumpb.User{Nickname: "alice", Fullname: "Alice", Email: "alice@mail.ru"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.users.CreateUser(ctx, &forumpb.User{Nickname: "ALICE", Fullname: "A", Email: "other@mail.ru"})
+	st := wantCode(t, err, codes.AlreadyExists)
+	if details := st.Details(); len(details) != 1 || details[0].(*forumpb.User).Nickname != "alice" {
+		t.Errorf("conflict details = %v, want the existing alice", details)
+	}
+
+	_, err = c.forums.GetForum(ctx, &forumpb.GetForumRequest{Slug: "pirates"})
+	wantCode(t, err, codes.NotFound)
+	if _, err = c.forums.CreateForum(ctx, &forumpb.CreateForumRequest{Title: "Pirates", User: "alice", Slug: "pirates"}); err != nil {
+		t.Fatal(err)
+	}
+	thread, err := c.forums.CreateThread(ctx, &forumpb.CreateThreadRequest{Forum: "pirates", Title: "Jolly", Author: "alice", Message: "m", Slug: "jolly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.threads.Vote(ctx, &forumpb.VoteRequest{SlugOrId: "jolly", Nickname: "alice", Voice: 2})
+	wantCode(t, err, codes.InvalidArgument)
+	voted, err := c.threads.Vote(ctx, &forumpb.VoteRequest{SlugOrId: "jolly", Nickname: "alice", Voice: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if voted.Votes != 1 || voted.Id != thread.Id {
+		t.Errorf("vote = %v", voted)
+	}
+
+	created, err := c.threads.CreatePosts(ctx, &forumpb.CreatePostsRequest{SlugOrId: "jolly", Posts: []*forumpb.NewPost{
+		{Author: "alice", Message: "first"},
+		{Author: "alice", Message: "second"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.threads.CreatePosts(ctx, &forumpb.CreatePostsRequest{SlugOrId: "jolly", Posts: []*forumpb.NewPost{
+		{Parent: 1000, Author: "alice", Message: "orphan"},
+	}})
+	wantCode(t, err, codes.FailedPrecondition)
+
+	reply, err := c.threads.CreatePosts(ctx, &forumpb.CreatePostsRequest{SlugOrId: "jolly", Posts: []*forumpb.NewPost{
+		{Parent: created.Posts[0].Id, Author: "alice", Message: "reply"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := c.threads.ListPosts(ctx, &forumpb.ListPostsRequest{SlugOrId: "jolly", Sort: forumpb.ListPostsRequest_TREE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for {
+		p, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, p.Id)
+	}
+	want := []int64{created.Posts[0].Id, reply.Posts[0].Id, created.Posts[1].Id}
+	if len(ids) != len(want) || ids[0] != want[0] || ids[1] != want[1] || ids[2] != want[2] {
+		t.Errorf("tree order = %v, want %v", ids, want)
+	}
+
+	details, err := c.posts.GetPost(ctx, &forumpb.GetPostRequest{Id: reply.Posts[0].Id, Related: []forumpb.GetPostRequest_Related{forumpb.GetPostRequest_FORUM}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Forum.GetSlug() != "pirates" || details.Author != nil {
+		t.Errorf("post details = %v", details)
+	}
+
+	status, err := c.db.GetStatus(ctx, &forumpb.GetStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.User != 1 || status.Forum != 1 || status.Thread != 1 || status.Post != 3 {
+		t.Errorf("status = %v", status)
+	}
+}
+
+func TestListPostsNotFound(t *testing.T) {
+	c := serveTestGRPC(t)
+
+	stream, err := c.threads.ListPosts(context.Background(), &forumpb.ListPostsRequest{SlugOrId: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	wantCode(t, err, codes.NotFound)
+}
+
+func TestRequestID(t *testing.T) {
+	c := serveTestGRPC(t)
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "probe-1")
+	if _, err := c.db.GetStatus(ctx, &forumpb.GetStatusRequest{}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "probe-1" {
+		t.Errorf("x-request-id = %v, want the one sent", got)
+	}
+
+	header = nil
+	_, err := c.users.GetUser(context.Background(), &forumpb.GetUserRequest{Nickname: "nobody"}, grpc.Header(&header))
+	wantCode(t, err, codes.NotFound)
+	if got := header.Get("x-request-id"); len(got) != 1 || len(got[0]) != 32 {
+		t.Errorf("x-request-id = %v, want a generated one", got)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	c := serveTestGRPCWith(t, true, "jack", "alice")
+	ctx := context.Background()
+
+	_, err := c.auth.Login(ctx, &forumpb.LoginRequest{Nickname: "jack", Password: "wrong"})
+	wantCode(t, err, codes.Unauthenticated)
+	token, err := c.auth.Login(ctx, &forumpb.LoginRequest{Nickname: "JACK", Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token == "" || !token.Expires.AsTime().After(time.Now()) {
+		t.Fatalf("token = %v", token)
+	}
+	jack := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token.Token)
+
+	_, err = c.forums.CreateForum(ctx, &forumpb.CreateForumRequest{Title: "Pirates", User: "jack", Slug: "pirates"})
+	wantCode(t, err, codes.Unauthenticated)
+	forum, err := c.forums.CreateForum(jack, &forumpb.CreateForumRequest{Title: "Pirates", Slug: "pirates"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thread, err := c.forums.CreateThread(jack, &forumpb.CreateThreadRequest{Forum: "pirates", Title: "Jolly", Message: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := c.threads.CreatePosts(jack, &forumpb.CreatePostsRequest{SlugOrId: "1", Posts: []*forumpb.NewPost{
+		{Message: "no author"},
+		{Author: "alice", Message: "claimed"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forum.User != "jack" || thread.Author != "jack" || created.Posts[0].Author != "jack" || created.Posts[1].Author != "jack" {
+		t.Errorf("acted as %s, %s, %s and %s, want jack from the token", forum.User, thread.Author, created.Posts[0].Author, created.Posts[1].Author)
+	}
+	_, err = c.threads.CreatePosts(jack, &forumpb.CreatePostsRequest{SlugOrId: "1", Posts: []*forumpb.NewPost{{}}})
+	wantCode(t, err, codes.InvalidArgument)
+
+	// without auth there are no tokens to issue
+	_, err = serveTestGRPC(t).auth.Login(ctx, &forumpb.LoginRequest{Nickname: "jack", Password: "pw"})
+	wantCode(t, err, codes.Unimplemented)
+}
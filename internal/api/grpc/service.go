@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"context"
+
+	"park_db_course/internal/api/grpc/forumpb"
+	"park_db_course/internal/usecase"
+)
+
+type databaseServer struct {
+	forumpb.UnimplementedDatabaseServiceServer
+	service usecase.ServiceUsecaseI
+}
+
+func (s *databaseServer) GetStatus(ctx context.Context, _ *forumpb.GetStatusRequest) (*forumpb.Status, error) {
+	status, err := s.service.Status(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &forumpb.Status{
+		User:   int64(status.User),
+		Forum:  int64(status.Forum),
+		Thread: int64(status.Thread),
+		Post:   int64(status.Post),
+	}, nil
+}
+
+func (s *databaseServer) Clear(ctx context.Context, _ *forumpb.ClearRequest) (*forumpb.ClearResponse, error) {
+	if err := s.service.Clear(ctx); err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &forumpb.ClearResponse{}, nil
+}
+
+func (s *databaseServer) GetPoolStats(ctx context.Context, _ *forumpb.GetPoolStatsRequest) (*forumpb.PoolStats, error) {
+	st := s.service.PoolStats(ctx)
+	return &forumpb.PoolStats{
+		MaxConns:                st.MaxConns,
+		TotalConns:              st.TotalConns,
+		IdleConns:               st.IdleConns,
+		AcquiredConns:           st.AcquiredConns,
+		ConstructingConns:       st.ConstructingConns,
+		AcquireCount:            st.AcquireCount,
+		AcquireDurationMs:       st.AcquireDurationMs,
+		EmptyAcquireCount:       st.EmptyAcquireCount,
+		CanceledAcquireCount:    st.CanceledAcquireCount,
+		NewConnsCount:           st.NewConnsCount,
+		MaxLifetimeDestroyCount: st.MaxLifetimeDestroyCount,
+		MaxIdleDestroyCount:     st.MaxIdleDestroyCount,
+	}, nil
+}
@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	"context"
+	"strconv"
+
+	"park_db_course/internal/api/grpc/forumpb"
+	"park_db_course/internal/models"
+	"park_db_course/internal/usecase"
+)
+
+var postSorts = map[forumpb.ListPostsRequest_Sort]string{
+	forumpb.ListPostsRequest_FLAT:        "flat",
+	forumpb.ListPostsRequest_TREE:        "tree",
+	forumpb.ListPostsRequest_PARENT_TREE: "parent_tree",
+}
+
+type threadServer struct {
+	forumpb.UnimplementedThreadServiceServer
+	threads usecase.ThreadUsecaseI
+}
+
+func (s *threadServer) CreatePosts(ctx context.Context, req *forumpb.CreatePostsRequest) (*forumpb.CreatePostsResponse, error) {
+	claims := claimsIdentity(ctx)
+	posts := make([]models.PostReq, 0, len(req.Posts))
+	for i, p := range req.Posts {
+		if (claims && p.Author == "") || p.Message == "" {
+			return nil, invalidArgument("posts[" + strconv.Itoa(i) + "]: author and message are required")
+		}
+		posts = append(posts, models.PostReq{Parent: int(p.Parent), Author: p.Author, Message: p.Message})
+	}
+
+	created, err := s.threads.AddPosts(ctx, req.SlugOrId, posts)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &forumpb.CreatePostsResponse{Posts: postsPb(created)}, nil
+}
+
+func (s *threadServer) GetThread(ctx context.Context, req *forumpb.GetThreadRequest) (*forumpb.Thread, error) {
+	thread, err := s.threads.Get(ctx, req.SlugOrId)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return threadPb(thread), nil
+}
+
+func (s *threadServer) UpdateThread(ctx context.Context, req *forumpb.UpdateThreadRequest) (*forumpb.Thread, error) {
+	thread, err := s.threads.Update(ctx, req.SlugOrId, models.ThreadUpdateReq{Title: req.Title, Message: req.Message})
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return threadPb(thread), nil
+}
+
+func (s *threadServer) Vote(ctx context.Context, req *forumpb.VoteRequest) (*forumpb.Thread, error) {
+	if req.Voice != 1 && req.Voice != -1 {
+		return nil, invalidArgument("voice must be -1 or 1")
+	}
+
+	thread, err := s.threads.Vote(ctx, req.SlugOrId, models.VoteRequest{Nickname: req.Nickname, Voice: int(req.Voice)})
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return threadPb(thread), nil
+}
+
+// ListPosts reads the page with one query, like the REST listing, and
+// streams it post by post.
+func (s *threadServer) ListPosts(req *forumpb.ListPostsRequest, stream forumpb.ThreadService_ListPostsServer) error {
+	limit, err := listLimit(req.Limit)
+	if err != nil {
+		return err
+	}
+	sort, ok := postSorts[req.Sort]
+	if !ok {
+		return invalidArgument("unknown sort " + req.Sort.String())
+	}
+	var since string
+	if req.Since != 0 {
+		since = strconv.FormatInt(req.Since, 10)
+	}
+
+	posts, err := s.threads.Posts(stream.Context(), req.SlugOrId, since, sort, limit, req.Desc)
+	if err != nil {
+		return statusError(stream.Context(), err)
+	}
+	for _, p := range posts {
+		if err = stream.Send(postPb(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"park_db_course/internal/api/grpc/forumpb"
+	"park_db_course/internal/models"
+	"park_db_course/internal/usecase"
+
+	"google.golang.org/protobuf/protoadapt"
+)
+
+type userServer struct {
+	forumpb.UnimplementedUserServiceServer
+	users usecase.UserUsecaseI
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *forumpb.User) (*forumpb.User, error) {
+	if req.Nickname == "" || req.Email == "" {
+		return nil, invalidArgument("nickname and email are required")
+	}
+
+	// User carries no password, so with auth enabled users register over
+	// HTTP.
+	user, existing, err := s.users.Create(ctx, models.User{
+		Nickname: req.Nickname,
+		Fullname: req.Fullname,
+		About:    req.About,
+		Email:    req.Email,
+	}, "")
+	if errors.Is(err, models.ErrConflict) && len(existing) > 0 {
+		details := make([]protoadapt.MessageV1, 0, len(existing))
+		for _, u := range existing {
+			details = append(details, protoadapt.MessageV1Of(userPb(*u)))
+		}
+		return nil, conflictError(ctx, err, details...)
+	}
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return userPb(user), nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *forumpb.GetUserRequest) (*forumpb.User, error) {
+	user, err := s.users.Get(ctx, req.Nickname)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return userPb(user), nil
+}
+
+func (s *userServer) UpdateUser(ctx context.Context, req *forumpb.UpdateUserRequest) (*forumpb.User, error) {
+	user, err := s.users.UpdateProfile(ctx, req.Nickname, models.UserUpdate{
+		Fullname: req.Fullname,
+		About:    req.About,
+		Email:    req.Email,
+	})
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return userPb(user), nil
+}
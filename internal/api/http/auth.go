@@ -0,0 +1,63 @@
+package http
+
+import (
+	"net/http"
+
+	"park_db_course/internal/auth"
+	"park_db_course/internal/models"
+	"park_db_course/internal/usecase"
+
+	"github.com/mailru/easyjson"
+	"github.com/valyala/fasthttp"
+)
+
+type AuthHandlersI interface {
+	Login(ctx *fasthttp.RequestCtx)
+}
+
+type authH struct {
+	auth usecase.AuthUsecaseI
+}
+
+func NewAuthH(a usecase.AuthUsecaseI) AuthHandlersI {
+	return &authH{auth: a}
+}
+
+func (h *authH) Login(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	var creds models.Credentials
+	err := easyjson.Unmarshal(ctx.PostBody(), &creds)
+	if err != nil {
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	token, err := h.auth.Login(reqCtx, creds)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, token)
+}
+
+// WithAuthentication resolves the caller of every request from its bearer
+// token, anonymous when it sent none, for the use cases to act as. A token
+// that does not verify is answered with 401 right away. Installed only with
+// auth enabled: without it the use cases trust the nicknames of requests.
+func WithAuthentication(authenticate func(token string) (auth.Caller, error), next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		var caller auth.Caller
+		if token := auth.BearerToken(string(ctx.Request.Header.Peek(fasthttp.HeaderAuthorization))); token != "" {
+			var err error
+			if caller, err = authenticate(token); err != nil {
+				writeError(ctx, err)
+				return
+			}
+		}
+
+		ctx.SetUserValue(requestContextKey{}, auth.WithCaller(requestContext(ctx), caller))
+		next(ctx)
+	}
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"park_db_course/internal/logging"
+	"park_db_course/internal/tracing"
+
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestContextKey struct{}
+
+// WithTimeout bounds every repository call made while serving a request by
+// timeout. Queries still running at the deadline, or when the client closes
+// its connection before the handler returned, are cancelled in postgres.
+// A disconnect is noticed on plain TCP and unix sockets only, see
+// watchDisconnect.
+//
+// The context carries the span of WithTracing but is deliberately not
+// derived from *fasthttp.RequestCtx: its Done channel closes as soon as
+// graceful shutdown starts, which would abort the very requests we are
+// draining.
+func WithTimeout(timeout time.Duration, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		reqCtx, cancel := context.WithTimeout(requestContext(ctx), timeout)
+		defer cancel()
+
+		ctx.SetUserValue(requestContextKey{}, reqCtx)
+		stop := watchDisconnect(ctx.Conn(), cancel)
+		next(ctx)
+		stop()
+
+		if errors.Is(reqCtx.Err(), context.DeadlineExceeded) && ctx.Response.StatusCode() >= http.StatusBadRequest {
+			writeMessage(ctx, http.StatusGatewayTimeout, "request timed out")
+		}
+	}
+}
+
+// WithLogging gives every request an id, the X-Request-ID of the caller
+// when it sent a usable one, echoes it in the response and makes logger,
+// tagged with it, the logger of the request. With access set a line is
+// logged for every request once it is served.
+func WithLogging(logger *slog.Logger, access bool, route func(ctx *fasthttp.RequestCtx) string, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		id := logging.RequestIDOrNew(string(ctx.Request.Header.Peek(logging.RequestIDHeader)))
+		ctx.Response.Header.Set(logging.RequestIDHeader, id)
+
+		reqCtx := logging.NewContext(logging.WithRequestID(requestContext(ctx), id), logger)
+		ctx.SetUserValue(requestContextKey{}, reqCtx)
+		next(ctx)
+
+		if !access {
+			return
+		}
+		status := ctx.Response.StatusCode()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(requestContext(ctx), level, "request",
+			slog.String("method", string(ctx.Method())),
+			slog.String("path", string(ctx.Path())),
+			slog.String("route", route(ctx)),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote", ctx.RemoteIP().String()),
+		)
+	}
+}
+
+// WithTracing records a span for every request, continuing the traceparent
+// of the caller, and makes it the parent of the spans recorded while the
+// request is served. Once the router matched, route names the span.
+func WithTracing(tp trace.TracerProvider, route func(ctx *fasthttp.RequestCtx) string, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	tracer := tp.Tracer("park_db_course/internal/api/http")
+	return func(ctx *fasthttp.RequestCtx) {
+		method := string(ctx.Method())
+		parent := tracing.Propagator.Extract(requestContext(ctx), headerCarrier{&ctx.Request.Header})
+		spanCtx, span := tracer.Start(parent, method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(method), semconv.URLPath(string(ctx.Path()))))
+		defer span.End()
+
+		ctx.SetUserValue(requestContextKey{}, spanCtx)
+		next(ctx)
+
+		if name := route(ctx); name != "" {
+			span.SetName(name)
+		}
+		if path, ok := ctx.UserValue(router.MatchedRoutePathParam).(string); ok {
+			span.SetAttributes(semconv.HTTPRoute(path))
+		}
+		for _, param := range pathParams {
+			if v, ok := ctx.UserValue(param).(string); ok {
+				span.SetAttributes(attribute.String(param, v))
+			}
+		}
+		status := ctx.Response.StatusCode()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// pathParams are the path parameters of the API routes, recorded on spans.
+var pathParams = []string{"slug", "slug_or_id", "nickname", "id"}
+
+// headerCarrier lets the propagator read request headers.
+type headerCarrier struct {
+	h *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) Get(key string) string {
+	return string(c.h.Peek(key))
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.h.Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+	c.h.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// requestContext returns the context set up by WithLogging, WithTracing
+// and WithTimeout.
+func requestContext(ctx *fasthttp.RequestCtx) context.Context {
+	if reqCtx, ok := ctx.UserValue(requestContextKey{}).(context.Context); ok {
+		return reqCtx
+	}
+	return context.Background()
+}
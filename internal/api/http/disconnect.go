@@ -0,0 +1,53 @@
+//go:build unix
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// watchDisconnect calls cancel when the client closes c while its request
+// is served. fasthttp reads nothing from c until the handler returned, so
+// c is peeked at in the kernel: data stays for fasthttp to read. Bytes of
+// a pipelined request end the watch, as a close cannot be told then.
+//
+// The returned stop ends the watch, it has to be called before the
+// handler returns.
+func watchDisconnect(c net.Conn, cancel context.CancelFunc) (stop func()) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var b [1]byte
+		_ = raw.Read(func(fd uintptr) bool {
+			n, _, err := syscall.Recvfrom(int(fd), b[:], syscall.MSG_PEEK)
+			switch {
+			case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR):
+				// wait until c is readable or the deadline of stop passed
+				return false
+			case err != nil || n == 0:
+				cancel()
+			}
+			return true
+		})
+	}()
+	return func() {
+		// a passed deadline wakes the raw read, fasthttp sets its own
+		// deadlines before it reads the next request
+		_ = c.SetReadDeadline(time.Now())
+		<-done
+		_ = c.SetReadDeadline(time.Time{})
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package http
+
+import (
+	"context"
+	"net"
+)
+
+// watchDisconnect cannot peek at connections here, a disconnect does not
+// cancel the request.
+func watchDisconnect(net.Conn, context.CancelFunc) (stop func()) {
+	return func() {}
+}
@@ -1,12 +1,10 @@
 package http
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"park_db_course/internal/models"
-	"park_db_course/internal/repository"
-	"strconv"
+	"park_db_course/internal/usecase"
 
 	"github.com/mailru/easyjson"
 	"github.com/valyala/fasthttp"
@@ -18,224 +16,143 @@ type ForumHandlersI interface {
 	CreateThread(ctx *fasthttp.RequestCtx)
 	ForumThreads(ctx *fasthttp.RequestCtx)
 	ForumUsers(ctx *fasthttp.RequestCtx)
+	Moderators(ctx *fasthttp.RequestCtx)
+	AddModerator(ctx *fasthttp.RequestCtx)
+	RemoveModerator(ctx *fasthttp.RequestCtx)
 }
 
 type forumH struct {
-	forumRepo  repository.ForumRepoI
-	userRepo   repository.UserRepoI
-	threadRepo repository.ThreadRepoI
+	forums usecase.ForumUsecaseI
 }
 
-func NewForumH(f repository.ForumRepoI, u repository.UserRepoI, t repository.ThreadRepoI) ForumHandlersI {
+func NewForumH(f usecase.ForumUsecaseI) ForumHandlersI {
 	return &forumH{
-		forumRepo:  f,
-		userRepo:   u,
-		threadRepo: t,
+		forums: f,
 	}
 }
 
 func (h *forumH) Create(ctx *fasthttp.RequestCtx) {
-	forum := models.ForumReq{}
-	err := easyjson.Unmarshal(ctx.PostBody(), &forum)
+	reqCtx := requestContext(ctx)
+
+	req := models.ForumReq{}
+	err := easyjson.Unmarshal(ctx.PostBody(), &req)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
 
-	checkForum, err := h.forumRepo.GetBySlug(forum.Slug)
-	if err == nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusConflict)
-		body, _ := easyjson.Marshal(checkForum)
-		ctx.SetBody(body)
+	forum, err := h.forums.Create(reqCtx, req)
+	if errors.Is(err, models.ErrConflict) && forum.Slug != "" {
+		writeJSON(ctx, http.StatusConflict, forum)
 		return
 	}
-
-	checkUser, err := h.userRepo.GetByNickname(forum.User)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	forum.User = checkUser.Nickname
-
-	newForum, err := h.forumRepo.Create(forum)
-	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusInternalServerError)
-		return
-	}
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusCreated)
-	body, err := easyjson.Marshal(newForum)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusCreated, forum)
 }
 
 func (h *forumH) Details(ctx *fasthttp.RequestCtx) {
-	checkForum, err := h.forumRepo.GetBySlug(ctx.UserValue("slug").(string))
+	reqCtx := requestContext(ctx)
+
+	forum, err := h.forums.Get(reqCtx, ctx.UserValue("slug").(string))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := easyjson.Marshal(checkForum)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, forum)
 }
 
 func (h *forumH) CreateThread(ctx *fasthttp.RequestCtx) {
-	checkForum, err := h.forumRepo.GetBySlug(ctx.UserValue("slug").(string))
-	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{})
-		ctx.SetBody(body)
-		return
-	}
+	reqCtx := requestContext(ctx)
 
-	thread := models.ThreadsReq{}
-	err = easyjson.Unmarshal(ctx.PostBody(), &thread)
+	req := models.ThreadsReq{}
+	err := easyjson.Unmarshal(ctx.PostBody(), &req)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
 
-	if thread.Slug != "" {
-		checkThread, err := h.threadRepo.GetBySlugOrId(thread.Slug)
-		if err == nil {
-			ctx.SetContentType("application/json")
-			ctx.SetStatusCode(http.StatusConflict)
-			body, _ := easyjson.Marshal(checkThread)
-			ctx.SetBody(body)
-			return
-		}
-	}
-
-	thread.Forum = checkForum.Slug
-
-	checkAuthor, err := h.userRepo.GetByNickname(thread.Author)
-	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{})
-		ctx.SetBody(body)
+	thread, err := h.forums.CreateThread(reqCtx, ctx.UserValue("slug").(string), req)
+	if errors.Is(err, models.ErrConflict) && thread.Id != 0 {
+		writeJSON(ctx, http.StatusConflict, thread)
 		return
 	}
-	thread.Author = checkAuthor.Nickname
-
-	newThread, err := h.threadRepo.Create(thread)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusInternalServerError)
-		fmt.Println("WHAT", err)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusCreated)
-	body, _ := easyjson.Marshal(newThread)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusCreated, thread)
 }
 
 func (h *forumH) ForumThreads(ctx *fasthttp.RequestCtx) {
-	slug := ctx.UserValue("slug").(string)
-	_, err := h.forumRepo.GetBySlug(slug)
+	reqCtx := requestContext(ctx)
+
+	limit := paramInt(ctx, "limit")
+	since := paramString(ctx, "since")
+	desc := paramBool(ctx, "desc")
+
+	threads, err := h.forums.Threads(reqCtx, ctx.UserValue("slug").(string), since, limit, desc)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	limit := 0
-	if limitVal := string(ctx.FormValue("limit")); limitVal != "" {
-		limit, err = strconv.Atoi(limitVal)
-		if err != nil {
-			ctx.SetContentType("application/json")
-			ctx.SetStatusCode(http.StatusBadRequest)
-			body, _ := easyjson.Marshal(models.MessageError{Message: "wrong limit format"})
-			ctx.SetBody(body)
-		}
-	} else {
-		limit = 100
-	}
+	writeJSON(ctx, http.StatusOK, threads)
+}
 
-	since := string(ctx.FormValue("since"))
+func (h *forumH) ForumUsers(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
 
-	desc := false
-	if string(ctx.FormValue("desc")) == "true" {
-		desc = true
-	}
+	limit := paramInt(ctx, "limit")
+	since := paramString(ctx, "since")
+	desc := paramBool(ctx, "desc")
 
-	threads, err := h.forumRepo.GetThreads(slug, since, limit, desc)
+	users, err := h.forums.Users(reqCtx, ctx.UserValue("slug").(string), since, limit, desc)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := json.Marshal(threads)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, users)
 }
 
-func (h *forumH) ForumUsers(ctx *fasthttp.RequestCtx) {
-	slug, ok := ctx.UserValue("slug").(string)
-	if !ok {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "wrong slug format"})
-		ctx.SetBody(body)
-	}
-	forum, err := h.forumRepo.GetBySlug(slug)
+func (h *forumH) Moderators(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	users, err := h.forums.Moderators(reqCtx, ctx.UserValue("slug").(string))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find forum by slug: " + slug})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	limit := 0
-	if limitVal := string(ctx.FormValue("limit")); limitVal != "" {
-		limit, err = strconv.Atoi(limitVal)
-		if err != nil {
-			ctx.SetContentType("application/json")
-			ctx.SetStatusCode(http.StatusBadRequest)
-			body, _ := easyjson.Marshal(models.MessageError{Message: "wrong limit format"})
-			ctx.SetBody(body)
-		}
-	} else {
-		limit = 100
-	}
+	writeJSON(ctx, http.StatusOK, users)
+}
 
-	since := string(ctx.FormValue("since"))
+func (h *forumH) AddModerator(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
 
-	desc := false
-	if string(ctx.FormValue("desc")) == "true" {
-		desc = true
+	users, err := h.forums.AddModerator(reqCtx, ctx.UserValue("slug").(string), ctx.UserValue("nickname").(string))
+	if err != nil {
+		writeError(ctx, err)
+		return
 	}
 
-	users, err := h.forumRepo.GetUsers(forum, since, limit, desc)
+	writeJSON(ctx, http.StatusOK, users)
+}
+
+func (h *forumH) RemoveModerator(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	users, err := h.forums.RemoveModerator(reqCtx, ctx.UserValue("slug").(string), ctx.UserValue("nickname").(string))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := json.Marshal(users)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, users)
 }
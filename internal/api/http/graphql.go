@@ -0,0 +1,36 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"park_db_course/internal/api/graphql"
+
+	"github.com/valyala/fasthttp"
+)
+
+type GraphQLHandlersI interface {
+	Query(ctx *fasthttp.RequestCtx)
+}
+
+type graphqlH struct {
+	exec *graphql.Executor
+}
+
+func NewGraphQLH(exec *graphql.Executor) GraphQLHandlersI {
+	return &graphqlH{exec: exec}
+}
+
+// Query answers 200 whatever the outcome, as GraphQL over HTTP does for
+// application/json: errors are in the body next to the data.
+func (h *graphqlH) Query(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	var req graphql.Request
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeMessage(ctx, http.StatusBadRequest, "request body is not a GraphQL request")
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, h.exec.Execute(reqCtx, req))
+}
@@ -3,10 +3,9 @@ package http
 import (
 	"net/http"
 	"strconv"
-	"strings"
 
 	"park_db_course/internal/models"
-	"park_db_course/internal/repository"
+	"park_db_course/internal/usecase"
 
 	"github.com/mailru/easyjson"
 	"github.com/valyala/fasthttp"
@@ -15,87 +14,88 @@ import (
 type PostHandlersI interface {
 	GetDetails(ctx *fasthttp.RequestCtx)
 	UpdateDetails(ctx *fasthttp.RequestCtx)
+	Delete(ctx *fasthttp.RequestCtx)
 }
 
 type postH struct {
-	postRepo repository.PostRepoI
+	posts usecase.PostUsecaseI
 }
 
-func NewPostH(p repository.PostRepoI) PostHandlersI {
+func NewPostH(p usecase.PostUsecaseI) PostHandlersI {
 	return &postH{
-		postRepo: p,
+		posts: p,
 	}
 }
 
 func (h *postH) GetDetails(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
 	id, err := strconv.Atoi(ctx.UserValue("id").(string))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
+		writeMessage(ctx, http.StatusBadRequest, "wrong id format")
 		return
 	}
 
-	related := strings.Split(string(ctx.FormValue("related")), ",")
-	post, err := h.postRepo.Get(id, related)
+	related := paramStrings(ctx, "related")
+	post, err := h.posts.Get(reqCtx, id, related)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find post with id: " + strconv.Itoa(id)})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := easyjson.Marshal(post)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, post)
 }
 
 func (h *postH) UpdateDetails(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
 	id, err := strconv.Atoi(ctx.UserValue("id").(string))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
+		writeMessage(ctx, http.StatusBadRequest, "wrong id format")
 		return
 	}
 
 	var newPost models.PostUpdateReq
 	err = easyjson.Unmarshal(ctx.PostBody(), &newPost)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	post, err := h.posts.Update(reqCtx, id, newPost)
+	if err != nil {
+		writeError(ctx, err)
 		return
 	}
 
-	var related []string
-	postInfo, err := h.postRepo.Get(id, related)
+	writeJSON(ctx, http.StatusOK, post)
+}
+
+// Delete answers the placeholder left of the post, or 204 when the subtree
+// query flag removed it with its replies.
+func (h *postH) Delete(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	id, err := strconv.Atoi(ctx.UserValue("id").(string))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find post with id: " + strconv.Itoa(id)})
-		ctx.SetBody(body)
+		writeMessage(ctx, http.StatusBadRequest, "wrong id format")
 		return
 	}
 
-	oldPost := postInfo.Post
-	if newPost.Message == "" || oldPost.Message == newPost.Message {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusOK)
-		body, _ := easyjson.Marshal(oldPost)
-		ctx.SetBody(body)
+	if paramBool(ctx, "subtree") {
+		if err = h.posts.DeleteTree(reqCtx, id); err != nil {
+			writeError(ctx, err)
+			return
+		}
+		ctx.SetStatusCode(http.StatusNoContent)
 		return
 	}
 
-	post, err := h.postRepo.Update(id, newPost)
+	post, err := h.posts.Delete(reqCtx, id, paramBool(ctx, "hideAuthor"))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := easyjson.Marshal(post)
-	ctx.SetBody(body)
-	return
+	writeJSON(ctx, http.StatusOK, post)
 }
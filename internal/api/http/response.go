@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"park_db_course/internal/logging"
+	"park_db_course/internal/models"
+
+	"github.com/mailru/easyjson"
+	"github.com/valyala/fasthttp"
+)
+
+// writeJSON writes v with the given status. Models go through easyjson,
+// slices of them through encoding/json.
+func writeJSON(ctx *fasthttp.RequestCtx, status int, v interface{}) {
+	var body []byte
+	if m, ok := v.(easyjson.Marshaler); ok {
+		body, _ = easyjson.Marshal(m)
+	} else {
+		body, _ = json.Marshal(v)
+	}
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(status)
+	ctx.SetBody(body)
+}
+
+func writeMessage(ctx *fasthttp.RequestCtx, status int, message string) {
+	writeJSON(ctx, status, models.MessageError{Message: message})
+}
+
+// writeError is the single place a repository error becomes a response:
+// the kind of the domain error decides the status and its message is the
+// body. Errors of no known kind are logged and answered with a 500 that
+// does not leak driver details.
+func writeError(ctx *fasthttp.RequestCtx, err error) {
+	status, message := errorResponse(requestContext(ctx), err)
+	if status == http.StatusUnauthorized {
+		ctx.Response.Header.Set("WWW-Authenticate", "Bearer")
+	}
+	writeMessage(ctx, status, message)
+}
+
+// errorResponse is the status and message of err. Errors that are not the
+// caller's fault are logged with the logger of ctx.
+func errorResponse(ctx context.Context, err error) (int, string) {
+	status := errorStatus(err)
+	message := err.Error()
+	switch status {
+	case http.StatusInternalServerError:
+		logging.FromContext(ctx).ErrorContext(ctx, "request failed", "error", err)
+		message = "internal server error"
+	case http.StatusServiceUnavailable:
+		var domainErr *models.Error
+		if errors.As(err, &domainErr) && domainErr.Err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "storage unavailable", "error", err, "cause", domainErr.Err)
+		}
+	}
+	return status, message
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, models.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
@@ -3,45 +3,51 @@ package http
 import (
 	"net/http"
 
-	"park_db_course/internal/repository"
+	"park_db_course/internal/usecase"
 
-	"github.com/mailru/easyjson"
 	"github.com/valyala/fasthttp"
 )
 
 type ServiceHandlersI interface {
 	Status(ctx *fasthttp.RequestCtx)
 	Clear(ctx *fasthttp.RequestCtx)
+	PoolStats(ctx *fasthttp.RequestCtx)
 }
 
 type serviceH struct {
-	serviceRepo repository.ServiceRepoI
+	service usecase.ServiceUsecaseI
 }
 
-func NewServiceH(s repository.ServiceRepoI) ServiceHandlersI {
-	return &serviceH{serviceRepo: s}
+func NewServiceH(s usecase.ServiceUsecaseI) ServiceHandlersI {
+	return &serviceH{service: s}
 }
 
 func (h *serviceH) Status(ctx *fasthttp.RequestCtx) {
-	status, err := h.serviceRepo.Status()
+	reqCtx := requestContext(ctx)
+
+	status, err := h.service.Status(reqCtx)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusOK)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := easyjson.Marshal(status)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, status)
 }
 
 func (h *serviceH) Clear(ctx *fasthttp.RequestCtx) {
-	if err := h.serviceRepo.Clear(); err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusInternalServerError)
+	reqCtx := requestContext(ctx)
+
+	if err := h.service.Clear(reqCtx); err != nil {
+		writeError(ctx, err)
+		return
 	}
 
 	ctx.SetContentType("application/json")
 	ctx.SetStatusCode(http.StatusOK)
 }
+
+func (h *serviceH) PoolStats(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	writeJSON(ctx, http.StatusOK, h.service.PoolStats(reqCtx))
+}
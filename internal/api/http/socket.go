@@ -0,0 +1,221 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/usecase"
+
+	"github.com/fasthttp/websocket"
+	"github.com/mailru/easyjson"
+	"github.com/valyala/fasthttp"
+)
+
+type SocketHandlersI interface {
+	ForumActivity(ctx *fasthttp.RequestCtx)
+}
+
+// SocketAuth holds the hooks every activity socket goes through. Nil hooks
+// allow everything.
+type SocketAuth struct {
+	// Connect runs before the upgrade and names who connects. An error
+	// refuses the socket like any other request.
+	Connect func(ctx *fasthttp.RequestCtx) (identity string, err error)
+	// Subscribe runs for every subscribe message once the forum is found,
+	// an error is sent back instead of following the forum.
+	Subscribe func(ctx context.Context, identity string, forum models.Forum) error
+}
+
+type SocketConfig struct {
+	Follow usecase.FollowOptions
+	// PingInterval is how often the socket is pinged. A socket that sends
+	// nothing, pongs included, for two intervals is closed.
+	PingInterval time.Duration
+	// RequestTimeout bounds the work of one subscribe message.
+	RequestTimeout time.Duration
+	Auth           SocketAuth
+}
+
+const (
+	socketWriteWait  = 10 * time.Second
+	maxSocketMessage = 4096
+)
+
+// Types of models.SocketMessage besides the update kinds.
+const (
+	socketSubscribe    = "subscribe"
+	socketUnsubscribe  = "unsubscribe"
+	socketSubscribed   = "subscribed"
+	socketUnsubscribed = "unsubscribed"
+	socketError        = "error"
+)
+
+type socketH struct {
+	forums   usecase.ForumUsecaseI
+	feed     usecase.FeedUsecaseI
+	conf     SocketConfig
+	upgrader websocket.FastHTTPUpgrader
+}
+
+func NewSocketH(f usecase.ForumUsecaseI, feed usecase.FeedUsecaseI, conf SocketConfig) SocketHandlersI {
+	return &socketH{forums: f, feed: feed, conf: conf}
+}
+
+// ForumActivity upgrades to a socket that follows any number of forums.
+// Like thread streams the socket outlives the request context; it ends
+// when the client goes away, falls behind with slow_consumer=disconnect
+// or the feed stops.
+func (h *socketH) ForumActivity(ctx *fasthttp.RequestCtx) {
+	if !websocket.FastHTTPIsWebSocketUpgrade(ctx) {
+		writeMessage(ctx, http.StatusBadRequest, "expected a websocket upgrade")
+		return
+	}
+
+	var identity string
+	if h.conf.Auth.Connect != nil {
+		var err error
+		if identity, err = h.conf.Auth.Connect(ctx); err != nil {
+			writeError(ctx, err)
+			return
+		}
+	}
+
+	sub, err := h.feed.Follow(requestContext(ctx), h.conf.Follow)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	// The messages of the socket are logged with the request id of the
+	// upgrade, long after its deadline.
+	base := context.WithoutCancel(requestContext(ctx))
+	err = h.upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
+		h.serve(base, conn, sub, identity)
+	})
+	if err != nil {
+		// the upgrader answered already
+		sub.Close()
+	}
+}
+
+// serve is the only writer of conn. Replies to the messages read by read
+// and updates go out in the order they come in.
+func (h *socketH) serve(base context.Context, conn *websocket.Conn, sub *usecase.ForumSubscription, identity string) {
+	defer sub.Close()
+
+	replies := make(chan models.SocketMessage)
+	done := make(chan struct{})
+	go h.read(base, conn, sub, identity, replies, done)
+	defer func() {
+		// fasthttp reuses conn once serve returns, so the reader has to be
+		// gone by then.
+		close(done)
+		_ = conn.SetReadDeadline(time.Now())
+		for range replies {
+		}
+	}()
+
+	ping := time.NewTicker(h.conf.PingInterval)
+	defer ping.Stop()
+	for {
+		var msg models.SocketMessage
+		select {
+		case u, ok := <-sub.Updates:
+			if !ok {
+				closeSocket(conn, sub.Err())
+				return
+			}
+			msg = models.SocketMessage{Type: u.Kind, Forum: u.Forum, Thread: u.Thread, Post: u.Post, User: u.User, Missed: u.Missed}
+		case r, ok := <-replies:
+			if !ok {
+				return
+			}
+			msg = r
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				return
+			}
+			continue
+		}
+
+		data, _ := easyjson.Marshal(msg)
+		_ = conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+	}
+}
+
+// read handles the messages of the client until it goes away or stays
+// silent for two ping intervals.
+func (h *socketH) read(base context.Context, conn *websocket.Conn, sub *usecase.ForumSubscription, identity string, replies chan<- models.SocketMessage, done <-chan struct{}) {
+	defer close(replies)
+
+	alive := func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.conf.PingInterval))
+	}
+	_ = alive("")
+	conn.SetPongHandler(alive)
+	conn.SetReadLimit(maxSocketMessage)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = alive("")
+
+		var reply, msg models.SocketMessage
+		if err = easyjson.Unmarshal(data, &msg); err != nil {
+			reply = errorMessage(base, "", models.Validation("invalid message: %s", err))
+		} else {
+			reply = h.handle(base, sub, identity, msg)
+		}
+
+		select {
+		case replies <- reply:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (h *socketH) handle(base context.Context, sub *usecase.ForumSubscription, identity string, msg models.SocketMessage) models.SocketMessage {
+	switch msg.Type {
+	case socketSubscribe:
+		ctx, cancel := context.WithTimeout(base, h.conf.RequestTimeout)
+		defer cancel()
+
+		forum, err := h.forums.Get(ctx, msg.Forum)
+		if err == nil && h.conf.Auth.Subscribe != nil {
+			err = h.conf.Auth.Subscribe(ctx, identity, forum)
+		}
+		if err == nil {
+			err = sub.Subscribe(forum)
+		}
+		if err != nil {
+			return errorMessage(ctx, msg.Forum, err)
+		}
+		return models.SocketMessage{Type: socketSubscribed, Forum: forum.Slug}
+	case socketUnsubscribe:
+		sub.Unsubscribe(msg.Forum)
+		return models.SocketMessage{Type: socketUnsubscribed, Forum: msg.Forum}
+	}
+	return errorMessage(base, msg.Forum, models.Validation("unknown message type %q, want %s or %s", msg.Type, socketSubscribe, socketUnsubscribe))
+}
+
+func errorMessage(ctx context.Context, forum string, err error) models.SocketMessage {
+	status, message := errorResponse(ctx, err)
+	return models.SocketMessage{Type: socketError, Forum: forum, Status: status, Message: message}
+}
+
+// closeSocket tells the client why its updates ended.
+func closeSocket(conn *websocket.Conn, err error) {
+	code, text := websocket.CloseGoingAway, "updates stopped, subscribe again"
+	if errors.Is(err, usecase.ErrSlowConsumer) {
+		code, text = websocket.ClosePolicyViolation, "too slow to read the updates"
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(socketWriteWait))
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"bufio"
+	"strconv"
+	"time"
+
+	"park_db_course/internal/models"
+
+	"github.com/mailru/easyjson"
+	"github.com/valyala/fasthttp"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment, so that
+// proxies keep the connection and a gone client is noticed.
+const streamHeartbeat = 15 * time.Second
+
+// Stream pushes the changes of a thread as server-sent events: the thread
+// first, then post, post_edit, thread and votes events. New posts carry
+// their id as the event id, so a client reconnecting with Last-Event-ID
+// gets the posts it missed. A thread_delete event ends the stream.
+//
+// The response is written after the handler returned, so the stream does
+// not use the request context: it ends when the client goes away or the
+// feed closes the subscription, e.g. on shutdown.
+func (h *threadH) Stream(ctx *fasthttp.RequestCtx) {
+	lastPost, _ := strconv.ParseInt(paramString(ctx, "Last-Event-ID"), 10, 64)
+
+	sub, err := h.feed.Watch(requestContext(ctx), ctx.UserValue("slug_or_id").(string), lastPost)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	// nginx buffers responses unless told otherwise
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case u, ok := <-sub.Updates:
+				if !ok {
+					return
+				}
+				writeEvent(w, u)
+				if u.Kind == models.EventThreadDelete {
+					_ = w.Flush()
+					return
+				}
+			case <-heartbeat.C:
+				_, _ = w.WriteString(": ping\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func writeEvent(w *bufio.Writer, u models.ThreadUpdate) {
+	var data []byte
+	if u.Post != nil {
+		data, _ = easyjson.Marshal(u.Post)
+	} else {
+		data, _ = easyjson.Marshal(u.Thread)
+	}
+
+	if u.Kind == models.EventPost {
+		_, _ = w.WriteString("id: " + strconv.FormatInt(u.Post.Id, 10) + "\n")
+	}
+	_, _ = w.WriteString("event: " + u.Kind + "\ndata: ")
+	_, _ = w.Write(data)
+	_, _ = w.WriteString("\n\n")
+}
@@ -4,8 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"park_db_course/internal/models"
-	"park_db_course/internal/repository"
-	"strconv"
+	"park_db_course/internal/usecase"
 
 	"github.com/mailru/easyjson"
 	"github.com/valyala/fasthttp"
@@ -17,287 +16,133 @@ type ThreadHandlersI interface {
 	Details(ctx *fasthttp.RequestCtx)
 	ThreadPost(ctx *fasthttp.RequestCtx)
 	Update(ctx *fasthttp.RequestCtx)
+	Stream(ctx *fasthttp.RequestCtx)
+	State(ctx *fasthttp.RequestCtx)
+	Delete(ctx *fasthttp.RequestCtx)
 }
 
 type threadH struct {
-	threadRepo repository.ThreadRepoI
-	userRepo   repository.UserRepoI
+	threads usecase.ThreadUsecaseI
+	feed    usecase.FeedUsecaseI
 }
 
-func NewThreadH(t repository.ThreadRepoI, u repository.UserRepoI) ThreadHandlersI {
-	return &threadH{threadRepo: t, userRepo: u}
+func NewThreadH(t usecase.ThreadUsecaseI, f usecase.FeedUsecaseI) ThreadHandlersI {
+	return &threadH{threads: t, feed: f}
 }
 
 func (h *threadH) CreatePost(ctx *fasthttp.RequestCtx) {
-	slugOrId, ok := ctx.UserValue("slug_or_id").(string)
-	if !ok {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "wrong slug_or_id format"})
-		ctx.SetBody(body)
-	}
+	reqCtx := requestContext(ctx)
 
-	thread, err := h.threadRepo.GetBySlugOrId(slugOrId)
+	var posts []models.PostReq
+	err := json.Unmarshal(ctx.PostBody(), &posts)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find post thread by id: " + slugOrId})
-		ctx.SetBody(body)
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
 
-	var posts models.PostsReq
-	err = json.Unmarshal(ctx.PostBody(), &posts.Posts)
+	created, err := h.threads.AddPosts(reqCtx, ctx.UserValue("slug_or_id").(string), posts)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
-
-		return
-	}
-
-	if len(posts.Posts) == 0 {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusCreated)
-		body, _ := json.Marshal(posts.Posts)
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	for _, item := range posts.Posts {
-		_, err := h.userRepo.GetByNickname(item.Author)
-		if err != nil {
-			ctx.SetContentType("application/json")
-			ctx.SetStatusCode(http.StatusNotFound)
-			body, _ := easyjson.Marshal(models.MessageError{Message: "user by nickname:"})
-			ctx.SetBody(body)
-			return
-		}
-
-		if item.Parent != 0 {
-			err = h.threadRepo.CheckPost(item.Parent, thread.Id)
-			if err != nil {
-				ctx.SetContentType("application/json")
-				ctx.SetStatusCode(http.StatusConflict)
-				body, _ := easyjson.Marshal(models.MessageError{Message: "post exists"})
-				ctx.SetBody(body)
-				return
-			}
-		}
-	}
-
-	response, err := h.threadRepo.CreatePosts(thread, posts)
-	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusConflict)
-		body, _ := easyjson.Marshal(models.MessageError{Message: err.Error()})
-		ctx.SetBody(body)
-		return
-	}
-
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusCreated)
-	body, _ := json.Marshal(response.Posts)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusCreated, created)
 }
 
 func (h *threadH) CreateVote(ctx *fasthttp.RequestCtx) {
-	slugOrId, ok := ctx.UserValue("slug_or_id").(string)
-	if !ok {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "wrong slug_or_id format"})
-		ctx.SetBody(body)
-	}
-
-	thread, err := h.threadRepo.GetBySlugOrId(slugOrId)
-	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find thread by slug:  " + slugOrId})
-		ctx.SetBody(body)
-		return
-	}
+	reqCtx := requestContext(ctx)
 
 	var vote models.VoteRequest
-	err = easyjson.Unmarshal(ctx.PostBody(), &vote)
+	err := easyjson.Unmarshal(ctx.PostBody(), &vote)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
 
-	checkUser, err := h.userRepo.GetByNickname(vote.Nickname)
+	thread, err := h.threads.Vote(reqCtx, ctx.UserValue("slug_or_id").(string), vote)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "user by nickname:"})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	vote1, err := h.threadRepo.CheckVotes(checkUser.Id, thread.Id)
-	if err == nil && vote.Voice == vote1.Voice {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusOK)
-		body, _ := easyjson.Marshal(thread)
-		ctx.SetBody(body)
-		return
-	}
-	if err != nil {
-		err = h.threadRepo.CreateVote(checkUser.Id, vote, thread)
-		if err != nil {
-			ctx.SetContentType("application/json")
-			ctx.SetStatusCode(http.StatusNotFound)
-			return
-		}
-
-		thread.Votes += vote.Voice
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusOK)
-		body, _ := easyjson.Marshal(thread)
-		ctx.SetBody(body)
-	} else {
-		_, err = h.threadRepo.UpdateVote(vote, vote1.Id)
-		if err == nil {
-			thread.Votes += 2 * vote.Voice
-			ctx.SetContentType("application/json")
-			ctx.SetStatusCode(http.StatusOK)
-			body, _ := easyjson.Marshal(thread)
-			ctx.SetBody(body)
-			return
-		}
-	}
+	writeJSON(ctx, http.StatusOK, thread)
 }
 
 func (h *threadH) Details(ctx *fasthttp.RequestCtx) {
-	slugOrId, ok := ctx.UserValue("slug_or_id").(string)
-	if !ok {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "wrong slug_or_id format"})
-		ctx.SetBody(body)
-	}
+	reqCtx := requestContext(ctx)
 
-	thread, err := h.threadRepo.GetBySlugOrId(slugOrId)
+	thread, err := h.threads.Get(reqCtx, ctx.UserValue("slug_or_id").(string))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find thread by slug: " + slugOrId})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	body, _ := easyjson.Marshal(thread)
-	ctx.SetStatusCode(http.StatusOK)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, thread)
 }
 
 func (h *threadH) ThreadPost(ctx *fasthttp.RequestCtx) {
-	slugOrId, ok := ctx.UserValue("slug_or_id").(string)
-	if !ok {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "wrong slug_or_id format"})
-		ctx.SetBody(body)
-	}
+	reqCtx := requestContext(ctx)
 
-	thread, err := h.threadRepo.GetBySlugOrId(slugOrId)
+	sort := paramString(ctx, "sort")
+	limit := paramInt(ctx, "limit")
+	since := paramString(ctx, "since")
+	desc := paramBool(ctx, "desc")
+
+	posts, err := h.threads.Posts(reqCtx, ctx.UserValue("slug_or_id").(string), since, sort, limit, desc)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find thread by slug: " + slugOrId})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	sort := string(ctx.FormValue("sort"))
-	if sort == "" {
-		sort = "flat"
-	}
-
-	limit := 0
-	if limitVal := string(ctx.FormValue("limit")); limitVal != "" {
-		limit, err = strconv.Atoi(limitVal)
-		if err != nil {
-			ctx.SetContentType("application/json")
-			ctx.SetStatusCode(http.StatusBadRequest)
-			body, _ := easyjson.Marshal(models.MessageError{Message: "wrong limit format"})
-			ctx.SetBody(body)
-		}
-	} else {
-		limit = 100
-	}
+	writeJSON(ctx, http.StatusOK, posts)
+}
 
-	since := string(ctx.FormValue("since"))
+func (h *threadH) Update(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
 
-	desc := false
-	if string(ctx.FormValue("desc")) == "true" {
-		desc = true
+	var upd models.ThreadUpdateReq
+	err := easyjson.Unmarshal(ctx.PostBody(), &upd)
+	if err != nil {
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
 	}
 
-	posts, err := h.threadRepo.GetThreadPosts(thread, since, sort, limit, desc)
+	thread, err := h.threads.Update(reqCtx, ctx.UserValue("slug_or_id").(string), upd)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := json.Marshal(posts)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, thread)
 }
 
-func (h *threadH) Update(ctx *fasthttp.RequestCtx) {
-	slugOrId, ok := ctx.UserValue("slug_or_id").(string)
-	if !ok {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "wrong slug_or_id format"})
-		ctx.SetBody(body)
-	}
+// State closes or opens and pins or unpins the thread.
+func (h *threadH) State(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
 
-	thread, err := h.threadRepo.GetBySlugOrId(slugOrId)
+	var state models.ThreadState
+	err := easyjson.Unmarshal(ctx.PostBody(), &state)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find thread by slug: " + slugOrId})
-		ctx.SetBody(body)
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
 
-	var updateThread models.ThreadUpdateReq
-	err = easyjson.Unmarshal(ctx.PostBody(), &updateThread)
+	thread, err := h.threads.SetState(reqCtx, ctx.UserValue("slug_or_id").(string), state)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
+		writeError(ctx, err)
 		return
 	}
 
-	if updateThread.Title == "" && updateThread.Message == "" {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusOK)
-		body, _ := easyjson.Marshal(thread)
-		ctx.SetBody(body)
-		return
-	}
-	if updateThread.Title == "" {
-		updateThread.Title = thread.Title
-	}
-	if updateThread.Message == "" {
-		updateThread.Message = thread.Message
-	}
+	writeJSON(ctx, http.StatusOK, thread)
+}
 
-	thread, err = h.threadRepo.Update(thread, updateThread)
-	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
+// Delete answers 204 once the thread is gone with its posts and votes.
+func (h *threadH) Delete(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	if err := h.threads.Delete(reqCtx, ctx.UserValue("slug_or_id").(string)); err != nil {
+		writeError(ctx, err)
 		return
 	}
-
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := easyjson.Marshal(thread)
-	ctx.SetBody(body)
+	ctx.SetStatusCode(http.StatusNoContent)
 }
@@ -1,10 +1,10 @@
 package http
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 	"park_db_course/internal/models"
-	"park_db_course/internal/repository"
+	"park_db_course/internal/usecase"
 
 	"github.com/mailru/easyjson"
 	"github.com/valyala/fasthttp"
@@ -14,107 +14,111 @@ type UserHandlersI interface {
 	Create(ctx *fasthttp.RequestCtx)
 	GetByNickname(ctx *fasthttp.RequestCtx)
 	Update(ctx *fasthttp.RequestCtx)
+	SetRole(ctx *fasthttp.RequestCtx)
+	SetPassword(ctx *fasthttp.RequestCtx)
 }
 
 type userH struct {
-	userRepo repository.UserRepoI
+	users usecase.UserUsecaseI
 }
 
-func NewUserH(u repository.UserRepoI) UserHandlersI {
+func NewUserH(u usecase.UserUsecaseI) UserHandlersI {
 	return &userH{
-		userRepo: u,
+		users: u,
 	}
 }
 
 func (h *userH) Create(ctx *fasthttp.RequestCtx) {
-	var user models.User
+	reqCtx := requestContext(ctx)
 
-	err := easyjson.Unmarshal(ctx.PostBody(), &user)
+	var req models.UserCreate
+
+	err := easyjson.Unmarshal(ctx.PostBody(), &req)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
-	user.Nickname = ctx.UserValue("nickname").(string)
-
-	users, err := h.userRepo.GetByEmailOrNick(user.Email, user.Nickname)
-	if err == nil && len(users) > 0 {
-		ctx.SetContentType("application/json")
-		body, _ := json.Marshal(users)
-		ctx.SetStatusCode(http.StatusConflict)
-		ctx.SetBody(body)
+	req.Nickname = ctx.UserValue("nickname").(string)
+
+	user, existing, err := h.users.Create(reqCtx, req.User, req.Password)
+	if errors.Is(err, models.ErrConflict) && len(existing) > 0 {
+		writeJSON(ctx, http.StatusConflict, existing)
 		return
 	}
-
-	_, err = h.userRepo.Create(user)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusInternalServerError)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusCreated)
-	body, _ := easyjson.Marshal(user)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusCreated, user)
 }
 
 func (h *userH) GetByNickname(ctx *fasthttp.RequestCtx) {
-	user, err := h.userRepo.GetByNickname(ctx.UserValue("nickname").(string))
+	reqCtx := requestContext(ctx)
 
+	user, err := h.users.Get(reqCtx, ctx.UserValue("nickname").(string))
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find user by nickname:"})
-		ctx.SetBody(body)
+		writeError(ctx, err)
 		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := easyjson.Marshal(user)
-	ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, user)
 }
 
 func (h *userH) Update(ctx *fasthttp.RequestCtx) {
-	newUserData, err := h.userRepo.GetByNickname(ctx.UserValue("nickname").(string))
+	reqCtx := requestContext(ctx)
+
+	var upd models.UserUpdate
+	err := easyjson.Unmarshal(ctx.PostBody(), &upd)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "Can't find user by nickname:"})
-		ctx.SetBody(body)
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
 
-	err = json.Unmarshal(ctx.PostBody(), &newUserData)
+	user, err := h.users.UpdateProfile(reqCtx, ctx.UserValue("nickname").(string), upd)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusBadRequest)
+		writeError(ctx, err)
 		return
 	}
 
-	checkUser, err := h.userRepo.GetByEmail(newUserData.Email)
-	if checkUser.Nickname != "" && checkUser.Nickname != newUserData.Nickname {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusConflict)
-		body, _ := easyjson.Marshal(models.MessageError{Message: "This email is already registered by user " + checkUser.Nickname})
-		ctx.SetBody(body)
+	writeJSON(ctx, http.StatusOK, user)
+}
+
+func (h *userH) SetRole(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	var role models.SiteRole
+	err := easyjson.Unmarshal(ctx.PostBody(), &role)
+	if err != nil {
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
+	role.Nickname = ctx.UserValue("nickname").(string)
 
-	user, err := h.userRepo.Update(newUserData)
+	role, err = h.users.SetRole(reqCtx, role)
 	if err != nil {
-		ctx.SetContentType("application/json")
-		ctx.SetStatusCode(http.StatusNotFound)
+		writeError(ctx, err)
 		return
 	}
 
-	if newUserData.About == "" {
-		user.About = newUserData.About
+	writeJSON(ctx, http.StatusOK, role)
+}
+
+func (h *userH) SetPassword(ctx *fasthttp.RequestCtx) {
+	reqCtx := requestContext(ctx)
+
+	var creds models.Credentials
+	err := easyjson.Unmarshal(ctx.PostBody(), &creds)
+	if err != nil {
+		writeMessage(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	err = h.users.SetPassword(reqCtx, ctx.UserValue("nickname").(string), creds.Password)
+	if err != nil {
+		writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	ctx.SetStatusCode(http.StatusOK)
-	body, _ := easyjson.Marshal(user)
-	ctx.SetBody(body)
+	ctx.SetStatusCode(http.StatusNoContent)
 }
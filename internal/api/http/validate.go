@@ -0,0 +1,267 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"park_db_course/internal/models"
+
+	"github.com/fasthttp/router"
+	oaerrors "github.com/go-openapi/errors"
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/swag"
+	"github.com/go-openapi/validate"
+	"github.com/valyala/fasthttp"
+)
+
+type requestParamsKey struct{}
+
+// LoadSpec parses a swagger 2.0 document in yaml or json and resolves
+// every $ref in it.
+func LoadSpec(data []byte) (*loads.Document, error) {
+	yamlDoc, err := swag.BytesToYAMLDoc(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	raw, err := swag.YAMLToJSON(yamlDoc)
+	if err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	doc, err := loads.Analyzed(raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("load spec: %w", err)
+	}
+	return doc.Expanded()
+}
+
+// Validator checks requests against the operations of an OpenAPI spec
+// before they reach a handler. Query values, with the spec defaults
+// applied, are then available to handlers through the param* helpers.
+type Validator struct {
+	basePath   string
+	operations map[string]*operationValidator // "GET /forum/{slug}/threads"
+}
+
+type operationValidator struct {
+	id     string
+	params []paramValidator
+	body   *bodyValidator
+}
+
+type paramValidator struct {
+	param     spec.Parameter
+	validator *validate.ParamValidator
+}
+
+type bodyValidator struct {
+	name      string
+	required  bool
+	schema    *spec.Schema
+	validator *validate.SchemaValidator
+}
+
+// NewValidator prepares validators for every operation of doc.
+func NewValidator(doc *loads.Document) *Validator {
+	v := &Validator{basePath: doc.BasePath(), operations: map[string]*operationValidator{}}
+	for path, item := range doc.Spec().Paths.Paths {
+		for method, op := range map[string]*spec.Operation{
+			http.MethodGet: item.Get, http.MethodPost: item.Post, http.MethodPut: item.Put, http.MethodDelete: item.Delete,
+		} {
+			if op == nil {
+				continue
+			}
+			opV := &operationValidator{id: op.ID}
+			for _, p := range op.Parameters {
+				switch p.In {
+				case "body":
+					opV.body = &bodyValidator{
+						name:      p.Name,
+						required:  p.Required,
+						schema:    p.Schema,
+						validator: validate.NewSchemaValidator(p.Schema, nil, p.Name, strfmt.Default),
+					}
+				case "path", "query", "header":
+					p := p
+					opV.params = append(opV.params, paramValidator{param: p, validator: validate.NewParamValidator(&p, strfmt.Default)})
+				}
+			}
+			v.operations[method+" "+path] = opV
+		}
+	}
+	return v
+}
+
+// Check wraps a handler registered on r. The operation is looked up by the
+// route the router matched, so r must have SaveMatchedRoutePath set.
+// Requests that do not match the spec get 400 with a MessageError listing
+// every offending field; routes the spec does not describe pass through.
+func (v *Validator) Check(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		_, op := v.operation(ctx)
+		if op == nil {
+			next(ctx)
+			return
+		}
+
+		params := map[string]string{}
+		var details []models.FieldError
+		for _, p := range op.params {
+			raw, ok := p.lookup(ctx)
+			if !ok {
+				if p.param.Default != nil {
+					params[p.param.Name] = fmt.Sprint(p.param.Default)
+				}
+				if p.param.Required {
+					details = append(details, models.FieldError{Field: p.param.Name, Message: p.param.Name + " in " + p.param.In + " is required"})
+				}
+				continue
+			}
+			params[p.param.Name] = raw
+			details = append(details, p.validate(raw)...)
+		}
+		if op.body != nil {
+			details = append(details, op.body.validate(ctx.PostBody())...)
+		}
+
+		if len(details) > 0 {
+			writeJSON(ctx, http.StatusBadRequest, models.MessageError{Message: "request does not match the API spec", Details: details})
+			return
+		}
+
+		ctx.SetUserValue(requestParamsKey{}, params)
+		next(ctx)
+	}
+}
+
+// Operation names the route a request matched by its operationId. Routes
+// the spec does not describe are named by their path, "" means no route
+// matched.
+func (v *Validator) Operation(ctx *fasthttp.RequestCtx) string {
+	route, op := v.operation(ctx)
+	if op != nil && op.id != "" {
+		return op.id
+	}
+	return route
+}
+
+func (v *Validator) operation(ctx *fasthttp.RequestCtx) (string, *operationValidator) {
+	route, _ := ctx.UserValue(router.MatchedRoutePathParam).(string)
+	return route, v.operations[string(ctx.Method())+" "+strings.TrimPrefix(route, v.basePath)]
+}
+
+// lookup returns the raw value of the parameter. Empty query values count
+// as absent, as they always did for since and related, and so do empty
+// headers.
+func (p paramValidator) lookup(ctx *fasthttp.RequestCtx) (string, bool) {
+	var raw string
+	switch p.param.In {
+	case "path":
+		raw, ok := ctx.UserValue(p.param.Name).(string)
+		return raw, ok
+	case "header":
+		raw = string(ctx.Request.Header.Peek(p.param.Name))
+	default:
+		raw = string(ctx.QueryArgs().Peek(p.param.Name))
+	}
+	return raw, raw != ""
+}
+
+func (p paramValidator) validate(raw string) []models.FieldError {
+	var value interface{} = raw
+	var err error
+	switch p.param.Type {
+	case "number", "integer":
+		value, err = strconv.ParseFloat(raw, 64)
+	case "boolean":
+		value, err = strconv.ParseBool(raw)
+	case "array":
+		items := strings.Split(raw, ",")
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			values[i] = item
+		}
+		value = values
+	}
+	if err != nil {
+		return []models.FieldError{fieldError(p.param.Name, oaerrors.InvalidType(p.param.Name, p.param.In, p.param.Type, raw))}
+	}
+	return fieldErrors(p.param.Name, p.validator.Validate(value))
+}
+
+func (b *bodyValidator) validate(body []byte) []models.FieldError {
+	if len(body) == 0 {
+		if b.required {
+			return []models.FieldError{{Field: b.name, Message: b.name + " in body is required"}}
+		}
+		return nil
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return []models.FieldError{{Field: b.name, Message: "invalid JSON: " + err.Error()}}
+	}
+	// The schema validator loses the index of array items in the names it
+	// reports, so items of a list are checked one by one.
+	if items, ok := data.([]interface{}); ok && b.schema.Items != nil && b.schema.Items.Schema != nil {
+		var details []models.FieldError
+		for i, item := range items {
+			name := b.name + "." + strconv.Itoa(i)
+			res := validate.NewSchemaValidator(b.schema.Items.Schema, nil, name, strfmt.Default).Validate(item)
+			details = append(details, fieldErrors(name, res)...)
+		}
+		return details
+	}
+	return fieldErrors(b.name, b.validator.Validate(data))
+}
+
+func fieldErrors(name string, res *validate.Result) []models.FieldError {
+	if res == nil || res.IsValid() {
+		return nil
+	}
+	details := make([]models.FieldError, 0, len(res.Errors))
+	for _, err := range res.Errors {
+		details = append(details, fieldError(name, err))
+	}
+	return details
+}
+
+// fieldError names the field by its path inside the parameter, e.g.
+// posts.1.author for the author of the second post of a batch.
+func fieldError(name string, err error) models.FieldError {
+	field := name
+	if verr, ok := err.(*oaerrors.Validation); ok && verr.Name != "" && verr.Name != "." {
+		field = verr.Name
+		if field != name && !strings.HasPrefix(field, name+".") {
+			field = name + "." + strings.TrimPrefix(field, ".")
+		}
+	}
+	return models.FieldError{Field: field, Message: err.Error()}
+}
+
+// paramString returns a parameter checked by the Validator, or the spec
+// default when the request has none.
+func paramString(ctx *fasthttp.RequestCtx, name string) string {
+	params, _ := ctx.UserValue(requestParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+func paramInt(ctx *fasthttp.RequestCtx, name string) int {
+	n, _ := strconv.ParseFloat(paramString(ctx, name), 64)
+	return int(n)
+}
+
+func paramBool(ctx *fasthttp.RequestCtx, name string) bool {
+	b, _ := strconv.ParseBool(paramString(ctx, name))
+	return b
+}
+
+func paramStrings(ctx *fasthttp.RequestCtx, name string) []string {
+	if raw := paramString(ctx, name); raw != "" {
+		return strings.Split(raw, ",")
+	}
+	return nil
+}
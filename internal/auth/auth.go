@@ -0,0 +1,147 @@
+// Package auth issues and checks the bearer tokens users act with, hashes
+// their passwords and carries the authenticated caller of a request in its
+// context.
+//
+// A token is "v1.<claims>.<mac>": base64url JSON claims and their
+// HMAC-SHA256 under the configured secret. Any instance holding the secret
+// verifies it without a lookup, so tokens cannot be revoked before they
+// expire; keep auth.token_ttl short enough for that.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"park_db_course/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const tokenVersion = "v1"
+
+var (
+	errMalformed = models.Unauthorized("malformed token")
+	errSignature = models.Unauthorized("invalid token signature")
+	errExpired   = models.Unauthorized("token expired")
+)
+
+type claims struct {
+	Subject  string `json:"sub"`
+	IssuedAt int64  `json:"iat"`
+	Expires  int64  `json:"exp"`
+}
+
+// Signer issues and verifies the tokens of one secret.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewSigner returns a signer of tokens valid for ttl.
+func NewSigner(secret []byte, ttl time.Duration) *Signer {
+	return &Signer{secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Issue returns a token for the user nickname.
+func (s *Signer) Issue(nickname string) models.Token {
+	now := s.now()
+	expires := now.Add(s.ttl)
+	payload, _ := json.Marshal(claims{Subject: nickname, IssuedAt: now.Unix(), Expires: expires.Unix()})
+
+	head := tokenVersion + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return models.Token{
+		Token:   head + "." + base64.RawURLEncoding.EncodeToString(s.mac(head)),
+		Expires: time.Unix(expires.Unix(), 0).UTC(),
+	}
+}
+
+// Verify returns the caller a token was issued to. Tokens that are
+// malformed, signed with another secret or expired are ErrUnauthorized.
+func (s *Signer) Verify(token string) (Caller, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenVersion {
+		return Caller{}, errMalformed
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Caller{}, errMalformed
+	}
+	if !hmac.Equal(mac, s.mac(parts[0]+"."+parts[1])) {
+		return Caller{}, errSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Caller{}, errMalformed
+	}
+	var c claims
+	if err = json.Unmarshal(payload, &c); err != nil || c.Subject == "" {
+		return Caller{}, errMalformed
+	}
+	if !s.now().Before(time.Unix(c.Expires, 0)) {
+		return Caller{}, errExpired
+	}
+	return Caller{Nickname: c.Subject}, nil
+}
+
+func (s *Signer) mac(head string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(head))
+	return h.Sum(nil)
+}
+
+// HashPassword returns the bcrypt hash of password at cost.
+func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", models.Validation("password is longer than 72 bytes")
+	}
+	return string(hash), err
+}
+
+// CheckPassword reports whether password is the one hash was made of.
+func CheckPassword(hash, password string) bool {
+	return hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Caller is who a request is made by.
+type Caller struct {
+	// Nickname is the user of the token, "" when none was sent.
+	Nickname string
+}
+
+func (c Caller) Anonymous() bool {
+	return c.Nickname == ""
+}
+
+type callerKey struct{}
+
+// WithCaller returns ctx carrying the caller authenticated by a transport.
+func WithCaller(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// CallerFrom returns the caller of ctx. ok is false when the request did not
+// go through authentication at all, i.e. auth is disabled and the nicknames
+// sent in requests are taken at their word.
+func CallerFrom(ctx context.Context) (c Caller, ok bool) {
+	c, ok = ctx.Value(callerKey{}).(Caller)
+	return c, ok
+}
+
+// BearerToken returns the token of an Authorization header value, "" if
+// it holds none.
+func BearerToken(header string) string {
+	const prefix = "Bearer "
+	if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+		return strings.TrimSpace(header[len(prefix):])
+	}
+	return ""
+}
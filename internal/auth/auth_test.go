@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"park_db_course/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestToken(t *testing.T) {
+	secret := []byte(strings.Repeat("k", 32))
+	s := NewSigner(secret, time.Hour)
+	token := s.Issue("j.sparrow")
+
+	caller, err := s.Verify(token.Token)
+	if err != nil || caller.Nickname != "j.sparrow" {
+		t.Fatalf("Verify = %+v, %v, want j.sparrow", caller, err)
+	}
+
+	head, mac, _ := strings.Cut(token.Token[len(tokenVersion)+1:], ".")
+	forged := NewSigner(secret, time.Hour)
+	forged.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	for name, tc := range map[string]struct {
+		signer *Signer
+		token  string
+	}{
+		"empty":          {s, ""},
+		"other version":  {s, "v2." + head + "." + mac},
+		"tampered":       {s, tokenVersion + "." + head + "x." + mac},
+		"other secret":   {NewSigner([]byte(strings.Repeat("x", 32)), time.Hour), token.Token},
+		"expired":        {forged, token.Token},
+		"not base64 mac": {s, tokenVersion + "." + head + ".!"},
+	} {
+		if _, err := tc.signer.Verify(tc.token); !errors.Is(err, models.ErrUnauthorized) {
+			t.Errorf("%s: Verify err = %v, want ErrUnauthorized", name, err)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("black pearl", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(hash, "black pearl") || CheckPassword(hash, "flying dutchman") || CheckPassword("", "") {
+		t.Error("CheckPassword accepts the wrong passwords")
+	}
+	if _, err = HashPassword(strings.Repeat("p", 73), bcrypt.MinCost); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("long password: err = %v, want ErrValidation", err)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	for header, want := range map[string]string{
+		"Bearer abc":  "abc",
+		"bearer  abc": "abc",
+		"Basic abc":   "",
+		"Bearer ":     "",
+		"":            "",
+	} {
+		if got := BearerToken(header); got != want {
+			t.Errorf("BearerToken(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// unmatched is the route of requests no route matched.
+const unmatched = "unmatched"
+
+// Instrument counts and times the requests handled by next. route names
+// the route of a request once next is done with it, "" when none matched.
+func (m *Metrics) Instrument(route func(ctx *fasthttp.RequestCtx) string, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+
+		name := route(ctx)
+		if name == "" {
+			name = unmatched
+		}
+		m.requestDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		m.requests.WithLabelValues(name, string(ctx.Method()), strconv.Itoa(ctx.Response.StatusCode())).Inc()
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WatchPool exports the statistics of the postgres pool, read on every
+// scrape.
+func (m *Metrics) WatchPool(pool *pgxpool.Pool) {
+	m.registry.MustRegister(poolCollector{stat: pool.Stat})
+}
+
+var (
+	poolConns = prometheus.NewDesc(namespace+"_db_pool_conns", "Connections of the pool by state: acquired, idle or constructing.", []string{"state"}, nil)
+	poolMax   = prometheus.NewDesc(namespace+"_db_pool_max_conns", "Most connections the pool opens.", nil, nil)
+
+	poolAcquires      = prometheus.NewDesc(namespace+"_db_pool_acquires_total", "Connections acquired from the pool.", nil, nil)
+	poolAcquireWait   = prometheus.NewDesc(namespace+"_db_pool_acquire_wait_seconds_total", "Time spent acquiring connections, divide by the acquires for the average wait.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total", "Acquires that had to wait for a connection.", nil, nil)
+	poolCanceled      = prometheus.NewDesc(namespace+"_db_pool_canceled_acquires_total", "Acquires canceled by their context.", nil, nil)
+)
+
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{poolConns, poolMax, poolAcquires, poolAcquireWait, poolEmptyAcquires, poolCanceled} {
+		ch <- d
+	}
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(s.AcquiredConns()), "acquired")
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(s.IdleConns()), "idle")
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(s.ConstructingConns()), "constructing")
+	ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceled, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+}
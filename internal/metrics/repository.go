@@ -0,0 +1,288 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+// observer times the calls of one repository.
+type observer struct {
+	m    *Metrics
+	repo string
+}
+
+// observe is deferred by every wrapped method with the time it started and
+// its named error.
+func (o observer) observe(method string, start time.Time, err *error) {
+	o.m.queryDuration.WithLabelValues(o.repo, method).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		o.m.queryErrors.WithLabelValues(o.repo, method, errorKind(*err)).Inc()
+	}
+}
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, models.ErrConflict):
+		return "conflict"
+	case errors.Is(err, models.ErrValidation):
+		return "validation"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return "timeout"
+	case errors.Is(err, models.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, models.ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, models.ErrForbidden):
+		return "forbidden"
+	}
+	return "other"
+}
+
+func (m *Metrics) UserRepo(next repository.UserRepoI) repository.UserRepoI {
+	return &userRepo{next: next, observer: observer{m: m, repo: "user"}}
+}
+
+type userRepo struct {
+	next repository.UserRepoI
+	observer
+}
+
+func (r *userRepo) Create(ctx context.Context, newUser models.User) (_ models.User, err error) {
+	defer r.observe("Create", time.Now(), &err)
+	return r.next.Create(ctx, newUser)
+}
+
+func (r *userRepo) GetByNickname(ctx context.Context, nickname string) (_ models.User, err error) {
+	defer r.observe("GetByNickname", time.Now(), &err)
+	return r.next.GetByNickname(ctx, nickname)
+}
+
+func (r *userRepo) GetCredentials(ctx context.Context, nickname string) (_ models.User, err error) {
+	defer r.observe("GetCredentials", time.Now(), &err)
+	return r.next.GetCredentials(ctx, nickname)
+}
+
+func (r *userRepo) SetRole(ctx context.Context, nickname string, role models.Role) (_ models.SiteRole, err error) {
+	defer r.observe("SetRole", time.Now(), &err)
+	return r.next.SetRole(ctx, nickname, role)
+}
+
+func (r *userRepo) SetPassword(ctx context.Context, nickname, passwordHash string) (err error) {
+	defer r.observe("SetPassword", time.Now(), &err)
+	return r.next.SetPassword(ctx, nickname, passwordHash)
+}
+
+func (r *userRepo) GetByNicknames(ctx context.Context, nicknames []string) (_ []models.User, err error) {
+	defer r.observe("GetByNicknames", time.Now(), &err)
+	return r.next.GetByNicknames(ctx, nicknames)
+}
+
+func (r *userRepo) GetByEmail(ctx context.Context, email string) (_ models.User, err error) {
+	defer r.observe("GetByEmail", time.Now(), &err)
+	return r.next.GetByEmail(ctx, email)
+}
+
+func (r *userRepo) GetByEmailOrNick(ctx context.Context, email, nickname string) (_ []*models.User, err error) {
+	defer r.observe("GetByEmailOrNick", time.Now(), &err)
+	return r.next.GetByEmailOrNick(ctx, email, nickname)
+}
+
+func (r *userRepo) Update(ctx context.Context, user models.User) (_ models.User, err error) {
+	defer r.observe("Update", time.Now(), &err)
+	return r.next.Update(ctx, user)
+}
+
+func (m *Metrics) ForumRepo(next repository.ForumRepoI) repository.ForumRepoI {
+	return &forumRepo{next: next, observer: observer{m: m, repo: "forum"}}
+}
+
+type forumRepo struct {
+	next repository.ForumRepoI
+	observer
+}
+
+func (r *forumRepo) Create(ctx context.Context, new models.ForumReq) (_ models.Forum, err error) {
+	defer r.observe("Create", time.Now(), &err)
+	return r.next.Create(ctx, new)
+}
+
+func (r *forumRepo) GetBySlug(ctx context.Context, slug string) (_ models.Forum, err error) {
+	defer r.observe("GetBySlug", time.Now(), &err)
+	return r.next.GetBySlug(ctx, slug)
+}
+
+func (r *forumRepo) GetBySlugs(ctx context.Context, slugs []string) (_ []models.Forum, err error) {
+	defer r.observe("GetBySlugs", time.Now(), &err)
+	return r.next.GetBySlugs(ctx, slugs)
+}
+
+func (r *forumRepo) GetThreads(ctx context.Context, slug, since string, limit int, desc bool) (_ []models.Thread, err error) {
+	defer r.observe("GetThreads", time.Now(), &err)
+	return r.next.GetThreads(ctx, slug, since, limit, desc)
+}
+
+func (r *forumRepo) GetUsers(ctx context.Context, forum models.Forum, since string, limit int, desc bool) (_ []models.User, err error) {
+	defer r.observe("GetUsers", time.Now(), &err)
+	return r.next.GetUsers(ctx, forum, since, limit, desc)
+}
+
+func (r *forumRepo) GetModerators(ctx context.Context, forum models.Forum) (_ []models.User, err error) {
+	defer r.observe("GetModerators", time.Now(), &err)
+	return r.next.GetModerators(ctx, forum)
+}
+
+func (r *forumRepo) IsModerator(ctx context.Context, forum models.Forum, nickname string) (_ bool, err error) {
+	defer r.observe("IsModerator", time.Now(), &err)
+	return r.next.IsModerator(ctx, forum, nickname)
+}
+
+func (r *forumRepo) AddModerator(ctx context.Context, forum models.Forum, user models.User) (err error) {
+	defer r.observe("AddModerator", time.Now(), &err)
+	return r.next.AddModerator(ctx, forum, user)
+}
+
+func (r *forumRepo) RemoveModerator(ctx context.Context, forum models.Forum, user models.User) (err error) {
+	defer r.observe("RemoveModerator", time.Now(), &err)
+	return r.next.RemoveModerator(ctx, forum, user)
+}
+
+// ThreadRepo also counts the threads, posts and votes created through it.
+func (m *Metrics) ThreadRepo(next repository.ThreadRepoI) repository.ThreadRepoI {
+	return &threadRepo{next: next, observer: observer{m: m, repo: "thread"}}
+}
+
+type threadRepo struct {
+	next repository.ThreadRepoI
+	observer
+}
+
+func (r *threadRepo) GetBySlugOrId(ctx context.Context, slug string) (_ models.Thread, err error) {
+	defer r.observe("GetBySlugOrId", time.Now(), &err)
+	return r.next.GetBySlugOrId(ctx, slug)
+}
+
+func (r *threadRepo) GetByIds(ctx context.Context, ids []int) (_ []models.Thread, err error) {
+	defer r.observe("GetByIds", time.Now(), &err)
+	return r.next.GetByIds(ctx, ids)
+}
+
+func (r *threadRepo) Create(ctx context.Context, new models.ThreadsReq) (t models.Thread, err error) {
+	defer r.observe("Create", time.Now(), &err)
+	if t, err = r.next.Create(ctx, new); err == nil {
+		r.m.threadsCreated.Inc()
+	}
+	return t, err
+}
+
+func (r *threadRepo) Update(ctx context.Context, old models.Thread, new models.ThreadUpdateReq) (_ models.Thread, err error) {
+	defer r.observe("Update", time.Now(), &err)
+	return r.next.Update(ctx, old, new)
+}
+
+func (r *threadRepo) CreatePosts(ctx context.Context, thread models.Thread, new models.PostsReq) (posts *models.Posts, err error) {
+	defer r.observe("CreatePosts", time.Now(), &err)
+	if posts, err = r.next.CreatePosts(ctx, thread, new); err == nil {
+		r.m.postsCreated.Add(float64(len(posts.Posts)))
+	}
+	return posts, err
+}
+
+func (r *threadRepo) CheckVotes(ctx context.Context, user, thread int) (_ models.Vote, err error) {
+	defer r.observe("CheckVotes", time.Now(), &err)
+	return r.next.CheckVotes(ctx, user, thread)
+}
+
+func (r *threadRepo) CreateVote(ctx context.Context, userId int, vote models.VoteRequest, thread models.Thread) (err error) {
+	defer r.observe("CreateVote", time.Now(), &err)
+	if err = r.next.CreateVote(ctx, userId, vote, thread); err == nil {
+		r.m.votesCast.Inc()
+	}
+	return err
+}
+
+func (r *threadRepo) UpdateVote(ctx context.Context, vote models.VoteRequest, voteId int) (id int, err error) {
+	defer r.observe("UpdateVote", time.Now(), &err)
+	if id, err = r.next.UpdateVote(ctx, vote, voteId); err == nil {
+		r.m.votesCast.Inc()
+	}
+	return id, err
+}
+
+func (r *threadRepo) GetThreadPosts(ctx context.Context, thread models.Thread, since, sort string, limit int, desc bool) (_ []models.Post, err error) {
+	defer r.observe("GetThreadPosts", time.Now(), &err)
+	return r.next.GetThreadPosts(ctx, thread, since, sort, limit, desc)
+}
+
+func (r *threadRepo) SetState(ctx context.Context, thread models.Thread, state models.ThreadState) (_ models.Thread, err error) {
+	defer r.observe("SetState", time.Now(), &err)
+	return r.next.SetState(ctx, thread, state)
+}
+
+func (r *threadRepo) Delete(ctx context.Context, thread models.Thread) (err error) {
+	defer r.observe("Delete", time.Now(), &err)
+	return r.next.Delete(ctx, thread)
+}
+
+func (m *Metrics) PostRepo(next repository.PostRepoI) repository.PostRepoI {
+	return &postRepo{next: next, observer: observer{m: m, repo: "post"}}
+}
+
+type postRepo struct {
+	next repository.PostRepoI
+	observer
+}
+
+func (r *postRepo) Get(ctx context.Context, id int, related []string) (_ models.PostFull, err error) {
+	defer r.observe("Get", time.Now(), &err)
+	return r.next.Get(ctx, id, related)
+}
+
+func (r *postRepo) Update(ctx context.Context, id int, new models.PostUpdateReq) (_ models.Post, err error) {
+	defer r.observe("Update", time.Now(), &err)
+	return r.next.Update(ctx, id, new)
+}
+
+func (r *postRepo) Delete(ctx context.Context, id int, hideAuthor bool) (_ models.Post, err error) {
+	defer r.observe("Delete", time.Now(), &err)
+	return r.next.Delete(ctx, id, hideAuthor)
+}
+
+func (r *postRepo) DeleteTree(ctx context.Context, id int) (_ int, err error) {
+	defer r.observe("DeleteTree", time.Now(), &err)
+	return r.next.DeleteTree(ctx, id)
+}
+
+func (r *postRepo) GetBatch(ctx context.Context, thread int, first, last int64) (_ []models.Post, err error) {
+	defer r.observe("GetBatch", time.Now(), &err)
+	return r.next.GetBatch(ctx, thread, first, last)
+}
+
+func (m *Metrics) ServiceRepo(next repository.ServiceRepoI) repository.ServiceRepoI {
+	return &serviceRepo{next: next, observer: observer{m: m, repo: "service"}}
+}
+
+type serviceRepo struct {
+	next repository.ServiceRepoI
+	observer
+}
+
+func (r *serviceRepo) Status(ctx context.Context) (_ models.Status, err error) {
+	defer r.observe("Status", time.Now(), &err)
+	return r.next.Status(ctx)
+}
+
+func (r *serviceRepo) Clear(ctx context.Context) (err error) {
+	defer r.observe("Clear", time.Now(), &err)
+	return r.next.Clear(ctx)
+}
+
+// PoolStats reads counters in memory and is not timed.
+func (r *serviceRepo) PoolStats(ctx context.Context) models.PoolStats {
+	return r.next.PoolStats(ctx)
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+//go:generate easyjson -snake_case -all
+
+// Credentials is the body of a login.
+type Credentials struct {
+	Nickname string
+	Password string
+}
+
+// Token is a signed bearer token and when it stops being accepted.
+type Token struct {
+	Token   string
+	Expires time.Time
+}
+
+// Role is what a user may do. Site roles are stored with the user, forum
+// roles follow from owning or moderating the forum.
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleOwner     Role = "owner"
+	RoleModerator Role = "moderator"
+	RoleMember    Role = "member"
+	RoleBanned    Role = "banned"
+)
+
+// SiteRole is the body and result of setting the site role of a user:
+// admin, member or banned.
+type SiteRole struct {
+	Nickname string
+	Role     Role
+}
@@ -1,7 +1,102 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+)
+
 //go:generate easyjson -snake_case -all
 
 type MessageError struct {
 	Message string
+	Details []FieldError `json:",omitempty"`
+}
+
+// FieldError points at a request value that failed validation.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Kinds of domain errors. Every error a repository returns wraps one of
+// them, so callers can tell a missing thread from a database that is down
+// without looking at driver error codes.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("invalid value")
+	ErrUnavailable = errors.New("storage unavailable")
+	// ErrUnauthorized means the caller has to log in first, ErrForbidden
+	// that who they are is not allowed to do it.
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+var (
+	ErrThreadNotFound     = NotFound("thread not found")
+	ErrPostAuthorNotFound = NotFound("post author not found")
+	ErrPostParentNotFound = Conflict("parent post not found in thread")
+	ErrThreadClosed       = Conflict("thread is closed")
+)
+
+// Error is a domain error of one of the kinds above. Message is meant for
+// clients, Err keeps the driver error for logs.
+//
+//easyjson:skip
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func NotFound(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func Validation(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrValidation, Message: fmt.Sprintf(format, args...)}
+}
+
+func Unauthorized(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrUnauthorized, Message: fmt.Sprintf(format, args...)}
+}
+
+func Forbidden(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// Unavailable reports that the storage could not serve the request at all.
+func Unavailable(err error) *Error {
+	return &Error{Kind: ErrUnavailable, Message: "database is unavailable", Err: err}
+}
+
+// PostBatchError tells which post of a create batch was rejected.
+//
+//easyjson:skip
+type PostBatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *PostBatchError) Error() string {
+	return fmt.Sprintf("posts[%d]: %v", e.Index, e.Err)
+}
+
+func (e *PostBatchError) Unwrap() error {
+	return e.Err
 }
@@ -38,6 +38,29 @@ func easyjsonD31a5a85DecodeParkDbCourseInternalModels(in *jlexer.Lexer, out *Mes
 		switch key {
 		case "message":
 			out.Message = string(in.String())
+		case "details":
+			if in.IsNull() {
+				in.Skip()
+				out.Details = nil
+			} else {
+				in.Delim('[')
+				if out.Details == nil {
+					if !in.IsDelim(']') {
+						out.Details = make([]FieldError, 0, 2)
+					} else {
+						out.Details = []FieldError{}
+					}
+				} else {
+					out.Details = (out.Details)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 FieldError
+					(v1).UnmarshalEasyJSON(in)
+					out.Details = append(out.Details, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -57,6 +80,20 @@ func easyjsonD31a5a85EncodeParkDbCourseInternalModels(out *jwriter.Writer, in Me
 		out.RawString(prefix[1:])
 		out.String(string(in.Message))
 	}
+	if len(in.Details) != 0 {
+		const prefix string = ",\"details\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v2, v3 := range in.Details {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				(v3).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
@@ -83,3 +120,76 @@ func (v *MessageError) UnmarshalJSON(data []byte) error {
 func (v *MessageError) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjsonD31a5a85DecodeParkDbCourseInternalModels(l, v)
 }
+func easyjsonD31a5a85DecodeParkDbCourseInternalModels1(in *jlexer.Lexer, out *FieldError) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "field":
+			out.Field = string(in.String())
+		case "message":
+			out.Message = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD31a5a85EncodeParkDbCourseInternalModels1(out *jwriter.Writer, in FieldError) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"field\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Field))
+	}
+	{
+		const prefix string = ",\"message\":"
+		out.RawString(prefix)
+		out.String(string(in.Message))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v FieldError) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD31a5a85EncodeParkDbCourseInternalModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v FieldError) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD31a5a85EncodeParkDbCourseInternalModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *FieldError) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD31a5a85DecodeParkDbCourseInternalModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *FieldError) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD31a5a85DecodeParkDbCourseInternalModels1(l, v)
+}
@@ -0,0 +1,66 @@
+package models
+
+//go:generate easyjson -snake_case -all
+
+// Kinds of changes. They are the SSE event names of thread streams and the
+// message types of forum activity sockets.
+const (
+	EventPost         = "post"          // a post was added
+	EventPostEdit     = "post_edit"     // the message of a post changed
+	EventThread       = "thread"        // the title, message or state of the thread changed
+	EventVotes        = "votes"         // the vote total of the thread changed
+	EventNewThread    = "new_thread"    // a thread was created in the forum
+	EventMember       = "member"        // a user posted in the forum for the first time
+	EventThreadDelete = "thread_delete" // the thread was deleted with its posts
+)
+
+// Event is a committed change as the forum_events channel carries it:
+// only keys, the rows are read when the change is delivered. A post event
+// with LastPost announces the batch of posts from Post to LastPost added
+// to Thread at once, see PostRepoI.GetBatch.
+type Event struct {
+	Kind     string
+	Forum    string `json:",omitempty"`
+	Thread   int    `json:",omitempty"`
+	Post     int64  `json:",omitempty"`
+	LastPost int64  `json:",omitempty"`
+	User     string `json:",omitempty"`
+}
+
+// ThreadUpdate is a change delivered to the watchers of a thread. Post is
+// set for post and post_edit, Thread for thread, votes and thread_delete,
+// which carries only the id and forum of the thread and is the last one.
+type ThreadUpdate struct {
+	Kind   string
+	Post   *Post   `json:",omitempty"`
+	Thread *Thread `json:",omitempty"`
+}
+
+// ForumUpdate is a change delivered to the followers of a forum: Thread
+// is set for new_thread, thread and thread_delete, Post for post and User
+// for member. Missed counts
+// the updates dropped before this one because the follower was slow.
+type ForumUpdate struct {
+	Kind   string
+	Forum  string
+	Thread *Thread `json:",omitempty"`
+	Post   *Post   `json:",omitempty"`
+	User   *User   `json:",omitempty"`
+	Missed int     `json:",omitempty"`
+}
+
+// SocketMessage is a message of a forum activity socket in either
+// direction. Clients send subscribe and unsubscribe with a Forum. The
+// server answers subscribed, unsubscribed or error, with Status and
+// Message, and pushes the updates of the forums followed with their kind
+// as Type.
+type SocketMessage struct {
+	Type    string
+	Forum   string  `json:",omitempty"`
+	Status  int     `json:",omitempty"`
+	Message string  `json:",omitempty"`
+	Thread  *Thread `json:",omitempty"`
+	Post    *Post   `json:",omitempty"`
+	User    *User   `json:",omitempty"`
+	Missed  int     `json:",omitempty"`
+}
@@ -24,10 +24,13 @@ type Post struct {
 	Author   string
 	Message  string
 	IsEdited bool `json:"isEdited"`
-	Forum    string
-	Thread   int32
-	Created  time.Time
-	Path     int64 `json:"-"`
+	// IsDeleted marks the placeholder of a deleted post, which keeps its
+	// place in the tree without message and, if so asked, author.
+	IsDeleted bool `json:"isDeleted,omitempty"`
+	Forum     string
+	Thread    int32
+	Created   time.Time
+	Path      []int64 `json:"-"`
 }
 
 type Posts struct {
@@ -36,7 +39,7 @@ type Posts struct {
 
 type PostFull struct {
 	Post   *Post
-	Author *User
-	Thread *Thread
-	Forum  *Forum
+	Author *User   `json:",omitempty"`
+	Thread *Thread `json:",omitempty"`
+	Forum  *Forum  `json:",omitempty"`
 }
@@ -396,7 +396,7 @@ func easyjson5a72dc82DecodeParkDbCourseInternalModels4(in *jlexer.Lexer, out *Po
 				if out.Author == nil {
 					out.Author = new(User)
 				}
-				easyjson5a72dc82DecodeParkDbCourseInternalModels5(in, out.Author)
+				(*out.Author).UnmarshalEasyJSON(in)
 			}
 		case "thread":
 			if in.IsNull() {
@@ -406,7 +406,7 @@ func easyjson5a72dc82DecodeParkDbCourseInternalModels4(in *jlexer.Lexer, out *Po
 				if out.Thread == nil {
 					out.Thread = new(Thread)
 				}
-				easyjson5a72dc82DecodeParkDbCourseInternalModels6(in, out.Thread)
+				(*out.Thread).UnmarshalEasyJSON(in)
 			}
 		case "forum":
 			if in.IsNull() {
@@ -441,32 +441,20 @@ func easyjson5a72dc82EncodeParkDbCourseInternalModels4(out *jwriter.Writer, in P
 			(*in.Post).MarshalEasyJSON(out)
 		}
 	}
-	{
+	if in.Author != nil {
 		const prefix string = ",\"author\":"
 		out.RawString(prefix)
-		if in.Author == nil {
-			out.RawString("null")
-		} else {
-			easyjson5a72dc82EncodeParkDbCourseInternalModels5(out, *in.Author)
-		}
+		(*in.Author).MarshalEasyJSON(out)
 	}
-	{
+	if in.Thread != nil {
 		const prefix string = ",\"thread\":"
 		out.RawString(prefix)
-		if in.Thread == nil {
-			out.RawString("null")
-		} else {
-			easyjson5a72dc82EncodeParkDbCourseInternalModels6(out, *in.Thread)
-		}
+		(*in.Thread).MarshalEasyJSON(out)
 	}
-	{
+	if in.Forum != nil {
 		const prefix string = ",\"forum\":"
 		out.RawString(prefix)
-		if in.Forum == nil {
-			out.RawString("null")
-		} else {
-			(*in.Forum).MarshalEasyJSON(out)
-		}
+		(*in.Forum).MarshalEasyJSON(out)
 	}
 	out.RawByte('}')
 }
@@ -494,168 +482,7 @@ func (v *PostFull) UnmarshalJSON(data []byte) error {
 func (v *PostFull) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson5a72dc82DecodeParkDbCourseInternalModels4(l, v)
 }
-func easyjson5a72dc82DecodeParkDbCourseInternalModels6(in *jlexer.Lexer, out *Thread) {
-	isTopLevel := in.IsStart()
-	if in.IsNull() {
-		if isTopLevel {
-			in.Consumed()
-		}
-		in.Skip()
-		return
-	}
-	in.Delim('{')
-	for !in.IsDelim('}') {
-		key := in.UnsafeFieldName(false)
-		in.WantColon()
-		if in.IsNull() {
-			in.Skip()
-			in.WantComma()
-			continue
-		}
-		switch key {
-		case "id":
-			out.Id = int(in.Int())
-		case "title":
-			out.Title = string(in.String())
-		case "author":
-			out.Author = string(in.String())
-		case "forum":
-			out.Forum = string(in.String())
-		case "message":
-			out.Message = string(in.String())
-		case "votes":
-			out.Votes = int(in.Int())
-		case "slug":
-			out.Slug = string(in.String())
-		case "created":
-			if data := in.Raw(); in.Ok() {
-				in.AddError((out.Created).UnmarshalJSON(data))
-			}
-		default:
-			in.SkipRecursive()
-		}
-		in.WantComma()
-	}
-	in.Delim('}')
-	if isTopLevel {
-		in.Consumed()
-	}
-}
-func easyjson5a72dc82EncodeParkDbCourseInternalModels6(out *jwriter.Writer, in Thread) {
-	out.RawByte('{')
-	first := true
-	_ = first
-	{
-		const prefix string = ",\"id\":"
-		out.RawString(prefix[1:])
-		out.Int(int(in.Id))
-	}
-	{
-		const prefix string = ",\"title\":"
-		out.RawString(prefix)
-		out.String(string(in.Title))
-	}
-	{
-		const prefix string = ",\"author\":"
-		out.RawString(prefix)
-		out.String(string(in.Author))
-	}
-	{
-		const prefix string = ",\"forum\":"
-		out.RawString(prefix)
-		out.String(string(in.Forum))
-	}
-	{
-		const prefix string = ",\"message\":"
-		out.RawString(prefix)
-		out.String(string(in.Message))
-	}
-	{
-		const prefix string = ",\"votes\":"
-		out.RawString(prefix)
-		out.Int(int(in.Votes))
-	}
-	{
-		const prefix string = ",\"slug\":"
-		out.RawString(prefix)
-		out.String(string(in.Slug))
-	}
-	{
-		const prefix string = ",\"created\":"
-		out.RawString(prefix)
-		out.Raw((in.Created).MarshalJSON())
-	}
-	out.RawByte('}')
-}
-func easyjson5a72dc82DecodeParkDbCourseInternalModels5(in *jlexer.Lexer, out *User) {
-	isTopLevel := in.IsStart()
-	if in.IsNull() {
-		if isTopLevel {
-			in.Consumed()
-		}
-		in.Skip()
-		return
-	}
-	in.Delim('{')
-	for !in.IsDelim('}') {
-		key := in.UnsafeFieldName(false)
-		in.WantColon()
-		if in.IsNull() {
-			in.Skip()
-			in.WantComma()
-			continue
-		}
-		switch key {
-		case "nickname":
-			out.Nickname = string(in.String())
-		case "fullname":
-			out.Fullname = string(in.String())
-		case "about":
-			out.About = string(in.String())
-		case "email":
-			out.Email = string(in.String())
-		default:
-			in.SkipRecursive()
-		}
-		in.WantComma()
-	}
-	in.Delim('}')
-	if isTopLevel {
-		in.Consumed()
-	}
-}
-func easyjson5a72dc82EncodeParkDbCourseInternalModels5(out *jwriter.Writer, in User) {
-	out.RawByte('{')
-	first := true
-	_ = first
-	{
-		const prefix string = ",\"nickname\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
-		out.String(string(in.Nickname))
-	}
-	{
-		const prefix string = ",\"fullname\":"
-		out.RawString(prefix)
-		out.String(string(in.Fullname))
-	}
-	{
-		const prefix string = ",\"about\":"
-		out.RawString(prefix)
-		out.String(string(in.About))
-	}
-	{
-		const prefix string = ",\"email\":"
-		out.RawString(prefix)
-		out.String(string(in.Email))
-	}
-	out.RawByte('}')
-}
-func easyjson5a72dc82DecodeParkDbCourseInternalModels7(in *jlexer.Lexer, out *Post) {
+func easyjson5a72dc82DecodeParkDbCourseInternalModels5(in *jlexer.Lexer, out *Post) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -684,6 +511,8 @@ func easyjson5a72dc82DecodeParkDbCourseInternalModels7(in *jlexer.Lexer, out *Po
 			out.Message = string(in.String())
 		case "isEdited":
 			out.IsEdited = bool(in.Bool())
+		case "isDeleted":
+			out.IsDeleted = bool(in.Bool())
 		case "forum":
 			out.Forum = string(in.String())
 		case "thread":
@@ -702,7 +531,7 @@ func easyjson5a72dc82DecodeParkDbCourseInternalModels7(in *jlexer.Lexer, out *Po
 		in.Consumed()
 	}
 }
-func easyjson5a72dc82EncodeParkDbCourseInternalModels7(out *jwriter.Writer, in Post) {
+func easyjson5a72dc82EncodeParkDbCourseInternalModels5(out *jwriter.Writer, in Post) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -731,6 +560,11 @@ func easyjson5a72dc82EncodeParkDbCourseInternalModels7(out *jwriter.Writer, in P
 		out.RawString(prefix)
 		out.Bool(bool(in.IsEdited))
 	}
+	if in.IsDeleted {
+		const prefix string = ",\"isDeleted\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.IsDeleted))
+	}
 	{
 		const prefix string = ",\"forum\":"
 		out.RawString(prefix)
@@ -752,23 +586,23 @@ func easyjson5a72dc82EncodeParkDbCourseInternalModels7(out *jwriter.Writer, in P
 // MarshalJSON supports json.Marshaler interface
 func (v Post) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson5a72dc82EncodeParkDbCourseInternalModels7(&w, v)
+	easyjson5a72dc82EncodeParkDbCourseInternalModels5(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Post) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson5a72dc82EncodeParkDbCourseInternalModels7(w, v)
+	easyjson5a72dc82EncodeParkDbCourseInternalModels5(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Post) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson5a72dc82DecodeParkDbCourseInternalModels7(&r, v)
+	easyjson5a72dc82DecodeParkDbCourseInternalModels5(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Post) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson5a72dc82DecodeParkDbCourseInternalModels7(l, v)
+	easyjson5a72dc82DecodeParkDbCourseInternalModels5(l, v)
 }
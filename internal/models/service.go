@@ -8,3 +8,21 @@ type Status struct {
 	Thread int
 	Post   int
 }
+
+// PoolStats is a snapshot of the database connection pool.
+type PoolStats struct {
+	MaxConns          int32
+	TotalConns        int32
+	IdleConns         int32
+	AcquiredConns     int32
+	ConstructingConns int32
+
+	AcquireCount         int64
+	AcquireDurationMs    int64
+	EmptyAcquireCount    int64
+	CanceledAcquireCount int64
+
+	NewConnsCount           int64
+	MaxLifetimeDestroyCount int64
+	MaxIdleDestroyCount     int64
+}
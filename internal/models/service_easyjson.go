@@ -104,3 +104,146 @@ func (v *Status) UnmarshalJSON(data []byte) error {
 func (v *Status) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjsonCd93bc43DecodeParkDbCourseInternalModels(l, v)
 }
+func easyjsonCd93bc43DecodeParkDbCourseInternalModels1(in *jlexer.Lexer, out *PoolStats) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "max_conns":
+			out.MaxConns = int32(in.Int32())
+		case "total_conns":
+			out.TotalConns = int32(in.Int32())
+		case "idle_conns":
+			out.IdleConns = int32(in.Int32())
+		case "acquired_conns":
+			out.AcquiredConns = int32(in.Int32())
+		case "constructing_conns":
+			out.ConstructingConns = int32(in.Int32())
+		case "acquire_count":
+			out.AcquireCount = int64(in.Int64())
+		case "acquire_duration_ms":
+			out.AcquireDurationMs = int64(in.Int64())
+		case "empty_acquire_count":
+			out.EmptyAcquireCount = int64(in.Int64())
+		case "canceled_acquire_count":
+			out.CanceledAcquireCount = int64(in.Int64())
+		case "new_conns_count":
+			out.NewConnsCount = int64(in.Int64())
+		case "max_lifetime_destroy_count":
+			out.MaxLifetimeDestroyCount = int64(in.Int64())
+		case "max_idle_destroy_count":
+			out.MaxIdleDestroyCount = int64(in.Int64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonCd93bc43EncodeParkDbCourseInternalModels1(out *jwriter.Writer, in PoolStats) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"max_conns\":"
+		out.RawString(prefix[1:])
+		out.Int32(int32(in.MaxConns))
+	}
+	{
+		const prefix string = ",\"total_conns\":"
+		out.RawString(prefix)
+		out.Int32(int32(in.TotalConns))
+	}
+	{
+		const prefix string = ",\"idle_conns\":"
+		out.RawString(prefix)
+		out.Int32(int32(in.IdleConns))
+	}
+	{
+		const prefix string = ",\"acquired_conns\":"
+		out.RawString(prefix)
+		out.Int32(int32(in.AcquiredConns))
+	}
+	{
+		const prefix string = ",\"constructing_conns\":"
+		out.RawString(prefix)
+		out.Int32(int32(in.ConstructingConns))
+	}
+	{
+		const prefix string = ",\"acquire_count\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.AcquireCount))
+	}
+	{
+		const prefix string = ",\"acquire_duration_ms\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.AcquireDurationMs))
+	}
+	{
+		const prefix string = ",\"empty_acquire_count\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.EmptyAcquireCount))
+	}
+	{
+		const prefix string = ",\"canceled_acquire_count\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.CanceledAcquireCount))
+	}
+	{
+		const prefix string = ",\"new_conns_count\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.NewConnsCount))
+	}
+	{
+		const prefix string = ",\"max_lifetime_destroy_count\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.MaxLifetimeDestroyCount))
+	}
+	{
+		const prefix string = ",\"max_idle_destroy_count\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.MaxIdleDestroyCount))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v PoolStats) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonCd93bc43EncodeParkDbCourseInternalModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v PoolStats) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonCd93bc43EncodeParkDbCourseInternalModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *PoolStats) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonCd93bc43DecodeParkDbCourseInternalModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *PoolStats) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonCd93bc43DecodeParkDbCourseInternalModels1(l, v)
+}
@@ -25,6 +25,17 @@ type Thread struct {
 	Forum   string
 	Message string
 	Votes   int
-	Slug    string
+	Slug    string `json:",omitempty"`
 	Created time.Time
+	// A closed thread takes no posts, pinned ones are listed first in
+	// their forum.
+	IsClosed bool `json:"isClosed,omitempty"`
+	IsPinned bool `json:"isPinned,omitempty"`
+}
+
+// ThreadState is what moderators change of a thread, nil fields keep their
+// value.
+type ThreadState struct {
+	Closed *bool `json:",omitempty"`
+	Pinned *bool `json:",omitempty"`
 }
@@ -193,7 +193,102 @@ func (v *ThreadUpdateReq) UnmarshalJSON(data []byte) error {
 func (v *ThreadUpdateReq) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson2d00218DecodeParkDbCourseInternalModels1(l, v)
 }
-func easyjson2d00218DecodeParkDbCourseInternalModels2(in *jlexer.Lexer, out *Thread) {
+func easyjson2d00218DecodeParkDbCourseInternalModels2(in *jlexer.Lexer, out *ThreadState) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "closed":
+			if in.IsNull() {
+				in.Skip()
+				out.Closed = nil
+			} else {
+				if out.Closed == nil {
+					out.Closed = new(bool)
+				}
+				*out.Closed = bool(in.Bool())
+			}
+		case "pinned":
+			if in.IsNull() {
+				in.Skip()
+				out.Pinned = nil
+			} else {
+				if out.Pinned == nil {
+					out.Pinned = new(bool)
+				}
+				*out.Pinned = bool(in.Bool())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson2d00218EncodeParkDbCourseInternalModels2(out *jwriter.Writer, in ThreadState) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if in.Closed != nil {
+		const prefix string = ",\"closed\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.Bool(bool(*in.Closed))
+	}
+	if in.Pinned != nil {
+		const prefix string = ",\"pinned\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Bool(bool(*in.Pinned))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ThreadState) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson2d00218EncodeParkDbCourseInternalModels2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ThreadState) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson2d00218EncodeParkDbCourseInternalModels2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ThreadState) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson2d00218DecodeParkDbCourseInternalModels2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ThreadState) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson2d00218DecodeParkDbCourseInternalModels2(l, v)
+}
+func easyjson2d00218DecodeParkDbCourseInternalModels3(in *jlexer.Lexer, out *Thread) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -230,6 +325,10 @@ func easyjson2d00218DecodeParkDbCourseInternalModels2(in *jlexer.Lexer, out *Thr
 			if data := in.Raw(); in.Ok() {
 				in.AddError((out.Created).UnmarshalJSON(data))
 			}
+		case "isClosed":
+			out.IsClosed = bool(in.Bool())
+		case "isPinned":
+			out.IsPinned = bool(in.Bool())
 		default:
 			in.SkipRecursive()
 		}
@@ -240,7 +339,7 @@ func easyjson2d00218DecodeParkDbCourseInternalModels2(in *jlexer.Lexer, out *Thr
 		in.Consumed()
 	}
 }
-func easyjson2d00218EncodeParkDbCourseInternalModels2(out *jwriter.Writer, in Thread) {
+func easyjson2d00218EncodeParkDbCourseInternalModels3(out *jwriter.Writer, in Thread) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -274,7 +373,7 @@ func easyjson2d00218EncodeParkDbCourseInternalModels2(out *jwriter.Writer, in Th
 		out.RawString(prefix)
 		out.Int(int(in.Votes))
 	}
-	{
+	if in.Slug != "" {
 		const prefix string = ",\"slug\":"
 		out.RawString(prefix)
 		out.String(string(in.Slug))
@@ -284,29 +383,39 @@ func easyjson2d00218EncodeParkDbCourseInternalModels2(out *jwriter.Writer, in Th
 		out.RawString(prefix)
 		out.Raw((in.Created).MarshalJSON())
 	}
+	if in.IsClosed {
+		const prefix string = ",\"isClosed\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.IsClosed))
+	}
+	if in.IsPinned {
+		const prefix string = ",\"isPinned\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.IsPinned))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v Thread) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2d00218EncodeParkDbCourseInternalModels2(&w, v)
+	easyjson2d00218EncodeParkDbCourseInternalModels3(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Thread) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2d00218EncodeParkDbCourseInternalModels2(w, v)
+	easyjson2d00218EncodeParkDbCourseInternalModels3(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Thread) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2d00218DecodeParkDbCourseInternalModels2(&r, v)
+	easyjson2d00218DecodeParkDbCourseInternalModels3(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Thread) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2d00218DecodeParkDbCourseInternalModels2(l, v)
+	easyjson2d00218DecodeParkDbCourseInternalModels3(l, v)
 }
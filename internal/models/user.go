@@ -8,14 +8,28 @@ type User struct {
 	Fullname string
 	About    string
 	Email    string
+	// PasswordHash is the bcrypt hash of the password, set when a user is
+	// created with one and by UserRepoI.GetCredentials only.
+	PasswordHash string `json:"-"`
+	// Role is the site role, set by UserRepoI.GetCredentials only.
+	Role Role `json:"-"`
+}
+
+// UserCreate is the body of a registration: the profile and, to be able to
+// log in, a password.
+type UserCreate struct {
+	User
+	Password string `json:",omitempty"`
 }
 
 type Users struct {
 	Users []User
 }
 
+// UserUpdate is a profile patch: fields left out of the request are nil
+// and keep their current value.
 type UserUpdate struct {
-	Fullname string
-	About    string
-	Email    string
+	Fullname *string
+	About    *string
+	Email    *string
 }
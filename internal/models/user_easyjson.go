@@ -135,11 +135,35 @@ func easyjson9e1087fdDecodeParkDbCourseInternalModels1(in *jlexer.Lexer, out *Us
 		}
 		switch key {
 		case "fullname":
-			out.Fullname = string(in.String())
+			if in.IsNull() {
+				in.Skip()
+				out.Fullname = nil
+			} else {
+				if out.Fullname == nil {
+					out.Fullname = new(string)
+				}
+				*out.Fullname = string(in.String())
+			}
 		case "about":
-			out.About = string(in.String())
+			if in.IsNull() {
+				in.Skip()
+				out.About = nil
+			} else {
+				if out.About == nil {
+					out.About = new(string)
+				}
+				*out.About = string(in.String())
+			}
 		case "email":
-			out.Email = string(in.String())
+			if in.IsNull() {
+				in.Skip()
+				out.Email = nil
+			} else {
+				if out.Email == nil {
+					out.Email = new(string)
+				}
+				*out.Email = string(in.String())
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -157,17 +181,29 @@ func easyjson9e1087fdEncodeParkDbCourseInternalModels1(out *jwriter.Writer, in U
 	{
 		const prefix string = ",\"fullname\":"
 		out.RawString(prefix[1:])
-		out.String(string(in.Fullname))
+		if in.Fullname == nil {
+			out.RawString("null")
+		} else {
+			out.String(string(*in.Fullname))
+		}
 	}
 	{
 		const prefix string = ",\"about\":"
 		out.RawString(prefix)
-		out.String(string(in.About))
+		if in.About == nil {
+			out.RawString("null")
+		} else {
+			out.String(string(*in.About))
+		}
 	}
 	{
 		const prefix string = ",\"email\":"
 		out.RawString(prefix)
-		out.String(string(in.Email))
+		if in.Email == nil {
+			out.RawString("null")
+		} else {
+			out.String(string(*in.Email))
+		}
 	}
 	out.RawByte('}')
 }
@@ -195,7 +231,7 @@ func (v *UserUpdate) UnmarshalJSON(data []byte) error {
 func (v *UserUpdate) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson9e1087fdDecodeParkDbCourseInternalModels1(l, v)
 }
-func easyjson9e1087fdDecodeParkDbCourseInternalModels2(in *jlexer.Lexer, out *User) {
+func easyjson9e1087fdDecodeParkDbCourseInternalModels2(in *jlexer.Lexer, out *UserCreate) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -214,6 +250,8 @@ func easyjson9e1087fdDecodeParkDbCourseInternalModels2(in *jlexer.Lexer, out *Us
 			continue
 		}
 		switch key {
+		case "password":
+			out.Password = string(in.String())
 		case "nickname":
 			out.Nickname = string(in.String())
 		case "fullname":
@@ -232,10 +270,16 @@ func easyjson9e1087fdDecodeParkDbCourseInternalModels2(in *jlexer.Lexer, out *Us
 		in.Consumed()
 	}
 }
-func easyjson9e1087fdEncodeParkDbCourseInternalModels2(out *jwriter.Writer, in User) {
+func easyjson9e1087fdEncodeParkDbCourseInternalModels2(out *jwriter.Writer, in UserCreate) {
 	out.RawByte('{')
 	first := true
 	_ = first
+	if in.Password != "" {
+		const prefix string = ",\"password\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.String(string(in.Password))
+	}
 	{
 		const prefix string = ",\"nickname\":"
 		if first {
@@ -265,25 +309,117 @@ func easyjson9e1087fdEncodeParkDbCourseInternalModels2(out *jwriter.Writer, in U
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v User) MarshalJSON() ([]byte, error) {
+func (v UserCreate) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
 	easyjson9e1087fdEncodeParkDbCourseInternalModels2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v User) MarshalEasyJSON(w *jwriter.Writer) {
+func (v UserCreate) MarshalEasyJSON(w *jwriter.Writer) {
 	easyjson9e1087fdEncodeParkDbCourseInternalModels2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *User) UnmarshalJSON(data []byte) error {
+func (v *UserCreate) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
 	easyjson9e1087fdDecodeParkDbCourseInternalModels2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *User) UnmarshalEasyJSON(l *jlexer.Lexer) {
+func (v *UserCreate) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson9e1087fdDecodeParkDbCourseInternalModels2(l, v)
 }
+func easyjson9e1087fdDecodeParkDbCourseInternalModels3(in *jlexer.Lexer, out *User) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "nickname":
+			out.Nickname = string(in.String())
+		case "fullname":
+			out.Fullname = string(in.String())
+		case "about":
+			out.About = string(in.String())
+		case "email":
+			out.Email = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson9e1087fdEncodeParkDbCourseInternalModels3(out *jwriter.Writer, in User) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"nickname\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Nickname))
+	}
+	{
+		const prefix string = ",\"fullname\":"
+		out.RawString(prefix)
+		out.String(string(in.Fullname))
+	}
+	{
+		const prefix string = ",\"about\":"
+		out.RawString(prefix)
+		out.String(string(in.About))
+	}
+	{
+		const prefix string = ",\"email\":"
+		out.RawString(prefix)
+		out.String(string(in.Email))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v User) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson9e1087fdEncodeParkDbCourseInternalModels3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v User) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson9e1087fdEncodeParkDbCourseInternalModels3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *User) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson9e1087fdDecodeParkDbCourseInternalModels3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *User) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson9e1087fdDecodeParkDbCourseInternalModels3(l, v)
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"park_db_course/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// postgres error codes the schema can produce, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	notNullViolation    = "23502"
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+	checkViolation      = "23514"
+	stringTooLong       = "22001"
+	invalidDatetime     = "22007"
+	datetimeOverflow    = "22008"
+	invalidText         = "22P02"
+)
+
+// dbError turns an error of the driver into a domain error. notFound is
+// the message for a query that matched no rows. Domain and context errors
+// are kept as they are: the deadline belongs to the request, not to the
+// database.
+func dbError(err error, notFound string) error {
+	if err == nil {
+		return nil
+	}
+	var domainErr *models.Error
+	if errors.As(err, &domainErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		e := models.NotFound("%s", notFound)
+		e.Err = err
+		return e
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		var e *models.Error
+		switch {
+		case pgErr.Code == uniqueViolation:
+			e = models.Conflict("%s", pgErrorMessage(pgErr))
+		case pgErr.Code == foreignKeyViolation:
+			e = models.NotFound("%s", pgErrorMessage(pgErr))
+		case pgErr.Code == notNullViolation, pgErr.Code == checkViolation, pgErr.Code == stringTooLong,
+			pgErr.Code == invalidDatetime, pgErr.Code == datetimeOverflow, pgErr.Code == invalidText:
+			e = models.Validation("%s", pgErrorMessage(pgErr))
+		// connection exceptions, insufficient resources, operator intervention
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), strings.HasPrefix(pgErr.Code, "57P"):
+			return models.Unavailable(err)
+		default:
+			return err
+		}
+		e.Err = err
+		return e
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) {
+		return models.Unavailable(err)
+	}
+	return err
+}
+
+// pgErrorMessage prefers the detail, e.g. `Key (slug)=(jolly) already
+// exists.`, which names the offending value.
+func pgErrorMessage(pgErr *pgconn.PgError) string {
+	if pgErr.Detail != "" {
+		return pgErr.Detail
+	}
+	return pgErr.Message
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"park_db_course/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestDBError(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		kind    error
+		message string
+	}{
+		{name: "no rows", err: pgx.ErrNoRows, kind: models.ErrNotFound, message: "Can't find thread by slug or id: jolly"},
+		{name: "unique violation", err: &pgconn.PgError{Code: uniqueViolation, Message: "duplicate key", Detail: "Key (slug)=(jolly) already exists."},
+			kind: models.ErrConflict, message: "Key (slug)=(jolly) already exists."},
+		{name: "foreign key violation", err: &pgconn.PgError{Code: foreignKeyViolation, Message: "violates foreign key constraint"},
+			kind: models.ErrNotFound, message: "violates foreign key constraint"},
+		{name: "not null violation", err: &pgconn.PgError{Code: notNullViolation, Message: "null value"},
+			kind: models.ErrValidation, message: "null value"},
+		{name: "bad timestamp", err: fmt.Errorf("query: %w", &pgconn.PgError{Code: invalidDatetime, Message: "invalid input syntax"}),
+			kind: models.ErrValidation, message: "invalid input syntax"},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01", Message: "terminating connection"},
+			kind: models.ErrUnavailable, message: "database is unavailable"},
+		{name: "too many connections", err: &pgconn.PgError{Code: "53300", Message: "too many clients"},
+			kind: models.ErrUnavailable, message: "database is unavailable"},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			kind: models.ErrUnavailable, message: "database is unavailable"},
+	}
+	for _, c := range cases {
+		got := dbError(c.err, "Can't find thread by slug or id: jolly")
+		if !errors.Is(got, c.kind) {
+			t.Errorf("%s: %v is not %v", c.name, got, c.kind)
+		}
+		if got.Error() != c.message {
+			t.Errorf("%s: message %q, want %q", c.name, got.Error(), c.message)
+		}
+		if !errors.Is(got, c.err) {
+			t.Errorf("%s: cause %v is lost", c.name, c.err)
+		}
+	}
+
+	if dbError(nil, "") != nil {
+		t.Error("nil error mapped to non-nil")
+	}
+	for _, err := range []error{context.DeadlineExceeded, models.ErrThreadNotFound, errors.New("scan failed")} {
+		if got := dbError(err, ""); got != err {
+			t.Errorf("%v was rewritten to %v", err, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"park_db_course/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mailru/easyjson"
+)
+
+// EventRepoI delivers the changes to forums and threads committed by any
+// API instance.
+type EventRepoI interface {
+	// Listen starts receiving the changes committed from now on.
+	Listen(ctx context.Context) (EventStream, error)
+}
+
+// EventStream is one listening session.
+type EventStream interface {
+	// Next blocks until the next change. An error ends the stream, changes
+	// committed after it are not delivered.
+	Next(ctx context.Context) (models.Event, error)
+	Close()
+}
+
+// eventsChannel is fed by the triggers of 0003_forum_events.
+const eventsChannel = "forum_events"
+
+type eventRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewEventRepo(d *pgxpool.Pool) EventRepoI {
+	return &eventRepo{db: d}
+}
+
+// Listen takes a connection out of the pool for good: it stays in LISTEN
+// mode until the stream is closed.
+func (r *eventRepo) Listen(ctx context.Context) (EventStream, error) {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return nil, dbError(err, "")
+	}
+	pgConn := conn.Hijack()
+	if _, err = pgConn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+		_ = pgConn.Close(context.Background())
+		return nil, dbError(err, "")
+	}
+	return &eventStream{conn: pgConn}, nil
+}
+
+type eventStream struct {
+	conn *pgx.Conn
+}
+
+func (s *eventStream) Next(ctx context.Context) (models.Event, error) {
+	var ev models.Event
+	n, err := s.conn.WaitForNotification(ctx)
+	if err != nil {
+		return ev, dbError(err, "")
+	}
+	if err = easyjson.Unmarshal([]byte(n.Payload), &ev); err != nil {
+		return ev, fmt.Errorf("decode %s payload %q: %w", eventsChannel, n.Payload, err)
+	}
+	return ev, nil
+}
+
+func (s *eventStream) Close() {
+	_ = s.conn.Close(context.Background())
+}
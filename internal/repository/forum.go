@@ -1,64 +1,85 @@
 package repository
 
 import (
-	"fmt"
+	"context"
 	"park_db_course/internal/models"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type ForumRepoI interface {
-	Create(new models.ForumReq) (models.Forum, error)
-	GetBySlug(slug string) (forum models.Forum, err error)
-	GetThreads(slug, since string, limit int, desc bool) ([]models.Thread, error)
-	GetUsers(forum models.Forum, since string, limit int, desc bool) ([]models.User, error)
+	Create(ctx context.Context, new models.ForumReq) (models.Forum, error)
+	GetBySlug(ctx context.Context, slug string) (forum models.Forum, err error)
+	// GetBySlugs returns the forums found in one query, in no particular
+	// order. Unknown slugs are skipped.
+	GetBySlugs(ctx context.Context, slugs []string) ([]models.Forum, error)
+	GetThreads(ctx context.Context, slug, since string, limit int, desc bool) ([]models.Thread, error)
+	GetUsers(ctx context.Context, forum models.Forum, since string, limit int, desc bool) ([]models.User, error)
+	// GetModerators returns the moderators of forum ordered by nickname.
+	GetModerators(ctx context.Context, forum models.Forum) ([]models.User, error)
+	IsModerator(ctx context.Context, forum models.Forum, nickname string) (bool, error)
+	// AddModerator makes user a moderator of forum, again is a no-op.
+	AddModerator(ctx context.Context, forum models.Forum, user models.User) error
+	// RemoveModerator is ErrNotFound when user does not moderate forum.
+	RemoveModerator(ctx context.Context, forum models.Forum, user models.User) error
 }
 
 var (
-	createForumQ     = `INSERT INTO forum (title, "user", slug) values ($1, $2, $3) RETURNING title, "user", slug, posts, threads;`
-	getForumBySlugQ  = `SELECT id, title, "user", slug, posts, threads FROM forum WHERE slug = $1;`
-	getForumThreadsQ = `SELECT id, title, author, forum, message, votes, slug, created FROM thread WHERE forum = $1`
-	getForumUsersQ   = `SELECT nickname, about, email, fullname FROM "user" WHERE id IN (SELECT "user" FROM forum_user WHERE forum = $1)`
+	createForumQ      = `INSERT INTO forum (title, "user", slug) values ($1, $2, $3) RETURNING title, "user", slug, posts, threads;`
+	getForumBySlugQ   = `SELECT id, title, "user", slug, posts, threads FROM forum WHERE slug = $1;`
+	getForumsBySlugsQ = `SELECT id, title, "user", slug, posts, threads FROM forum WHERE slug = ANY ($1::text[]::citext[]);`
+	getForumThreadsQ  = `SELECT id, title, author, forum, message, votes, slug, created, is_closed, is_pinned FROM thread WHERE forum = $1`
+	getForumUsersQ    = `SELECT nickname, about, email, fullname FROM "user" WHERE id IN (SELECT "user" FROM forum_user WHERE forum = $1)`
+
+	getModeratorsQ   = `SELECT u.id, u.nickname, u.fullname, u.about, u.email FROM forum_moderator m JOIN "user" u ON u.id = m."user" WHERE m.forum = $1 ORDER BY u.nickname;`
+	isModeratorQ     = `SELECT EXISTS (SELECT 1 FROM forum_moderator m JOIN "user" u ON u.id = m."user" WHERE m.forum = $1 AND u.nickname = $2);`
+	addModeratorQ    = `INSERT INTO forum_moderator (forum, "user") VALUES ($1, $2) ON CONFLICT DO NOTHING;`
+	removeModeratorQ = `DELETE FROM forum_moderator WHERE forum = $1 AND "user" = $2;`
 )
 
 type forumRepo struct {
-	db *pgx.ConnPool
+	db *pgxpool.Pool
 }
 
-func NewForumRepo(d *pgx.ConnPool) ForumRepoI {
+func NewForumRepo(d *pgxpool.Pool) ForumRepoI {
 	return &forumRepo{db: d}
 }
 
-func (r *forumRepo) Create(new models.ForumReq) (forum models.Forum, err error) {
-	err = r.db.QueryRow(createForumQ, new.Title, new.User, new.Slug).Scan(&forum.Title, &forum.User, &forum.Slug, &forum.Posts, &forum.Threads)
+func (r *forumRepo) Create(ctx context.Context, new models.ForumReq) (forum models.Forum, err error) {
+	err = r.db.QueryRow(ctx, createForumQ, new.Title, new.User, new.Slug).Scan(&forum.Title, &forum.User, &forum.Slug, &forum.Posts, &forum.Threads)
+	err = dbError(err, "")
 	return
 }
 
-func (r *forumRepo) GetBySlug(slug string) (forum models.Forum, err error) {
-	err = r.db.QueryRow(getForumBySlugQ, slug).Scan(&forum.Id, &forum.Title, &forum.User, &forum.Slug, &forum.Posts, &forum.Threads)
+func (r *forumRepo) GetBySlug(ctx context.Context, slug string) (forum models.Forum, err error) {
+	err = r.db.QueryRow(ctx, getForumBySlugQ, slug).Scan(&forum.Id, &forum.Title, &forum.User, &forum.Slug, &forum.Posts, &forum.Threads)
+	err = dbError(err, "Can't find forum by slug: "+slug)
 	return
 }
 
-func (r *forumRepo) GetThreads(slug, since string, limit int, desc bool) ([]models.Thread, error) {
-	editQuery := getForumThreadsQ
-	if since != "" {
-		if desc {
-			editQuery += fmt.Sprintf(` AND created <= '%s'`, since)
-		} else {
-			editQuery += fmt.Sprintf(` AND created >= '%s'`, since)
-		}
-	}
-	editQuery += fmt.Sprintf(` ORDER BY created`)
-	if desc {
-		editQuery += fmt.Sprintf(` DESC`)
+func (r *forumRepo) GetBySlugs(ctx context.Context, slugs []string) ([]models.Forum, error) {
+	rows, err := r.db.Query(ctx, getForumsBySlugsQ, slugs)
+	if err != nil {
+		return nil, dbError(err, "")
 	}
-	if limit != 0 {
-		editQuery += fmt.Sprintf(` LIMIT %d;`, limit)
+	defer rows.Close()
+
+	forums := make([]models.Forum, 0, len(slugs))
+	for rows.Next() {
+		var f models.Forum
+		if err = rows.Scan(&f.Id, &f.Title, &f.User, &f.Slug, &f.Posts, &f.Threads); err != nil {
+			return nil, dbError(err, "")
+		}
+		forums = append(forums, f)
 	}
+	return forums, dbError(rows.Err(), "")
+}
 
-	rows, err := r.db.Query(editQuery, slug)
+func (r *forumRepo) GetThreads(ctx context.Context, slug, since string, limit int, desc bool) ([]models.Thread, error) {
+	q := forumThreadsQuery(slug, since, limit, desc)
+	rows, err := r.db.Query(ctx, q.String(), q.Args()...)
 	if err != nil {
-		return []models.Thread{}, err
+		return []models.Thread{}, dbError(err, "")
 	}
 	defer rows.Close()
 
@@ -74,37 +95,26 @@ func (r *forumRepo) GetThreads(slug, since string, limit int, desc bool) ([]mode
 			&t.Votes,
 			&t.Slug,
 			&t.Created,
+			&t.IsClosed,
+			&t.IsPinned,
 		)
 		if err != nil {
-			return []models.Thread{}, err
+			return []models.Thread{}, dbError(err, "")
 		}
 
 		threads = append(threads, t)
 	}
+	if err = rows.Err(); err != nil {
+		return []models.Thread{}, dbError(err, "")
+	}
 	return threads, nil
 }
 
-func (r *forumRepo) GetUsers(forum models.Forum, since string, limit int, desc bool) ([]models.User, error) {
-	editQuery := getForumUsersQ
-	if since != "" {
-		if desc {
-			editQuery += fmt.Sprintf(` AND nickname < '%s'`, since)
-		} else {
-			editQuery += fmt.Sprintf(` AND nickname > '%s'`, since)
-		}
-	}
-	editQuery += fmt.Sprintf(` ORDER BY "nickname"`)
-	if desc {
-		editQuery += fmt.Sprintf(` DESC`)
-	}
-
-	if limit > 0 {
-		editQuery += fmt.Sprintf(` LIMIT %d;`, limit)
-	}
-
-	rows, err := r.db.Query(editQuery, forum.Id)
+func (r *forumRepo) GetUsers(ctx context.Context, forum models.Forum, since string, limit int, desc bool) ([]models.User, error) {
+	q := forumUsersQuery(forum.Id, since, limit, desc)
+	rows, err := r.db.Query(ctx, q.String(), q.Args()...)
 	if err != nil {
-		return []models.User{}, err
+		return []models.User{}, dbError(err, "")
 	}
 	defer rows.Close()
 
@@ -119,10 +129,79 @@ func (r *forumRepo) GetUsers(forum models.Forum, since string, limit int, desc b
 			&u.Fullname,
 		)
 		if err != nil {
-			return []models.User{}, err
+			return []models.User{}, dbError(err, "")
 		}
 
 		users = append(users, u)
 	}
+	if err = rows.Err(); err != nil {
+		return []models.User{}, dbError(err, "")
+	}
 	return users, nil
 }
+
+// forumThreadsQuery lists pinned threads before the others whatever the
+// order. They all come on the first page: since pages through the threads
+// not pinned only. Each part is read in the order of the (forum, is_pinned,
+// created) index, which cannot give pinned first with created ascending
+// and descending both, so only the page itself is sorted.
+func forumThreadsQuery(slug, since string, limit int, desc bool) *sqlQuery {
+	limit = PageLimit(limit)
+	order := ` ORDER BY created ` + sortOrder(desc)
+	if since != "" {
+		return newQuery(getForumThreadsQ, slug).
+			add(` AND NOT is_pinned AND created `+sinceCmp(desc, true)+` ?::timestamptz`+order+` LIMIT ?`, since, limit)
+	}
+	return newQuery(`(`+getForumThreadsQ+` AND is_pinned`+order, slug).
+		add(` LIMIT ?) UNION ALL (`+getForumThreadsQ+` AND NOT is_pinned`+order+` LIMIT ?)`, limit, limit).
+		add(` ORDER BY is_pinned DESC, created `+sortOrder(desc)+` LIMIT ?`, limit)
+}
+
+func forumUsersQuery(forumId int64, since string, limit int, desc bool) *sqlQuery {
+	limit = PageLimit(limit)
+	q := newQuery(getForumUsersQ, forumId)
+	if since != "" {
+		q.add(` AND nickname `+sinceCmp(desc, false)+` ?`, since)
+	}
+	return q.add(` ORDER BY nickname `+sortOrder(desc)+` LIMIT ?`, limit)
+}
+
+func (r *forumRepo) GetModerators(ctx context.Context, forum models.Forum) ([]models.User, error) {
+	rows, err := r.db.Query(ctx, getModeratorsQ, forum.Id)
+	if err != nil {
+		return nil, dbError(err, "")
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		var u models.User
+		if err = rows.Scan(&u.Id, &u.Nickname, &u.Fullname, &u.About, &u.Email); err != nil {
+			return nil, dbError(err, "")
+		}
+		users = append(users, u)
+	}
+	return users, dbError(rows.Err(), "")
+}
+
+func (r *forumRepo) IsModerator(ctx context.Context, forum models.Forum, nickname string) (ok bool, err error) {
+	err = r.db.QueryRow(ctx, isModeratorQ, forum.Id, nickname).Scan(&ok)
+	err = dbError(err, "")
+	return
+}
+
+func (r *forumRepo) AddModerator(ctx context.Context, forum models.Forum, user models.User) error {
+	_, err := r.db.Exec(ctx, addModeratorQ, forum.Id, user.Id)
+	return dbError(err, "")
+}
+
+func (r *forumRepo) RemoveModerator(ctx context.Context, forum models.Forum, user models.User) error {
+	tag, err := r.db.Exec(ctx, removeModeratorQ, forum.Id, user.Id)
+	if err != nil {
+		return dbError(err, "")
+	}
+	if tag.RowsAffected() == 0 {
+		return models.NotFound("User %s does not moderate forum %s", user.Nickname, forum.Slug)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type eventRepo struct {
+	s *Store
+}
+
+func NewEventRepo(s *Store) repository.EventRepoI {
+	return &eventRepo{s: s}
+}
+
+func (r *eventRepo) Listen(_ context.Context) (repository.EventStream, error) {
+	es := &eventStream{s: r.s, wake: make(chan struct{}, 1), closed: make(chan struct{})}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.listeners[es] = struct{}{}
+	return es, nil
+}
+
+// eventStream queues the changes without bound, like the notification
+// queue of postgres, so that notify never blocks a writer.
+type eventStream struct {
+	s *Store
+
+	mu     sync.Mutex
+	queue  []models.Event
+	wake   chan struct{}
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (es *eventStream) Next(ctx context.Context) (models.Event, error) {
+	for {
+		es.mu.Lock()
+		if len(es.queue) > 0 {
+			ev := es.queue[0]
+			es.queue = es.queue[1:]
+			es.mu.Unlock()
+			return ev, nil
+		}
+		es.mu.Unlock()
+
+		select {
+		case <-es.wake:
+		case <-es.closed:
+			return models.Event{}, models.Unavailable(context.Canceled)
+		case <-ctx.Done():
+			return models.Event{}, ctx.Err()
+		}
+	}
+}
+
+func (es *eventStream) Close() {
+	es.once.Do(func() {
+		es.s.mu.Lock()
+		delete(es.s.listeners, es)
+		es.s.mu.Unlock()
+		close(es.closed)
+	})
+}
+
+// notify announces a change to every listener. Callers hold s.mu for
+// writing, which keeps the changes in commit order.
+func (s *Store) notify(ev models.Event) {
+	for es := range s.listeners {
+		es.mu.Lock()
+		es.queue = append(es.queue, ev)
+		es.mu.Unlock()
+		select {
+		case es.wake <- struct{}{}:
+		default:
+		}
+	}
+}
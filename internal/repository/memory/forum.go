@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type forumRepo struct {
+	s *Store
+}
+
+func NewForumRepo(s *Store) repository.ForumRepoI {
+	return &forumRepo{s: s}
+}
+
+// Create returns the forum without id, like the RETURNING clause of the
+// postgres repository.
+func (r *forumRepo) Create(_ context.Context, new models.ForumReq) (models.Forum, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.forumsBySlug[fold(new.Slug)]; ok {
+		return models.Forum{}, models.Conflict("Key (slug)=(%s) already exists.", new.Slug)
+	}
+
+	r.s.forumSeq++
+	f := &models.Forum{Id: r.s.forumSeq, Title: new.Title, User: new.User, Slug: new.Slug}
+	r.s.forums[f.Id] = f
+	r.s.forumsBySlug[fold(f.Slug)] = f
+
+	res := *f
+	res.Id = 0
+	return res, nil
+}
+
+func (r *forumRepo) GetBySlug(_ context.Context, slug string) (models.Forum, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	f, ok := r.s.forumsBySlug[fold(slug)]
+	if !ok {
+		return models.Forum{}, models.NotFound("Can't find forum by slug: %s", slug)
+	}
+	return *f, nil
+}
+
+func (r *forumRepo) GetBySlugs(_ context.Context, slugs []string) ([]models.Forum, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	forums := make([]models.Forum, 0, len(slugs))
+	for _, slug := range slugs {
+		if f, ok := r.s.forumsBySlug[fold(slug)]; ok {
+			forums = append(forums, *f)
+		}
+	}
+	return forums, nil
+}
+
+func (r *forumRepo) GetThreads(_ context.Context, slug, sinceVal string, limit int, desc bool) ([]models.Thread, error) {
+	limit = repository.PageLimit(limit)
+	var sinceTime time.Time
+	if sinceVal != "" {
+		var err error
+		if sinceTime, err = parseTimestamptz(sinceVal); err != nil {
+			return []models.Thread{}, err
+		}
+	}
+
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	threads := make([]models.Thread, 0)
+	for _, id := range r.s.threadOrder {
+		t := r.s.threads[id]
+		if fold(t.Forum) != fold(slug) {
+			continue
+		}
+		if sinceVal != "" && (t.IsPinned || !since(t.Created.Compare(sinceTime), desc, true)) {
+			continue
+		}
+		threads = append(threads, *t)
+	}
+
+	sort.SliceStable(threads, func(i, j int) bool {
+		if threads[i].IsPinned != threads[j].IsPinned {
+			return threads[i].IsPinned
+		}
+		if desc {
+			return threads[i].Created.After(threads[j].Created)
+		}
+		return threads[i].Created.Before(threads[j].Created)
+	})
+	if len(threads) > limit {
+		threads = threads[:limit]
+	}
+	return threads, nil
+}
+
+// GetUsers orders by lowercased nickname, which is how citext with the
+// ucs_basic collation sorts.
+func (r *forumRepo) GetUsers(_ context.Context, forum models.Forum, sinceVal string, limit int, desc bool) ([]models.User, error) {
+	limit = repository.PageLimit(limit)
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	users := make([]models.User, 0)
+	for id := range r.s.forumUsers[forum.Id] {
+		u := *r.s.users[id]
+		if sinceVal != "" && !since(compareStrings(fold(u.Nickname), fold(sinceVal)), desc, false) {
+			continue
+		}
+		u.Id = 0
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if desc {
+			return fold(users[i].Nickname) > fold(users[j].Nickname)
+		}
+		return fold(users[i].Nickname) < fold(users[j].Nickname)
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+var timestamptzLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseTimestamptz accepts the ISO 8601 forms clients send for since.
+func parseTimestamptz(s string) (time.Time, error) {
+	for _, layout := range timestamptzLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, models.Validation("invalid input syntax for type timestamp with time zone: %q", s)
+}
+
+func (r *forumRepo) GetModerators(_ context.Context, forum models.Forum) ([]models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	users := make([]models.User, 0, len(r.s.moderators[forum.Id]))
+	for id := range r.s.moderators[forum.Id] {
+		users = append(users, *r.s.users[id])
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return fold(users[i].Nickname) < fold(users[j].Nickname)
+	})
+	return users, nil
+}
+
+func (r *forumRepo) IsModerator(_ context.Context, forum models.Forum, nickname string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	u, ok := r.s.usersByNick[fold(nickname)]
+	return ok && r.s.moderators[forum.Id][u.Id], nil
+}
+
+func (r *forumRepo) AddModerator(_ context.Context, forum models.Forum, user models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.moderators[forum.Id] == nil {
+		r.s.moderators[forum.Id] = map[int]bool{}
+	}
+	r.s.moderators[forum.Id][user.Id] = true
+	return nil
+}
+
+func (r *forumRepo) RemoveModerator(_ context.Context, forum models.Forum, user models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if !r.s.moderators[forum.Id][user.Id] {
+		return models.NotFound("User %s does not moderate forum %s", user.Nickname, forum.Slug)
+	}
+	delete(r.s.moderators[forum.Id], user.Id)
+	return nil
+}
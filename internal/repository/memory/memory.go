@@ -0,0 +1,169 @@
+// Package memory implements the repository interfaces in process memory
+// with the semantics of the postgres schema in db/migrations: citext
+// nicknames, emails and slugs compare case-insensitively, votes and posts
+// update the same counters the triggers do, post paths are materialized
+// on insert and changes to forums, threads and posts are announced like
+// forum_events.
+// Errors are the domain errors the postgres repositories map driver errors
+// to, so handlers cannot tell the two storages apart.
+package memory
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"park_db_course/internal/models"
+)
+
+type voteKey struct {
+	user   int
+	thread int
+}
+
+// Store holds every table. All repositories created from one Store share
+// the data, like repositories sharing a pool.
+type Store struct {
+	mu sync.RWMutex
+
+	users        map[int]*models.User
+	usersByNick  map[string]*models.User
+	usersByEmail map[string]*models.User
+	// passwords are the password hashes by user id, kept apart so the
+	// users handed out carry none, like the password_hash column.
+	passwords map[int]string
+	// roles are the site roles by user id, members are left out.
+	roles map[int]models.Role
+	// admins are the folded nicknames made admins when they register,
+	// see SetAdmins. They survive Clear like configuration.
+	admins map[string]bool
+
+	forums       map[int64]*models.Forum
+	forumsBySlug map[string]*models.Forum
+	forumUsers   map[int64]map[int]bool
+	moderators   map[int64]map[int]bool
+
+	threads     map[int]*models.Thread
+	threadOrder []int
+
+	posts         map[int64]*models.Post
+	postsByThread map[int][]*models.Post
+
+	votes       map[int]*models.Vote
+	votesByUser map[voteKey]*models.Vote
+
+	// listeners are the open event streams, see notify.
+	listeners map[*eventStream]struct{}
+
+	// sequences survive Clear, as they do after TRUNCATE
+	userSeq, threadSeq, voteSeq int
+	forumSeq, postSeq           int64
+}
+
+func NewStore() *Store {
+	s := &Store{listeners: map[*eventStream]struct{}{}}
+	s.reset()
+	return s
+}
+
+// SetAdmins makes the users registering with one of nicknames site admins.
+// A memory store starts empty, so there is nobody to grant auth.admins to
+// at startup as in postgres.
+func (s *Store) SetAdmins(nicknames []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.admins = make(map[string]bool, len(nicknames))
+	for _, nickname := range nicknames {
+		s.admins[fold(nickname)] = true
+	}
+}
+
+func (s *Store) reset() {
+	s.users = map[int]*models.User{}
+	s.usersByNick = map[string]*models.User{}
+	s.usersByEmail = map[string]*models.User{}
+	s.passwords = map[int]string{}
+	s.roles = map[int]models.Role{}
+	s.forums = map[int64]*models.Forum{}
+	s.forumsBySlug = map[string]*models.Forum{}
+	s.forumUsers = map[int64]map[int]bool{}
+	s.moderators = map[int64]map[int]bool{}
+	s.threads = map[int]*models.Thread{}
+	s.threadOrder = nil
+	s.posts = map[int64]*models.Post{}
+	s.postsByThread = map[int][]*models.Post{}
+	s.votes = map[int]*models.Vote{}
+	s.votesByUser = map[voteKey]*models.Vote{}
+}
+
+// addForumUser mirrors the forum_user insert of the create_post and
+// create_thread triggers, and notify_forum_user for a new member.
+func (s *Store) addForumUser(forumSlug, nickname string) error {
+	f, ok := s.forumsBySlug[fold(forumSlug)]
+	if !ok {
+		return models.Validation(`null value in column "forum" of relation "forum_user" violates not-null constraint`)
+	}
+	u, ok := s.usersByNick[fold(nickname)]
+	if !ok {
+		return models.Validation(`null value in column "user" of relation "forum_user" violates not-null constraint`)
+	}
+	if s.forumUsers[f.Id] == nil {
+		s.forumUsers[f.Id] = map[int]bool{}
+	}
+	if !s.forumUsers[f.Id][u.Id] {
+		s.forumUsers[f.Id][u.Id] = true
+		s.notify(models.Event{Kind: models.EventMember, Forum: f.Slug, User: u.Nickname})
+	}
+	return nil
+}
+
+// fold is how citext compares values.
+func fold(s string) string {
+	return strings.ToLower(s)
+}
+
+// now has the precision of timestamptz.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// comparePaths orders bigint[] values like postgres does.
+func comparePaths(a, b []int64) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// since reports whether a keyset value passes the since filter of the
+// given direction.
+func since(cmp int, desc, inclusive bool) bool {
+	switch {
+	case desc && inclusive:
+		return cmp <= 0
+	case desc:
+		return cmp < 0
+	case inclusive:
+		return cmp >= 0
+	default:
+		return cmp > 0
+	}
+}
+
+func clonePost(p *models.Post) models.Post {
+	res := *p
+	res.Path = append([]int64(nil), p.Path...)
+	return res
+}
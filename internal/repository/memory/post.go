@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"context"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type postRepo struct {
+	s *Store
+}
+
+func NewPostRepo(s *Store) repository.PostRepoI {
+	return &postRepo{s: s}
+}
+
+// Get returns related user and forum without ids, like the postgres
+// repository does.
+func (r *postRepo) Get(_ context.Context, id int, related []string) (postInfo models.PostFull, err error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	p, ok := r.s.posts[int64(id)]
+	if !ok {
+		return postInfo, models.NotFound("Can't find post with id: %d", id)
+	}
+	post := clonePost(p)
+	post.Path = nil
+	postInfo.Post = &post
+
+	for _, q := range related {
+		switch q {
+		case "user":
+			if post.Author == "" {
+				// hidden with the deleted post
+				continue
+			}
+			u, ok := r.s.usersByNick[fold(post.Author)]
+			if !ok {
+				return postInfo, models.NotFound("Can't find user by nickname: %s", post.Author)
+			}
+			author := *u
+			author.Id = 0
+			postInfo.Author = &author
+		case "forum":
+			f, ok := r.s.forumsBySlug[fold(post.Forum)]
+			if !ok {
+				return postInfo, models.NotFound("Can't find forum by slug: %s", post.Forum)
+			}
+			forum := *f
+			forum.Id = 0
+			postInfo.Forum = &forum
+		case "thread":
+			t, ok := r.s.threads[int(post.Thread)]
+			if !ok {
+				return postInfo, models.NotFound("Can't find thread by id: %d", post.Thread)
+			}
+			thread := *t
+			postInfo.Thread = &thread
+		}
+	}
+	return postInfo, nil
+}
+
+func (r *postRepo) Update(_ context.Context, id int, new models.PostUpdateReq) (models.Post, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.posts[int64(id)]
+	if !ok {
+		return models.Post{}, models.NotFound("Can't find post with id: %d", id)
+	}
+	if p.Message != new.Message {
+		r.s.notify(models.Event{Kind: models.EventPostEdit, Forum: p.Forum, Thread: int(p.Thread), Post: p.Id})
+	}
+	p.Message = new.Message
+	p.IsEdited = true
+
+	res := clonePost(p)
+	res.Path = nil
+	return res, nil
+}
+
+// Delete announces the placeholder as an edit, like the message change
+// fires notify_post_update.
+func (r *postRepo) Delete(_ context.Context, id int, hideAuthor bool) (models.Post, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.posts[int64(id)]
+	if !ok {
+		return models.Post{}, models.NotFound("Can't find post with id: %d", id)
+	}
+	if !p.IsDeleted {
+		if p.Message != "" {
+			r.s.notify(models.Event{Kind: models.EventPostEdit, Forum: p.Forum, Thread: int(p.Thread), Post: p.Id})
+		}
+		p.Message = ""
+		if hideAuthor {
+			p.Author = ""
+		}
+		p.IsDeleted = true
+		r.s.forumsBySlug[fold(p.Forum)].Posts--
+	}
+
+	res := clonePost(p)
+	res.Path = nil
+	return res, nil
+}
+
+func (r *postRepo) DeleteTree(_ context.Context, id int) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	root, ok := r.s.posts[int64(id)]
+	if !ok {
+		return 0, models.NotFound("Can't find post with id: %d", id)
+	}
+
+	thread := int(root.Thread)
+	forum := r.s.forumsBySlug[fold(root.Forum)]
+	kept := make([]*models.Post, 0, len(r.s.postsByThread[thread]))
+	removed := 0
+	for _, p := range r.s.postsByThread[thread] {
+		if len(p.Path) < len(root.Path) || comparePaths(p.Path[:len(root.Path)], root.Path) != 0 {
+			kept = append(kept, p)
+			continue
+		}
+		delete(r.s.posts, p.Id)
+		if !p.IsDeleted {
+			forum.Posts--
+		}
+		removed++
+	}
+	r.s.postsByThread[thread] = kept
+	return removed, nil
+}
+
+func (r *postRepo) GetBatch(_ context.Context, thread int, first, last int64) ([]models.Post, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	head, ok := r.s.posts[first]
+	if !ok {
+		return []models.Post{}, nil
+	}
+	posts := make([]models.Post, 0, last-first+1)
+	for id := first; id <= last; id++ {
+		if p, ok := r.s.posts[id]; ok && int(p.Thread) == thread && p.Created.Equal(head.Created) {
+			posts = append(posts, clonePost(p))
+		}
+	}
+	return posts, nil
+}
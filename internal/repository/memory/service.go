@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type serviceRepo struct {
+	s *Store
+}
+
+func NewServiceRepo(s *Store) repository.ServiceRepoI {
+	return &serviceRepo{s: s}
+}
+
+func (r *serviceRepo) Status(_ context.Context) (models.Status, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return models.Status{
+		User:   len(r.s.users),
+		Forum:  len(r.s.forums),
+		Thread: len(r.s.threads),
+		Post:   len(r.s.posts),
+	}, nil
+}
+
+func (r *serviceRepo) Clear(_ context.Context) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.reset()
+	return nil
+}
+
+// PoolStats is always empty, there is no pool.
+func (r *serviceRepo) PoolStats(_ context.Context) models.PoolStats {
+	return models.PoolStats{}
+}
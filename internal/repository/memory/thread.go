@@ -0,0 +1,384 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type threadRepo struct {
+	s *Store
+}
+
+func NewThreadRepo(s *Store) repository.ThreadRepoI {
+	return &threadRepo{s: s}
+}
+
+// Create also does the work of the create_thread trigger: it bumps the
+// forum thread counter and records the author as a forum user.
+func (r *threadRepo) Create(_ context.Context, new models.ThreadsReq) (models.Thread, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.addForumUser(new.Forum, new.Author); err != nil {
+		return models.Thread{}, err
+	}
+	r.s.forumsBySlug[fold(new.Forum)].Threads++
+
+	r.s.threadSeq++
+	t := &models.Thread{
+		Id:      r.s.threadSeq,
+		Title:   new.Title,
+		Author:  new.Author,
+		Forum:   new.Forum,
+		Message: new.Message,
+		Slug:    new.Slug,
+		Created: new.Created.Truncate(time.Microsecond),
+	}
+	r.s.threads[t.Id] = t
+	r.s.threadOrder = append(r.s.threadOrder, t.Id)
+	r.s.notify(models.Event{Kind: models.EventNewThread, Forum: t.Forum, Thread: t.Id})
+	return *t, nil
+}
+
+func (r *threadRepo) Update(_ context.Context, oldThread models.Thread, newThread models.ThreadUpdateReq) (models.Thread, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.threads[oldThread.Id]
+	if !ok {
+		return models.Thread{}, models.NotFound("Can't find thread by id: %d", oldThread.Id)
+	}
+	if t.Title != newThread.Title || t.Message != newThread.Message {
+		t.Title, t.Message = newThread.Title, newThread.Message
+		r.s.notify(models.Event{Kind: models.EventThread, Forum: t.Forum, Thread: t.Id})
+	}
+	return *t, nil
+}
+
+// GetBySlugOrId matches slug = $1 OR id = $2 and returns the first thread,
+// like the postgres repository.
+func (r *threadRepo) GetBySlugOrId(_ context.Context, slug string) (models.Thread, error) {
+	id, _ := strconv.Atoi(slug)
+
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, tid := range r.s.threadOrder {
+		t := r.s.threads[tid]
+		if fold(t.Slug) == fold(slug) || t.Id == id {
+			return *t, nil
+		}
+	}
+	return models.Thread{}, models.NotFound("Can't find thread by slug or id: %s", slug)
+}
+
+func (r *threadRepo) GetByIds(_ context.Context, ids []int) ([]models.Thread, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	threads := make([]models.Thread, 0, len(ids))
+	for _, id := range ids {
+		if t, ok := r.s.threads[id]; ok {
+			threads = append(threads, *t)
+		}
+	}
+	return threads, nil
+}
+
+// CreatePosts validates the whole batch before storing anything, so a
+// rejected batch leaves no posts behind.
+func (r *threadRepo) CreatePosts(_ context.Context, thread models.Thread, new models.PostsReq) (*models.Posts, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.threads[thread.Id]
+	if !ok {
+		return nil, models.ErrThreadNotFound
+	}
+	if t.IsClosed {
+		return nil, models.ErrThreadClosed
+	}
+	for i, p := range new.Posts {
+		if _, ok := r.s.usersByNick[fold(p.Author)]; !ok {
+			return nil, &models.PostBatchError{Index: i, Err: fmt.Errorf("%w: %s", models.ErrPostAuthorNotFound, p.Author)}
+		}
+		if p.Parent == 0 {
+			continue
+		}
+		if parent, ok := r.s.posts[int64(p.Parent)]; !ok || int(parent.Thread) != thread.Id {
+			return nil, &models.PostBatchError{Index: i, Err: fmt.Errorf("%w: %d", models.ErrPostParentNotFound, p.Parent)}
+		}
+	}
+
+	created := now()
+	posts := make([]models.Post, 0, len(new.Posts))
+	for _, p := range new.Posts {
+		if err := r.s.addForumUser(thread.Forum, p.Author); err != nil {
+			return nil, err
+		}
+		r.s.forumsBySlug[fold(thread.Forum)].Posts++
+
+		r.s.postSeq++
+		post := &models.Post{
+			Id:      r.s.postSeq,
+			Parent:  int64(p.Parent),
+			Author:  p.Author,
+			Message: p.Message,
+			Forum:   thread.Forum,
+			Thread:  int32(thread.Id),
+			Created: created,
+		}
+		if parent, ok := r.s.posts[post.Parent]; ok {
+			post.Path = append(post.Path, parent.Path...)
+		}
+		post.Path = append(post.Path, post.Id)
+
+		r.s.posts[post.Id] = post
+		r.s.postsByThread[thread.Id] = append(r.s.postsByThread[thread.Id], post)
+		posts = append(posts, clonePost(post))
+	}
+	// one event for the batch, like notify_post_batch
+	if len(posts) > 0 {
+		r.s.notify(models.Event{Kind: models.EventPost, Forum: thread.Forum, Thread: thread.Id, Post: posts[0].Id, LastPost: posts[len(posts)-1].Id})
+	}
+	return &models.Posts{Posts: posts}, nil
+}
+
+func (r *threadRepo) CheckVotes(_ context.Context, user, thread int) (models.Vote, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	v, ok := r.s.votesByUser[voteKey{user: user, thread: thread}]
+	if !ok {
+		return models.Vote{}, models.NotFound("Can't find vote of user %d in thread %d", user, thread)
+	}
+	return *v, nil
+}
+
+// CreateVote applies the vote_insert trigger.
+func (r *threadRepo) CreateVote(_ context.Context, userId int, vote models.VoteRequest, thread models.Thread) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.threads[thread.Id]
+	if _, userOk := r.s.users[userId]; !ok || !userOk {
+		return models.NotFound("Key (user, thread)=(%d, %d) is not present in table \"user\" or \"thread\".", userId, thread.Id)
+	}
+	key := voteKey{user: userId, thread: thread.Id}
+	if _, ok := r.s.votesByUser[key]; ok {
+		return models.Conflict("Key (\"user\", thread)=(%d, %d) already exists.", userId, thread.Id)
+	}
+
+	r.s.voteSeq++
+	v := &models.Vote{Id: r.s.voteSeq, User: userId, Thread: thread.Id, Voice: vote.Voice}
+	r.s.votes[v.Id] = v
+	r.s.votesByUser[key] = v
+	t.Votes += v.Voice
+	r.s.notify(models.Event{Kind: models.EventVotes, Forum: t.Forum, Thread: t.Id})
+	return nil
+}
+
+// UpdateVote applies the vote_update trigger, which assumes the voice
+// flips and adds it twice.
+func (r *threadRepo) UpdateVote(_ context.Context, vote models.VoteRequest, voteId int) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	v, ok := r.s.votes[voteId]
+	if !ok {
+		return 0, models.NotFound("Can't find vote with id: %d", voteId)
+	}
+	v.Voice = vote.Voice
+	if t, ok := r.s.threads[v.Thread]; ok {
+		t.Votes += 2 * v.Voice
+		r.s.notify(models.Event{Kind: models.EventVotes, Forum: t.Forum, Thread: t.Id})
+	}
+	return v.Id, nil
+}
+
+// GetThreadPosts implements the flat, tree and parent_tree orders of
+// threadPostsQuery. since is a post id, and a since post that does not
+// exist yields no rows for the tree sorts, as the postgres subquery does.
+func (r *threadRepo) GetThreadPosts(_ context.Context, thread models.Thread, sinceVal, sortBy string, limit int, desc bool) ([]models.Post, error) {
+	limit = repository.PageLimit(limit)
+	var sinceId int64
+	if sinceVal != "" {
+		var err error
+		if sinceId, err = strconv.ParseInt(sinceVal, 10, 64); err != nil {
+			return []models.Post{}, models.Validation("wrong since format")
+		}
+	}
+
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	all := r.s.postsByThread[thread.Id]
+	var sincePost *models.Post
+	if sinceVal != "" {
+		sincePost = r.s.posts[sinceId]
+	}
+
+	var selected []*models.Post
+	switch sortBy {
+	case "flat":
+		for _, p := range all {
+			if sinceVal == "" || since(compareInts(p.Id, sinceId), desc, false) {
+				selected = append(selected, p)
+			}
+		}
+		sort.SliceStable(selected, func(i, j int) bool {
+			a, b := selected[i], selected[j]
+			c := a.Created.Compare(b.Created)
+			if c == 0 {
+				c = compareInts(a.Id, b.Id)
+			}
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	case "tree":
+		if sinceVal != "" && sincePost == nil {
+			break
+		}
+		for _, p := range all {
+			if sincePost == nil || since(comparePaths(p.Path, sincePost.Path), desc, false) {
+				selected = append(selected, p)
+			}
+		}
+		sort.SliceStable(selected, func(i, j int) bool {
+			c := comparePaths(selected[i].Path, selected[j].Path)
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	case "parent_tree":
+		if sinceVal != "" && sincePost == nil {
+			break
+		}
+		var roots []int64
+		for _, p := range all {
+			if p.Parent != 0 {
+				continue
+			}
+			if sincePost == nil || since(compareInts(p.Path[0], sincePost.Path[0]), desc, false) {
+				roots = append(roots, p.Path[0])
+			}
+		}
+		sort.Slice(roots, func(i, j int) bool {
+			if desc {
+				return roots[i] > roots[j]
+			}
+			return roots[i] < roots[j]
+		})
+		if len(roots) > limit {
+			roots = roots[:limit]
+		}
+		inRoots := map[int64]bool{}
+		for _, id := range roots {
+			inRoots[id] = true
+		}
+		for _, p := range all {
+			if inRoots[p.Path[0]] {
+				selected = append(selected, p)
+			}
+		}
+		sort.SliceStable(selected, func(i, j int) bool {
+			a, b := selected[i], selected[j]
+			if a.Path[0] != b.Path[0] {
+				return (a.Path[0] > b.Path[0]) == desc
+			}
+			return comparePaths(a.Path, b.Path) < 0
+		})
+		// the limit applies to root posts only
+		limit = len(selected)
+	default:
+		return []models.Post{}, models.Validation("wrong sort name")
+	}
+
+	if len(selected) > limit {
+		selected = selected[:limit]
+	}
+	posts := make([]models.Post, 0, len(selected))
+	for _, p := range selected {
+		posts = append(posts, clonePost(p))
+	}
+	return posts, nil
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (r *threadRepo) SetState(_ context.Context, thread models.Thread, state models.ThreadState) (models.Thread, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.threads[thread.Id]
+	if !ok {
+		return models.Thread{}, models.NotFound("Can't find thread by id: %d", thread.Id)
+	}
+	closed, pinned := t.IsClosed, t.IsPinned
+	if state.Closed != nil {
+		t.IsClosed = *state.Closed
+	}
+	if state.Pinned != nil {
+		t.IsPinned = *state.Pinned
+	}
+	if t.IsClosed != closed || t.IsPinned != pinned {
+		r.s.notify(models.Event{Kind: models.EventThread, Forum: t.Forum, Thread: t.Id})
+	}
+	return *t, nil
+}
+
+// Delete leaves forumUsers alone, like the postgres repository leaves
+// forum_user.
+func (r *threadRepo) Delete(_ context.Context, thread models.Thread) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.threads[thread.Id]
+	if !ok {
+		return models.NotFound("Can't find thread by id: %d", thread.Id)
+	}
+	forum := r.s.forumsBySlug[fold(t.Forum)]
+	forum.Threads--
+
+	for _, p := range r.s.postsByThread[t.Id] {
+		if !p.IsDeleted {
+			forum.Posts--
+		}
+		delete(r.s.posts, p.Id)
+	}
+	delete(r.s.postsByThread, t.Id)
+
+	for key, v := range r.s.votesByUser {
+		if key.thread == t.Id {
+			delete(r.s.votesByUser, key)
+			delete(r.s.votes, v.Id)
+		}
+	}
+
+	delete(r.s.threads, t.Id)
+	for i, id := range r.s.threadOrder {
+		if id == t.Id {
+			r.s.threadOrder = append(r.s.threadOrder[:i:i], r.s.threadOrder[i+1:]...)
+			break
+		}
+	}
+	r.s.notify(models.Event{Kind: models.EventThreadDelete, Forum: t.Forum, Thread: t.Id})
+	return nil
+}
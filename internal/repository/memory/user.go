@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type userRepo struct {
+	s *Store
+}
+
+func NewUserRepo(s *Store) repository.UserRepoI {
+	return &userRepo{s: s}
+}
+
+func (r *userRepo) Create(_ context.Context, newUser models.User) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.usersByNick[fold(newUser.Nickname)]; ok {
+		return models.User{}, models.Conflict("Key (nickname)=(%s) already exists.", newUser.Nickname)
+	}
+	if _, ok := r.s.usersByEmail[fold(newUser.Email)]; ok {
+		return models.User{}, models.Conflict("Key (email)=(%s) already exists.", newUser.Email)
+	}
+
+	r.s.userSeq++
+	u := newUser
+	u.Id = r.s.userSeq
+	if u.PasswordHash != "" {
+		r.s.passwords[u.Id] = u.PasswordHash
+		u.PasswordHash = ""
+	}
+	if r.s.admins[fold(u.Nickname)] {
+		r.s.roles[u.Id] = models.RoleAdmin
+	}
+	r.s.users[u.Id] = &u
+	r.s.usersByNick[fold(u.Nickname)] = &u
+	r.s.usersByEmail[fold(u.Email)] = &u
+	return u, nil
+}
+
+func (r *userRepo) GetByNickname(_ context.Context, nickname string) (models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	u, ok := r.s.usersByNick[fold(nickname)]
+	if !ok {
+		return models.User{}, models.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	return *u, nil
+}
+
+func (r *userRepo) GetCredentials(ctx context.Context, nickname string) (models.User, error) {
+	u, err := r.GetByNickname(ctx, nickname)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	u.PasswordHash = r.s.passwords[u.Id]
+	u.Role = models.RoleMember
+	if role, ok := r.s.roles[u.Id]; ok {
+		u.Role = role
+	}
+	return u, nil
+}
+
+// SetRole rejects roles other than admin, member and banned like the check
+// constraint of the role column.
+func (r *userRepo) SetRole(_ context.Context, nickname string, role models.Role) (models.SiteRole, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.usersByNick[fold(nickname)]
+	if !ok {
+		return models.SiteRole{}, models.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	switch role {
+	case models.RoleMember:
+		delete(r.s.roles, u.Id)
+	case models.RoleAdmin, models.RoleBanned:
+		r.s.roles[u.Id] = role
+	default:
+		return models.SiteRole{}, models.Validation(`new row for relation "user" violates check constraint "user_role_check"`)
+	}
+	return models.SiteRole{Nickname: u.Nickname, Role: role}, nil
+}
+
+func (r *userRepo) SetPassword(_ context.Context, nickname, passwordHash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.usersByNick[fold(nickname)]
+	if !ok {
+		return models.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	r.s.passwords[u.Id] = passwordHash
+	return nil
+}
+
+func (r *userRepo) GetByNicknames(_ context.Context, nicknames []string) ([]models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	users := make([]models.User, 0, len(nicknames))
+	for _, nickname := range nicknames {
+		if u, ok := r.s.usersByNick[fold(nickname)]; ok {
+			users = append(users, *u)
+		}
+	}
+	return users, nil
+}
+
+func (r *userRepo) GetByEmail(_ context.Context, email string) (models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	u, ok := r.s.usersByEmail[fold(email)]
+	if !ok {
+		return models.User{}, models.NotFound("Can't find user by email: %s", email)
+	}
+	return *u, nil
+}
+
+func (r *userRepo) GetByEmailOrNick(_ context.Context, email, nickname string) ([]*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var users []*models.User
+	byNick, okNick := r.s.usersByNick[fold(nickname)]
+	if okNick {
+		u := *byNick
+		users = append(users, &u)
+	}
+	if byEmail, ok := r.s.usersByEmail[fold(email)]; ok && (!okNick || byEmail != byNick) {
+		u := *byEmail
+		users = append(users, &u)
+	}
+	return users, nil
+}
+
+// Update returns the user without id, like the RETURNING clause of the
+// postgres repository.
+func (r *userRepo) Update(_ context.Context, user models.User) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.usersByNick[fold(user.Nickname)]
+	if !ok {
+		return models.User{}, models.NotFound("Can't find user by nickname: %s", user.Nickname)
+	}
+	if other, ok := r.s.usersByEmail[fold(user.Email)]; ok && other != u {
+		return models.User{}, models.Conflict("Key (email)=(%s) already exists.", user.Email)
+	}
+
+	delete(r.s.usersByEmail, fold(u.Email))
+	u.Fullname, u.About, u.Email = user.Fullname, user.About, user.Email
+	r.s.usersByEmail[fold(u.Email)] = u
+
+	res := *u
+	res.Id = 0
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"park_db_course/cfg"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewPool opens a postgres connection pool and checks it can reach the
+// database before returning. tracer sees every query of the pool and
+// logger gets them at debug, or at warn when slower than db.slow_query.
+func NewPool(ctx context.Context, conf cfg.DB, logger *slog.Logger, tracer pgx.QueryTracer) (*pgxpool.Pool, error) {
+	poolConf, err := pgxpool.ParseConfig(conf.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("parse db config: %w", err)
+	}
+
+	poolConf.MinConns = int32(conf.MinConns)
+	poolConf.MaxConns = int32(conf.MaxConns)
+	poolConf.MaxConnLifetime = conf.MaxConnLifetime
+	poolConf.MaxConnIdleTime = conf.MaxConnIdleTime
+	poolConf.HealthCheckPeriod = conf.HealthCheckPeriod
+	poolConf.ConnConfig.ConnectTimeout = conf.ConnectTimeout
+	if conf.StatementCache > 0 {
+		poolConf.ConnConfig.StatementCacheCapacity = conf.StatementCache
+		poolConf.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	} else {
+		poolConf.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+	}
+
+	poolConf.ConnConfig.Tracer = tracers{tracer, &queryLog{logger: logger, slow: conf.SlowQuery}}
+	poolConf.AfterConnect = registerTypes
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConf)
+	if err != nil {
+		return nil, fmt.Errorf("create db pool: %w", err)
+	}
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ping db: %w", err)
+	}
+	return pool, nil
+}
+
+// registerTypes teaches pgx about extension types, which it needs to encode
+// them in binary, e.g. for COPY. citext sends and receives like text.
+func registerTypes(ctx context.Context, conn *pgx.Conn) error {
+	var citextOid *uint32
+	if err := conn.QueryRow(ctx, `SELECT to_regtype('citext')::oid;`).Scan(&citextOid); err != nil {
+		return err
+	}
+	// Not installed yet, e.g. when running the first migration.
+	if citextOid == nil {
+		return nil
+	}
+	conn.TypeMap().RegisterType(&pgtype.Type{Name: "citext", OID: *citextOid, Codec: pgtype.TextCodec{}})
+	return nil
+}
@@ -1,45 +1,67 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"park_db_course/internal/models"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PostRepoI interface {
-	Get(id int, related []string) (postInfo models.PostFull, err error)
-	Update(id int, new models.PostUpdateReq) (p models.Post, err error)
+	Get(ctx context.Context, id int, related []string) (postInfo models.PostFull, err error)
+	Update(ctx context.Context, id int, new models.PostUpdateReq) (p models.Post, err error)
+	// Delete turns a post into a placeholder: the message, and the author
+	// if hideAuthor, are cleared and IsDeleted set, its path stays so its
+	// replies keep their place. The post stops counting in forum.posts.
+	// Deleting a placeholder again changes nothing.
+	Delete(ctx context.Context, id int, hideAuthor bool) (p models.Post, err error)
+	// DeleteTree removes a post with all its replies and takes those not
+	// deleted before off forum.posts. It returns how many rows went.
+	DeleteTree(ctx context.Context, id int) (removed int, err error)
+	// GetBatch returns the batch of posts a post event announces: those
+	// of thread from first to last created with first, in id order. The
+	// ids of concurrent batches may interleave, their creation times do
+	// not.
+	GetBatch(ctx context.Context, thread int, first, last int64) ([]models.Post, error)
 }
 
 var (
-	getPostQ       = `SELECT id, parent, author, message, is_edited, forum, thread, created FROM post WHERE id = $1;`
-	getPostUserQ   = `SELECT nickname, fullname, about, email FROM "user" WHERE nickname = $1;`
-	getPostForumQ  = `SELECT title, "user", slug, posts, threads FROM forum WHERE slug = $1;`
-	getPostThreadQ = `SELECT id, title, author, forum, message, votes, slug, created FROM thread WHERE id = $1;`
-	updatePostQ    = `UPDATE post SET message = $1, is_edited = TRUE WHERE id = $2 RETURNING id, parent, author, message, is_edited, forum, thread, created;`
+	getPostQ        = `SELECT id, parent, author, message, is_edited, is_deleted, forum, thread, created FROM post WHERE id = $1;`
+	getPostUserQ    = `SELECT nickname, fullname, about, email FROM "user" WHERE nickname = $1;`
+	getPostForumQ   = `SELECT title, "user", slug, posts, threads FROM forum WHERE slug = $1;`
+	getPostThreadQ  = `SELECT id, title, author, forum, message, votes, slug, created, is_closed, is_pinned FROM thread WHERE id = $1;`
+	updatePostQ     = `UPDATE post SET message = $1, is_edited = TRUE WHERE id = $2 RETURNING id, parent, author, message, is_edited, is_deleted, forum, thread, created;`
+	deletePostQ     = `WITH deleted AS (UPDATE post SET message = '', author = CASE WHEN $2 THEN '' ELSE author END, is_deleted = TRUE WHERE id = $1 AND NOT is_deleted RETURNING id, parent, author, message, is_edited, is_deleted, forum, thread, created), counter AS (UPDATE forum SET posts = posts - 1 WHERE slug = (SELECT forum FROM deleted)) SELECT * FROM deleted;`
+	getPostBatchQ   = `SELECT ` + postReturningFields + ` FROM post WHERE thread = $1 AND id BETWEEN $2 AND $3 AND created = (SELECT created FROM post WHERE id = $2) ORDER BY id;`
+	deletePostTreeQ = `WITH root AS (SELECT thread, path FROM post WHERE id = $1), removed AS (DELETE FROM post p USING root WHERE p.thread = root.thread AND p.path[1:cardinality(root.path)] = root.path RETURNING p.forum, p.is_deleted), counter AS (UPDATE forum SET posts = posts - (SELECT count(*) FROM removed WHERE NOT is_deleted) WHERE slug = (SELECT forum FROM removed LIMIT 1)) SELECT count(*) FROM removed;`
 )
 
 type postRepo struct {
-	db *pgx.ConnPool
+	db *pgxpool.Pool
 }
 
-func NewPostRepo(d *pgx.ConnPool) PostRepoI {
+func NewPostRepo(d *pgxpool.Pool) PostRepoI {
 	return &postRepo{db: d}
 }
 
-func (r *postRepo) Get(id int, related []string) (postInfo models.PostFull, err error) {
+func (r *postRepo) Get(ctx context.Context, id int, related []string) (postInfo models.PostFull, err error) {
 	var post models.Post
-	err = r.db.QueryRow(getPostQ, id).Scan(
+	err = r.db.QueryRow(ctx, getPostQ, id).Scan(
 		&post.Id,
 		&post.Parent,
 		&post.Author,
 		&post.Message,
 		&post.IsEdited,
+		&post.IsDeleted,
 		&post.Forum,
 		&post.Thread,
 		&post.Created,
 	)
 	if err != nil {
+		err = dbError(err, fmt.Sprintf("Can't find post with id: %d", id))
 		return
 	}
 
@@ -49,20 +71,25 @@ func (r *postRepo) Get(id int, related []string) (postInfo models.PostFull, err
 		for _, q := range related {
 			switch q {
 			case "user":
+				if post.Author == "" {
+					// hidden with the deleted post
+					continue
+				}
 				var u models.User
-				err = r.db.QueryRow(getPostUserQ, post.Author).Scan(
+				err = r.db.QueryRow(ctx, getPostUserQ, post.Author).Scan(
 					&u.Nickname,
 					&u.Fullname,
 					&u.About,
 					&u.Email,
 				)
 				if err != nil {
+					err = dbError(err, "Can't find user by nickname: "+post.Author)
 					return
 				}
 				postInfo.Author = &u
 			case "forum":
 				var f models.Forum
-				err = r.db.QueryRow(getPostForumQ, post.Forum).Scan(
+				err = r.db.QueryRow(ctx, getPostForumQ, post.Forum).Scan(
 					&f.Title,
 					&f.User,
 					&f.Slug,
@@ -70,12 +97,13 @@ func (r *postRepo) Get(id int, related []string) (postInfo models.PostFull, err
 					&f.Threads,
 				)
 				if err != nil {
+					err = dbError(err, "Can't find forum by slug: "+post.Forum)
 					return
 				}
 				postInfo.Forum = &f
 			case "thread":
 				var t models.Thread
-				err = r.db.QueryRow(getPostThreadQ, post.Thread).Scan(
+				err = r.db.QueryRow(ctx, getPostThreadQ, post.Thread).Scan(
 					&t.Id,
 					&t.Title,
 					&t.Author,
@@ -84,8 +112,11 @@ func (r *postRepo) Get(id int, related []string) (postInfo models.PostFull, err
 					&t.Votes,
 					&t.Slug,
 					&t.Created,
+					&t.IsClosed,
+					&t.IsPinned,
 				)
 				if err != nil {
+					err = dbError(err, fmt.Sprintf("Can't find thread by id: %d", post.Thread))
 					return
 				}
 				postInfo.Thread = &t
@@ -98,16 +129,61 @@ func (r *postRepo) Get(id int, related []string) (postInfo models.PostFull, err
 	return
 }
 
-func (r *postRepo) Update(id int, new models.PostUpdateReq) (p models.Post, err error) {
-	err = r.db.QueryRow(updatePostQ, new.Message, id).Scan(
+func (r *postRepo) Update(ctx context.Context, id int, new models.PostUpdateReq) (p models.Post, err error) {
+	err = r.db.QueryRow(ctx, updatePostQ, new.Message, id).Scan(
 		&p.Id,
 		&p.Parent,
 		&p.Author,
 		&p.Message,
 		&p.IsEdited,
+		&p.IsDeleted,
 		&p.Forum,
 		&p.Thread,
 		&p.Created,
 	)
+	err = dbError(err, fmt.Sprintf("Can't find post with id: %d", id))
 	return
 }
+
+func (r *postRepo) Delete(ctx context.Context, id int, hideAuthor bool) (p models.Post, err error) {
+	err = r.db.QueryRow(ctx, deletePostQ, id, hideAuthor).Scan(
+		&p.Id,
+		&p.Parent,
+		&p.Author,
+		&p.Message,
+		&p.IsEdited,
+		&p.IsDeleted,
+		&p.Forum,
+		&p.Thread,
+		&p.Created,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// missing, or a placeholder already
+		info, err := r.Get(ctx, id, nil)
+		if err != nil {
+			return models.Post{}, err
+		}
+		return *info.Post, nil
+	}
+	err = dbError(err, "")
+	return
+}
+
+func (r *postRepo) DeleteTree(ctx context.Context, id int) (removed int, err error) {
+	if err = r.db.QueryRow(ctx, deletePostTreeQ, id).Scan(&removed); err != nil {
+		return 0, dbError(err, "")
+	}
+	if removed == 0 {
+		return 0, models.NotFound("Can't find post with id: %d", id)
+	}
+	return removed, nil
+}
+
+func (r *postRepo) GetBatch(ctx context.Context, thread int, first, last int64) ([]models.Post, error) {
+	rows, err := r.db.Query(ctx, getPostBatchQ, thread, first, last)
+	if err != nil {
+		return nil, dbError(err, "")
+	}
+	posts, err := scanPosts(rows, int(last-first+1))
+	return posts, dbError(err, "")
+}
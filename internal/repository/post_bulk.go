@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"park_db_course/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Postgres accepts at most 65535 bind parameters per statement.
+const (
+	maxBindParams       = 65535
+	postInsertColumns   = 6
+	maxPostsPerInsertQ  = maxBindParams / postInsertColumns
+	postReturningFields = `id, parent, author, message, is_edited, is_deleted, forum, thread, created, path`
+)
+
+var (
+	createThreadPostsQ = `INSERT INTO post (parent, author, message, forum, thread, created) VALUES `
+	reservePostIdsQ    = `SELECT nextval(pg_get_serial_sequence('post', 'id')) FROM generate_series(1, $1);`
+	getPostsByIdsQ     = `SELECT ` + postReturningFields + ` FROM post WHERE id = ANY ($1::bigint[]);`
+	postCopyColumns    = []string{"id", "parent", "author", "message", "forum", "thread", "created"}
+)
+
+// insertPosts writes a validated batch inside tx and returns the stored
+// posts in input order. Batches above copyThreshold go through COPY,
+// smaller ones through multi-row INSERTs split to stay under the bind
+// parameter limit. Both paths fire the create_post trigger, which fills
+// path and updates forum counters and forum_user, and notify_post_batch
+// once per statement, not per post.
+func insertPosts(ctx context.Context, tx pgx.Tx, thread models.Thread, posts []models.PostReq, copyThreshold int) ([]models.Post, error) {
+	created := time.Now()
+	if copyThreshold > 0 && len(posts) > copyThreshold {
+		return copyPosts(ctx, tx, thread, posts, created)
+	}
+
+	res := make([]models.Post, 0, len(posts))
+	for start := 0; start < len(posts); start += maxPostsPerInsertQ {
+		end := start + maxPostsPerInsertQ
+		if end > len(posts) {
+			end = len(posts)
+		}
+		chunk, err := insertPostsChunk(ctx, tx, thread, posts[start:end], created)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, chunk...)
+	}
+	return res, nil
+}
+
+func insertPostsChunk(ctx context.Context, tx pgx.Tx, thread models.Thread, posts []models.PostReq, created time.Time) ([]models.Post, error) {
+	var editQuery strings.Builder
+	editQuery.WriteString(createThreadPostsQ)
+
+	postsValues := make([]interface{}, 0, len(posts)*postInsertColumns)
+	for i, post := range posts {
+		if i != 0 {
+			editQuery.WriteString(", ")
+		}
+		n := i * postInsertColumns
+		fmt.Fprintf(&editQuery, "($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+		postsValues = append(postsValues, post.Parent, post.Author, post.Message, thread.Forum, thread.Id, created)
+	}
+	editQuery.WriteString(` RETURNING ` + postReturningFields + `;`)
+
+	rows, err := tx.Query(ctx, editQuery.String(), postsValues...)
+	if err != nil {
+		return nil, err
+	}
+	return scanPosts(rows, len(posts))
+}
+
+// copyPosts reserves ids up front so rows can be matched back to the input
+// order, since COPY cannot return anything.
+func copyPosts(ctx context.Context, tx pgx.Tx, thread models.Thread, posts []models.PostReq, created time.Time) ([]models.Post, error) {
+	rows, err := tx.Query(ctx, reservePostIdsQ, len(posts))
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+	// ascending ids keep the input order for sort=flat (created, id)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"post"}, postCopyColumns,
+		pgx.CopyFromSlice(len(posts), func(i int) ([]interface{}, error) {
+			p := posts[i]
+			return []interface{}{ids[i], int64(p.Parent), p.Author, p.Message, thread.Forum, int32(thread.Id), created}, nil
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(ctx, getPostsByIdsQ, ids)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := scanPosts(rows, len(posts))
+	if err != nil {
+		return nil, err
+	}
+
+	position := make(map[int64]int, len(ids))
+	for i, id := range ids {
+		position[id] = i
+	}
+	res := make([]models.Post, len(posts))
+	for _, p := range stored {
+		res[position[p.Id]] = p
+	}
+	return res, nil
+}
+
+func scanPosts(rows pgx.Rows, sizeHint int) ([]models.Post, error) {
+	defer rows.Close()
+
+	posts := make([]models.Post, 0, sizeHint)
+	for rows.Next() {
+		var p models.Post
+		err := rows.Scan(
+			&p.Id,
+			&p.Parent,
+			&p.Author,
+			&p.Message,
+			&p.IsEdited,
+			&p.IsDeleted,
+			&p.Forum,
+			&p.Thread,
+			&p.Created,
+			&p.Path,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
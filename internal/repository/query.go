@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sqlQuery builds dynamic SQL without ever splicing request values into the
+// text: values are bound as positional parameters and keywords such as the
+// sort direction come from fixed whitelists.
+type sqlQuery struct {
+	sql  strings.Builder
+	args []interface{}
+}
+
+// newQuery starts a query from base, which refers to args as $1..$n.
+func newQuery(base string, args ...interface{}) *sqlQuery {
+	q := &sqlQuery{args: args}
+	q.sql.WriteString(base)
+	return q
+}
+
+// add appends fragment, replacing every "?" with the placeholder of the
+// next value from args.
+func (q *sqlQuery) add(fragment string, args ...interface{}) *sqlQuery {
+	if n := strings.Count(fragment, "?"); n != len(args) {
+		panic(fmt.Sprintf("query fragment %q has %d placeholders, got %d args", fragment, n, len(args)))
+	}
+	for _, arg := range args {
+		i := strings.IndexByte(fragment, '?')
+		q.args = append(q.args, arg)
+		q.sql.WriteString(fragment[:i])
+		q.sql.WriteString("$" + strconv.Itoa(len(q.args)))
+		fragment = fragment[i+1:]
+	}
+	q.sql.WriteString(fragment)
+	return q
+}
+
+func (q *sqlQuery) String() string {
+	return q.sql.String()
+}
+
+func (q *sqlQuery) Args() []interface{} {
+	return q.args
+}
+
+// Page sizes of the list queries, as doc/swagger.yml bounds limit.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 10000
+)
+
+// PageLimit is the page size a list query reads for limit: the default
+// when it is not positive and never more than MaxLimit, so no caller that
+// got past the validators scans a whole table.
+func PageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultLimit
+	case limit > MaxLimit:
+		return MaxLimit
+	}
+	return limit
+}
+
+// sortOrder is the only way a sort direction gets into SQL text.
+func sortOrder(desc bool) string {
+	if desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// sinceCmp picks the keyset comparison operator for a sort direction.
+func sinceCmp(desc bool, inclusive bool) string {
+	switch {
+	case desc && inclusive:
+		return "<="
+	case desc:
+		return "<"
+	case inclusive:
+		return ">="
+	default:
+		return ">"
+	}
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var hostileInputs = []string{
+	"",
+	"2019-01-01T00:00:00.000Z",
+	"42",
+	"' OR 1=1 --",
+	"2019-01-01'; DROP TABLE post; --",
+	"1) OR (1=1",
+	"1; TRUNCATE \"user\" CASCADE",
+	"$1",
+	"?",
+	"DESC; SELECT pg_sleep(10)",
+}
+
+// assertBound checks that the query text is exactly the template chosen by
+// the whitelisted knobs, so request values can only reach postgres as args.
+func assertBound(t *testing.T, got, want *sqlQuery, hostile string) {
+	t.Helper()
+	if got.String() != want.String() {
+		t.Fatalf("SQL text depends on input %q:\n got: %s\nwant: %s", hostile, got, want)
+	}
+	if placeholders := strings.Count(got.String(), "$"); placeholders < len(got.Args()) {
+		t.Fatalf("%d args but only %d placeholders in %s", len(got.Args()), placeholders, got)
+	}
+}
+
+// benign replaces a user supplied value with a harmless one of the same
+// "shape", i.e. one that makes the builder choose the same template.
+func benign(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "1"
+}
+
+func FuzzForumThreadsQuery(f *testing.F) {
+	for i, s := range hostileInputs {
+		f.Add(s, s, i*37-50, i%2 == 0)
+	}
+	f.Fuzz(func(t *testing.T, slug, since string, limit int, desc bool) {
+		got := forumThreadsQuery(slug, since, limit, desc)
+		want := forumThreadsQuery("slug", benign(since), limit, desc)
+		assertBound(t, got, want, since)
+		assertBound(t, got, want, slug)
+	})
+}
+
+func FuzzForumUsersQuery(f *testing.F) {
+	for i, s := range hostileInputs {
+		f.Add(s, i*37-50, i%2 == 0)
+	}
+	f.Fuzz(func(t *testing.T, since string, limit int, desc bool) {
+		got := forumUsersQuery(1, since, limit, desc)
+		want := forumUsersQuery(1, benign(since), limit, desc)
+		assertBound(t, got, want, since)
+	})
+}
+
+func FuzzThreadPostsQuery(f *testing.F) {
+	sorts := append([]string{"flat", "tree", "parent_tree"}, hostileInputs...)
+	for i, s := range hostileInputs {
+		f.Add(s, sorts[i%len(sorts)], i*37-50, i%2 == 0)
+	}
+	f.Fuzz(func(t *testing.T, since, sort string, limit int, desc bool) {
+		got, err := threadPostsQuery(1, since, sort, limit, desc)
+		switch sort {
+		case "flat", "tree", "parent_tree":
+		default:
+			if err == nil {
+				t.Fatalf("sort %q is not whitelisted but produced %s", sort, got)
+			}
+			return
+		}
+		if err != nil {
+			// only a since that is not a post id may be rejected
+			if _, convErr := strconv.ParseInt(since, 10, 64); convErr == nil {
+				t.Fatalf("valid since %q rejected: %v", since, err)
+			}
+			return
+		}
+		want, err := threadPostsQuery(1, benign(since), sort, limit, desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertBound(t, got, want, since)
+	})
+}
+
+func TestQueryPlaceholders(t *testing.T) {
+	q := newQuery(`SELECT 1 WHERE a = $1`, "a").
+		add(` AND b > ? AND c < ?`, "b", 3).
+		add(` LIMIT ?`, 10)
+
+	if want := `SELECT 1 WHERE a = $1 AND b > $2 AND c < $3 LIMIT $4`; q.String() != want {
+		t.Fatalf("got %s, want %s", q, want)
+	}
+	if len(q.Args()) != 4 {
+		t.Fatalf("got %d args, want 4", len(q.Args()))
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	for limit, want := range map[int]int{-1: DefaultLimit, 0: DefaultLimit, 1: 1, MaxLimit: MaxLimit, MaxLimit + 1: MaxLimit} {
+		queries := map[string]*sqlQuery{
+			"threads": forumThreadsQuery("slug", "", limit, false),
+			"users":   forumUsersQuery(1, "", limit, false),
+		}
+		for _, sort := range []string{"flat", "tree", "parent_tree"} {
+			queries[sort], _ = threadPostsQuery(1, "", sort, limit, false)
+		}
+		for name, q := range queries {
+			args := q.Args()
+			if !strings.Contains(q.String(), "LIMIT") || args[len(args)-1] != want {
+				t.Errorf("%s with limit %d: got %s %v, want LIMIT %d", name, limit, q, args, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// queryLog logs every query at debug and those slower than slow at warn,
+// with the request id of the call that made them.
+type queryLog struct {
+	logger *slog.Logger
+	slow   time.Duration
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	sql string
+	at  time.Time
+}
+
+func (l *queryLog) start(ctx context.Context, sql string) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{sql: sql, at: time.Now()})
+}
+
+func (l *queryLog) end(ctx context.Context, err error, attrs ...slog.Attr) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	took := time.Since(start.at)
+	level := slog.LevelDebug
+	if l.slow > 0 && took >= l.slow {
+		level = slog.LevelWarn
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs = append(attrs, slog.String("sql", start.sql), slog.Duration("duration", took))
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	msg := "query"
+	if level == slog.LevelWarn {
+		msg = "slow query"
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (l *queryLog) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return l.start(ctx, data.SQL)
+}
+
+func (l *queryLog) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	l.end(ctx, data.Err, slog.Int64("rows", data.CommandTag.RowsAffected()))
+}
+
+func (l *queryLog) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return l.start(ctx, "batch")
+}
+
+func (l *queryLog) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (l *queryLog) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	l.end(ctx, data.Err)
+}
+
+func (l *queryLog) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return l.start(ctx, "COPY "+data.TableName.Sanitize())
+}
+
+func (l *queryLog) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	l.end(ctx, data.Err, slog.Int64("rows", data.CommandTag.RowsAffected()))
+}
+
+// tracers hands the calls of a pool to each of its tracers in turn, the
+// batch and COPY ones to those that implement them.
+type tracers []pgx.QueryTracer
+
+func (ts tracers) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	for _, t := range ts {
+		ctx = t.TraceQueryStart(ctx, conn, data)
+	}
+	return ctx
+}
+
+func (ts tracers) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	for _, t := range ts {
+		t.TraceQueryEnd(ctx, conn, data)
+	}
+}
+
+func (ts tracers) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	for _, t := range ts {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			ctx = bt.TraceBatchStart(ctx, conn, data)
+		}
+	}
+	return ctx
+}
+
+func (ts tracers) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
+	for _, t := range ts {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			bt.TraceBatchQuery(ctx, conn, data)
+		}
+	}
+}
+
+func (ts tracers) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	for _, t := range ts {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			bt.TraceBatchEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (ts tracers) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	for _, t := range ts {
+		if ct, ok := t.(pgx.CopyFromTracer); ok {
+			ctx = ct.TraceCopyFromStart(ctx, conn, data)
+		}
+	}
+	return ctx
+}
+
+func (ts tracers) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	for _, t := range ts {
+		if ct, ok := t.(pgx.CopyFromTracer); ok {
+			ct.TraceCopyFromEnd(ctx, conn, data)
+		}
+	}
+}
@@ -1,41 +1,61 @@
 package repository
 
 import (
+	"context"
 	"park_db_course/internal/models"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type ServiceRepoI interface {
-	Status() (models.Status, error)
-	Clear() error
+	Status(ctx context.Context) (models.Status, error)
+	Clear(ctx context.Context) error
+	PoolStats(ctx context.Context) models.PoolStats
 }
 
 var (
 	getDBInfoQ = `SELECT (SELECT count(*) from forum), (SELECT count(*) from post), (SELECT count(*) from thread), (SELECT count(*) from "user");`
-	deleteDBQ  = `TRUNCATE "user", forum, thread, post, vote, forum_user CASCADE;`
+	deleteDBQ  = `TRUNCATE "user", forum, thread, post, vote, forum_user, forum_moderator CASCADE;`
 )
 
 type serviceRepo struct {
-	db *pgx.ConnPool
+	db *pgxpool.Pool
 }
 
-func NewServiceRepo(d *pgx.ConnPool) ServiceRepoI {
+func NewServiceRepo(d *pgxpool.Pool) ServiceRepoI {
 	return &serviceRepo{db: d}
 }
 
-func (r *serviceRepo) Status() (models.Status, error) {
+func (r *serviceRepo) Status(ctx context.Context) (models.Status, error) {
 	var status models.Status
-	err := r.db.QueryRow(getDBInfoQ).Scan(
+	err := r.db.QueryRow(ctx, getDBInfoQ).Scan(
 		&status.Forum,
 		&status.Post,
 		&status.Thread,
 		&status.User,
 	)
-	return status, err
+	return status, dbError(err, "")
 }
 
-func (r *serviceRepo) Clear() error {
-	err := r.db.QueryRow(deleteDBQ).Scan()
-	return err
+func (r *serviceRepo) Clear(ctx context.Context) error {
+	_, err := r.db.Exec(ctx, deleteDBQ)
+	return dbError(err, "")
+}
+
+func (r *serviceRepo) PoolStats(ctx context.Context) models.PoolStats {
+	s := r.db.Stat()
+	return models.PoolStats{
+		MaxConns:                s.MaxConns(),
+		TotalConns:              s.TotalConns(),
+		IdleConns:               s.IdleConns(),
+		AcquiredConns:           s.AcquiredConns(),
+		ConstructingConns:       s.ConstructingConns(),
+		AcquireCount:            s.AcquireCount(),
+		AcquireDurationMs:       s.AcquireDuration().Milliseconds(),
+		EmptyAcquireCount:       s.EmptyAcquireCount(),
+		CanceledAcquireCount:    s.CanceledAcquireCount(),
+		NewConnsCount:           s.NewConnsCount(),
+		MaxLifetimeDestroyCount: s.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     s.MaxIdleDestroyCount(),
+	}
 }
@@ -1,183 +1,313 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"park_db_course/internal/models"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type ThreadRepoI interface {
-	GetBySlugOrId(slug string) (t models.Thread, err error)
-	Create(new models.ThreadsReq) (t models.Thread, err error)
-	Update(old models.Thread, new models.ThreadUpdateReq) (t models.Thread, err error)
-	CheckPost(parent, id int) (err error)
-	CreatePosts(thread models.Thread, new models.PostsReq) (response *models.Posts, err error)
-	CheckVotes(user, thread int) (vote models.Vote, err error)
-	CreateVote(userId int, vote models.VoteRequest, thread models.Thread) (err error)
-	UpdateVote(vote models.VoteRequest, voteId int) (id int, err error)
-	GetThreadPosts(thread models.Thread, since, sort string, limit int, desc bool) ([]models.Post, error)
+	GetBySlugOrId(ctx context.Context, slug string) (t models.Thread, err error)
+	// GetByIds returns the threads found in one query, in no particular
+	// order. Unknown ids are skipped.
+	GetByIds(ctx context.Context, ids []int) ([]models.Thread, error)
+	Create(ctx context.Context, new models.ThreadsReq) (t models.Thread, err error)
+	Update(ctx context.Context, old models.Thread, new models.ThreadUpdateReq) (t models.Thread, err error)
+	CreatePosts(ctx context.Context, thread models.Thread, new models.PostsReq) (response *models.Posts, err error)
+	CheckVotes(ctx context.Context, user, thread int) (vote models.Vote, err error)
+	CreateVote(ctx context.Context, userId int, vote models.VoteRequest, thread models.Thread) (err error)
+	UpdateVote(ctx context.Context, vote models.VoteRequest, voteId int) (id int, err error)
+	GetThreadPosts(ctx context.Context, thread models.Thread, since, sort string, limit int, desc bool) ([]models.Post, error)
+	// SetState closes or opens and pins or unpins thread.
+	SetState(ctx context.Context, thread models.Thread, state models.ThreadState) (t models.Thread, err error)
+	// Delete removes thread with its posts and votes and takes them off the
+	// counters of its forum.
+	Delete(ctx context.Context, thread models.Thread) error
 }
 
 var (
-	createThreadQ      = `INSERT INTO thread (title, author, forum, message, slug, created) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, title, author, forum, message, votes, slug, created;`
-	updateThreadQ      = `UPDATE thread SET title = $1, message = $2 WHERE id = $3 RETURNING id, title, author, forum, message, votes, slug, created;`
-	getThreadQ         = `SELECT id, title, author, forum, message, votes, slug, created FROM thread WHERE slug = $1 OR id = $2;`
-	checkThreadPostQ   = `SELECT id FROM post WHERE thread = $1 AND id = $2;`
-	createThreadPostsQ = `INSERT INTO post (parent, author, message, forum, thread, created) values `
-	checkVotesQ        = `SELECT id, "user", thread, voice from vote where "user" = $1 and thread = $2;`
-	createVoteQ        = `INSERT INTO vote ("user", thread, voice)  VALUES ($1, $2, $3)  RETURNING "user";`
-	updateVoteQ        = `UPDATE vote SET voice = $1 WHERE id = $2 RETURNING id;`
-	getThreadPostsQ    = `SELECT id, parent, author, message, is_edited, forum, thread, created FROM post WHERE thread = $1 `
+	createThreadQ    = `INSERT INTO thread (title, author, forum, message, slug, created) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, title, author, forum, message, votes, slug, created, is_closed, is_pinned;`
+	updateThreadQ    = `UPDATE thread SET title = $1, message = $2 WHERE id = $3 RETURNING id, title, author, forum, message, votes, slug, created, is_closed, is_pinned;`
+	getThreadQ       = `SELECT id, title, author, forum, message, votes, slug, created, is_closed, is_pinned FROM thread WHERE slug = $1 OR id = $2;`
+	getThreadsByIdsQ = `SELECT id, title, author, forum, message, votes, slug, created, is_closed, is_pinned FROM thread WHERE id = ANY ($1::int[]);`
+	lockThreadQ      = `SELECT id, is_closed FROM thread WHERE id = $1 FOR SHARE;`
+	lockPostAuthorsQ = `SELECT nickname FROM "user" WHERE nickname = ANY ($1::text[]::citext[]) FOR SHARE;`
+	getPostParentsQ  = `SELECT id FROM post WHERE thread = $1 AND id = ANY ($2::bigint[]);`
+	checkVotesQ      = `SELECT id, "user", thread, voice from vote where "user" = $1 and thread = $2;`
+	createVoteQ      = `INSERT INTO vote ("user", thread, voice)  VALUES ($1, $2, $3)  RETURNING "user";`
+	updateVoteQ      = `UPDATE vote SET voice = $1 WHERE id = $2 RETURNING id;`
+	getThreadPostsQ  = `SELECT id, parent, author, message, is_edited, is_deleted, forum, thread, created FROM post WHERE thread = $1 `
+	setThreadStateQ  = `UPDATE thread SET is_closed = coalesce($2, is_closed), is_pinned = coalesce($3, is_pinned) WHERE id = $1 RETURNING id, title, author, forum, message, votes, slug, created, is_closed, is_pinned;`
+	deleteThreadQ    = `WITH t AS (DELETE FROM thread WHERE id = $1 RETURNING id, forum), p AS (DELETE FROM post WHERE thread = (SELECT id FROM t) RETURNING is_deleted), v AS (DELETE FROM vote WHERE thread = (SELECT id FROM t)), f AS (UPDATE forum SET threads = threads - 1, posts = posts - (SELECT count(*) FROM p WHERE NOT is_deleted) WHERE slug = (SELECT forum FROM t)) SELECT count(*) FROM t;`
 )
 
 type threadRepo struct {
-	db *pgx.ConnPool
+	db            *pgxpool.Pool
+	copyThreshold int
 }
 
-func NewThreadRepo(d *pgx.ConnPool) ThreadRepoI {
-	return &threadRepo{db: d}
+// NewThreadRepo creates the postgres thread repository. Post batches larger
+// than copyThreshold are written with COPY, 0 disables COPY.
+func NewThreadRepo(d *pgxpool.Pool, copyThreshold int) ThreadRepoI {
+	return &threadRepo{db: d, copyThreshold: copyThreshold}
 }
 
-func (r *threadRepo) Create(new models.ThreadsReq) (t models.Thread, err error) {
+func (r *threadRepo) Create(ctx context.Context, new models.ThreadsReq) (t models.Thread, err error) {
 	if new.Created.String() == "" {
 		new.Created = time.Now()
 	}
 
-	err = r.db.QueryRow(createThreadQ, new.Title, new.Author, new.Forum, new.Message, new.Slug, new.Created).Scan(
-		&t.Id, &t.Title, &t.Author, &t.Forum, &t.Message, &t.Votes, &t.Slug, &t.Created)
+	err = r.db.QueryRow(ctx, createThreadQ, new.Title, new.Author, new.Forum, new.Message, new.Slug, new.Created).Scan(
+		&t.Id, &t.Title, &t.Author, &t.Forum, &t.Message, &t.Votes, &t.Slug, &t.Created, &t.IsClosed, &t.IsPinned)
+	err = dbError(err, "")
 	return
 }
 
-func (r *threadRepo) Update(oldThread models.Thread, newThread models.ThreadUpdateReq) (t models.Thread, err error) {
-	err = r.db.QueryRow(updateThreadQ, newThread.Title, newThread.Message, oldThread.Id).Scan(&t.Id, &t.Title, &t.Author, &t.Forum, &t.Message, &t.Votes, &t.Slug, &t.Created)
+func (r *threadRepo) Update(ctx context.Context, oldThread models.Thread, newThread models.ThreadUpdateReq) (t models.Thread, err error) {
+	err = r.db.QueryRow(ctx, updateThreadQ, newThread.Title, newThread.Message, oldThread.Id).Scan(&t.Id, &t.Title, &t.Author, &t.Forum, &t.Message, &t.Votes, &t.Slug, &t.Created, &t.IsClosed, &t.IsPinned)
+	err = dbError(err, fmt.Sprintf("Can't find thread by id: %d", oldThread.Id))
 	return
 }
 
-func (r *threadRepo) GetBySlugOrId(slug string) (t models.Thread, err error) {
+func (r *threadRepo) GetBySlugOrId(ctx context.Context, slug string) (t models.Thread, err error) {
 	id, _ := strconv.Atoi(slug)
-	err = r.db.QueryRow(getThreadQ, slug, id).Scan(&t.Id, &t.Title, &t.Author, &t.Forum, &t.Message, &t.Votes, &t.Slug, &t.Created)
+	err = r.db.QueryRow(ctx, getThreadQ, slug, id).Scan(&t.Id, &t.Title, &t.Author, &t.Forum, &t.Message, &t.Votes, &t.Slug, &t.Created, &t.IsClosed, &t.IsPinned)
+	err = dbError(err, "Can't find thread by slug or id: "+slug)
 	return
 }
 
-func (r *threadRepo) CheckPost(parent, id int) (err error) {
-	err = r.db.QueryRow(checkThreadPostQ, id, parent).Scan(&id)
-	return
-}
+func (r *threadRepo) GetByIds(ctx context.Context, ids []int) ([]models.Thread, error) {
+	rows, err := r.db.Query(ctx, getThreadsByIdsQ, ids)
+	if err != nil {
+		return nil, dbError(err, "")
+	}
+	defer rows.Close()
 
-func (r *threadRepo) CreatePosts(thread models.Thread, new models.PostsReq) (response *models.Posts, err error) {
-	editQuery := createThreadPostsQ
+	threads := make([]models.Thread, 0, len(ids))
+	for rows.Next() {
+		var t models.Thread
+		if err = rows.Scan(&t.Id, &t.Title, &t.Author, &t.Forum, &t.Message, &t.Votes, &t.Slug, &t.Created, &t.IsClosed, &t.IsPinned); err != nil {
+			return nil, dbError(err, "")
+		}
+		threads = append(threads, t)
+	}
+	return threads, dbError(rows.Err(), "")
+}
 
-	var postsValues []interface{}
-	created := time.Now()
+// CreatePosts validates and inserts the whole batch in one transaction.
+// The thread and every author are locked FOR SHARE, so they cannot change
+// between validation and insert. A closed thread is ErrThreadClosed. A
+// rejected post is reported as a
+// *models.PostBatchError wrapping ErrPostAuthorNotFound or
+// ErrPostParentNotFound.
+func (r *threadRepo) CreatePosts(ctx context.Context, thread models.Thread, new models.PostsReq) (response *models.Posts, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "")
+	}
+	defer tx.Rollback(ctx)
 
-	for i, post := range new.Posts {
-		if i != 0 {
-			editQuery += ", "
+	if err = tx.QueryRow(ctx, lockThreadQ, thread.Id).Scan(&thread.Id, &thread.IsClosed); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrThreadNotFound
 		}
-		editQuery += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", 1+i*6, 2+i*6, 3+i*6, 4+i*6, 5+i*6, 6+i*6)
-		postsValues = append(postsValues, post.Parent, post.Author, post.Message, thread.Forum, thread.Id, created)
+		return nil, dbError(err, "")
+	}
+	if thread.IsClosed {
+		return nil, models.ErrThreadClosed
 	}
 
-	editQuery += ` RETURNING id, parent, author, message, is_edited, forum, thread, created;`
+	if err = validatePostBatch(ctx, tx, thread, new.Posts); err != nil {
+		return nil, dbError(err, "")
+	}
 
-	rows, err := r.db.Query(editQuery, postsValues...)
+	posts, err := insertPosts(ctx, tx, thread, new.Posts, r.copyThreshold)
 	if err != nil {
-		return nil, errors.New(err.Error())
+		return nil, dbError(err, "")
 	}
-	defer rows.Close()
 
-	response = &models.Posts{}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "")
+	}
+	return &models.Posts{Posts: posts}, nil
+}
+
+// validatePostBatch checks all authors with one query and all parents with
+// another, then reports the first rejected post in input order.
+func validatePostBatch(ctx context.Context, tx pgx.Tx, thread models.Thread, posts []models.PostReq) error {
+	nicknames := make([]string, 0, len(posts))
+	var parentIds []int64
+	for _, p := range posts {
+		nicknames = append(nicknames, p.Author)
+		if p.Parent != 0 {
+			parentIds = append(parentIds, int64(p.Parent))
+		}
+	}
 
+	// nickname is citext, so match case-insensitively here as well
+	authors := map[string]bool{}
+	rows, err := tx.Query(ctx, lockPostAuthorsQ, nicknames)
+	if err != nil {
+		return err
+	}
 	for rows.Next() {
-		var p models.Post
-		err = rows.Scan(
-			&p.Id,
-			&p.Parent,
-			&p.Author,
-			&p.Message,
-			&p.IsEdited,
-			&p.Forum,
-			&p.Thread,
-			&p.Created,
-		)
-		if err != nil {
-			return nil, errors.New(err.Error())
+		var nickname string
+		if err = rows.Scan(&nickname); err != nil {
+			rows.Close()
+			return err
 		}
+		authors[strings.ToLower(nickname)] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
 
-		response.Posts = append(response.Posts, p)
+	parents := map[int64]bool{}
+	if len(parentIds) > 0 {
+		rows, err = tx.Query(ctx, getPostParentsQ, thread.Id, parentIds)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			parents[id] = true
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
 	}
 
-	return response, nil
+	for i, p := range posts {
+		if !authors[strings.ToLower(p.Author)] {
+			return &models.PostBatchError{Index: i, Err: fmt.Errorf("%w: %s", models.ErrPostAuthorNotFound, p.Author)}
+		}
+		if p.Parent != 0 && !parents[int64(p.Parent)] {
+			return &models.PostBatchError{Index: i, Err: fmt.Errorf("%w: %d", models.ErrPostParentNotFound, p.Parent)}
+		}
+	}
+	return nil
 }
 
-func (r *threadRepo) CheckVotes(user, thread int) (vote models.Vote, err error) {
-	err = r.db.QueryRow(checkVotesQ, user, thread).Scan(&vote.Id, &vote.User, &vote.Thread, &vote.Voice)
+func (r *threadRepo) CheckVotes(ctx context.Context, user, thread int) (vote models.Vote, err error) {
+	err = r.db.QueryRow(ctx, checkVotesQ, user, thread).Scan(&vote.Id, &vote.User, &vote.Thread, &vote.Voice)
+	err = dbError(err, fmt.Sprintf("Can't find vote of user %d in thread %d", user, thread))
 	return
 }
 
-func (r *threadRepo) CreateVote(userId int, vote models.VoteRequest, thread models.Thread) (err error) {
-	err = r.db.QueryRow(createVoteQ, userId, thread.Id, vote.Voice).Scan(&userId)
+func (r *threadRepo) CreateVote(ctx context.Context, userId int, vote models.VoteRequest, thread models.Thread) (err error) {
+	err = r.db.QueryRow(ctx, createVoteQ, userId, thread.Id, vote.Voice).Scan(&userId)
+	err = dbError(err, "")
 	return
 }
 
-func (r *threadRepo) UpdateVote(vote models.VoteRequest, voteId int) (id int, err error) {
-	err = r.db.QueryRow(updateVoteQ, vote.Voice, voteId).Scan(&id)
+func (r *threadRepo) UpdateVote(ctx context.Context, vote models.VoteRequest, voteId int) (id int, err error) {
+	err = r.db.QueryRow(ctx, updateVoteQ, vote.Voice, voteId).Scan(&id)
+	err = dbError(err, fmt.Sprintf("Can't find vote with id: %d", voteId))
 	return
 }
 
-func (r *threadRepo) GetThreadPosts(thread models.Thread, since, sort string, limit int, desc bool) ([]models.Post, error) {
+func (r *threadRepo) GetThreadPosts(ctx context.Context, thread models.Thread, since, sort string, limit int, desc bool) ([]models.Post, error) {
 	posts := make([]models.Post, 0)
 
-	editQuery := getThreadPostsQ
+	q, err := threadPostsQuery(thread.Id, since, sort, limit, desc)
+	if err != nil {
+		return []models.Post{}, err
+	}
+
+	rows, err := r.db.Query(ctx, q.String(), q.Args()...)
+	if err != nil {
+		return []models.Post{}, dbError(err, "")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Post
+		err := rows.Scan(&p.Id, &p.Parent, &p.Author, &p.Message, &p.IsEdited, &p.IsDeleted, &p.Forum, &p.Thread, &p.Created)
+		if err != nil {
+			return []models.Post{}, dbError(err, "")
+		}
+
+		posts = append(posts, p)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.Post{}, dbError(err, "")
+	}
+
+	return posts, nil
+}
 
-	cmp := ">"
-	order := "ASC"
-	if desc {
-		cmp = "<"
-		order = "DESC"
+func threadPostsQuery(threadId int, since, sort string, limit int, desc bool) (*sqlQuery, error) {
+	var sinceId int64
+	if since != "" {
+		var err error
+		if sinceId, err = strconv.ParseInt(since, 10, 64); err != nil {
+			return nil, models.Validation("wrong since format")
+		}
 	}
 
+	limit = PageLimit(limit)
+	cmp := sinceCmp(desc, false)
+	order := sortOrder(desc)
+	q := newQuery(getThreadPostsQ, threadId)
+
 	switch sort {
 	case "flat":
 		if since != "" {
-			editQuery += fmt.Sprintf(` AND id %s %s `, cmp, since)
+			q.add(` AND id `+cmp+` ?`, sinceId)
 		}
-		editQuery += fmt.Sprintf(` ORDER BY created %s, id %s LIMIT %d `, order, order, limit)
+		q.add(` ORDER BY created ` + order + `, id ` + order)
 	case "tree":
 		if since != "" {
-			editQuery += fmt.Sprintf(` AND path %s (SELECT path FROM post WHERE id = %s) `, cmp, since)
+			q.add(` AND path `+cmp+` (SELECT path FROM post WHERE id = ?)`, sinceId)
 		}
-		editQuery += fmt.Sprintf(` ORDER BY path[1] %s, path %s limit %d `, order, order, limit)
+		q.add(` ORDER BY path[1] ` + order + `, path ` + order)
 	case "parent_tree":
-		editQuery += ` AND path && (SELECT ARRAY (SELECT id FROM post WHERE thread = $1 and parent = 0 `
+		q.add(` AND path && (SELECT ARRAY (SELECT id FROM post WHERE thread = $1 AND parent = 0`)
 		if since != "" {
-			editQuery += fmt.Sprintf(` AND path %s (SELECT path[1:1] FROM post WHERE id = %s) `, cmp, since)
+			q.add(` AND path `+cmp+` (SELECT path[1:1] FROM post WHERE id = ?)`, sinceId)
 		}
-		editQuery += fmt.Sprintf(` ORDER BY path[1] %s, path LIMIT %d)) ORDER BY path[1] %s, path `, order, limit, order)
+		q.add(` ORDER BY path[1] ` + order + `, path`)
+		q.add(` LIMIT ?`, limit)
+		q.add(`)) ORDER BY path[1] ` + order + `, path`)
+		return q, nil
 	default:
-		return []models.Post{}, errors.New("wrong sort name")
+		return nil, models.Validation("wrong sort name")
 	}
 
-	rows, err := r.db.Query(editQuery, thread.Id)
-	if err != nil {
-		return []models.Post{}, err
-	}
-	defer rows.Close()
+	q.add(` LIMIT ?`, limit)
+	return q, nil
+}
 
-	for rows.Next() {
-		var p models.Post
-		err := rows.Scan(&p.Id, &p.Parent, &p.Author, &p.Message, &p.IsEdited, &p.Forum, &p.Thread, &p.Created)
-		if err != nil {
-			return []models.Post{}, err
-		}
+func (r *threadRepo) SetState(ctx context.Context, thread models.Thread, state models.ThreadState) (t models.Thread, err error) {
+	err = r.db.QueryRow(ctx, setThreadStateQ, thread.Id, state.Closed, state.Pinned).Scan(
+		&t.Id, &t.Title, &t.Author, &t.Forum, &t.Message, &t.Votes, &t.Slug, &t.Created, &t.IsClosed, &t.IsPinned)
+	err = dbError(err, fmt.Sprintf("Can't find thread by id: %d", thread.Id))
+	return
+}
 
-		posts = append(posts, p)
+// Delete leaves forum_user alone: users stay members of the forums they
+// once wrote in.
+func (r *threadRepo) Delete(ctx context.Context, thread models.Thread) error {
+	var deleted int
+	if err := r.db.QueryRow(ctx, deleteThreadQ, thread.Id).Scan(&deleted); err != nil {
+		return dbError(err, "")
 	}
-
-	return posts, nil
+	if deleted == 0 {
+		return models.NotFound("Can't find thread by id: %d", thread.Id)
+	}
+	return nil
 }
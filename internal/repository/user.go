@@ -1,54 +1,112 @@
 package repository
 
 import (
+	"context"
 	"park_db_course/internal/models"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type UserRepoI interface {
-	Create(newUser models.User) (models.User, error)
-	GetByNickname(nickname string) (user models.User, err error)
-	GetByEmail(email string) (user models.User, err error)
-	GetByEmailOrNick(email, nickname string) (users []*models.User, err error)
-	Update(user models.User) (NewUser models.User, err error)
+	Create(ctx context.Context, newUser models.User) (models.User, error)
+	GetByNickname(ctx context.Context, nickname string) (user models.User, err error)
+	// GetCredentials is GetByNickname with the password hash, "" when the
+	// user has none, and the site role filled in.
+	GetCredentials(ctx context.Context, nickname string) (user models.User, err error)
+	SetRole(ctx context.Context, nickname string, role models.Role) (models.SiteRole, error)
+	// SetPassword replaces the password hash of nickname.
+	SetPassword(ctx context.Context, nickname, passwordHash string) error
+	// GetByNicknames returns the users found in one query, in no
+	// particular order. Unknown nicknames are skipped.
+	GetByNicknames(ctx context.Context, nicknames []string) ([]models.User, error)
+	GetByEmail(ctx context.Context, email string) (user models.User, err error)
+	GetByEmailOrNick(ctx context.Context, email, nickname string) (users []*models.User, err error)
+	Update(ctx context.Context, user models.User) (NewUser models.User, err error)
 }
 
 var (
-	createUserQ           = `INSERT INTO "user" (nickname, fullname, about, email) VALUES ($1, $2, $3, $4) RETURNING id, nickname, fullname, about, email;`
+	createUserQ           = `INSERT INTO "user" (nickname, fullname, about, email, password_hash) VALUES ($1, $2, $3, $4, NULLIF($5, '')) RETURNING id, nickname, fullname, about, email;`
 	getUserByNicknameQ    = `SELECT id, nickname, fullname, about, email FROM "user" WHERE nickname = $1;`
+	getCredentialsQ       = `SELECT id, nickname, fullname, about, email, coalesce(password_hash, ''), role FROM "user" WHERE nickname = $1;`
+	setRoleQ              = `UPDATE "user" SET role = $2 WHERE nickname = $1 RETURNING nickname, role;`
+	setPasswordQ          = `UPDATE "user" SET password_hash = $2 WHERE nickname = $1;`
+	getUsersByNicknamesQ  = `SELECT id, nickname, fullname, about, email FROM "user" WHERE nickname = ANY ($1::text[]::citext[]);`
 	getUserByEmailQ       = `SELECT id, nickname, fullname, about, email  FROM "user" WHERE email = $1;`
 	getUserByEmailOrNickQ = `SELECT id, nickname, fullname, about, email FROM "user" WHERE nickname = $1 OR email = $2;`
 	updateUserQ           = `UPDATE "user" SET fullname = $2, about = $3, email = $4 WHERE nickname = $1 RETURNING nickname, fullname, about, email;`
 )
 
 type userRepo struct {
-	db *pgx.ConnPool
+	db *pgxpool.Pool
 }
 
-func NewUserRepo(d *pgx.ConnPool) UserRepoI {
+func NewUserRepo(d *pgxpool.Pool) UserRepoI {
 	return &userRepo{db: d}
 }
 
-func (r *userRepo) Create(newUser models.User) (user models.User, err error) {
-	err = r.db.QueryRow(createUserQ, newUser.Nickname, newUser.Fullname, newUser.About, newUser.Email).Scan(&user.Id, &user.Nickname, &user.Fullname, &user.About, &user.Email)
+func (r *userRepo) Create(ctx context.Context, newUser models.User) (user models.User, err error) {
+	err = r.db.QueryRow(ctx, createUserQ, newUser.Nickname, newUser.Fullname, newUser.About, newUser.Email, newUser.PasswordHash).Scan(&user.Id, &user.Nickname, &user.Fullname, &user.About, &user.Email)
+	err = dbError(err, "")
 	return
 }
 
-func (r *userRepo) GetByNickname(nickname string) (user models.User, err error) {
-	err = r.db.QueryRow(getUserByNicknameQ, nickname).Scan(&user.Id, &user.Nickname, &user.Fullname, &user.About, &user.Email)
+func (r *userRepo) GetByNickname(ctx context.Context, nickname string) (user models.User, err error) {
+	err = r.db.QueryRow(ctx, getUserByNicknameQ, nickname).Scan(&user.Id, &user.Nickname, &user.Fullname, &user.About, &user.Email)
+	err = dbError(err, "Can't find user by nickname: "+nickname)
 	return
 }
 
-func (r *userRepo) GetByEmail(email string) (user models.User, err error) {
-	err = r.db.QueryRow(getUserByEmailQ, email).Scan(&user.Id, &user.Nickname, &user.Fullname, &user.About, &user.Email)
+func (r *userRepo) GetCredentials(ctx context.Context, nickname string) (user models.User, err error) {
+	err = r.db.QueryRow(ctx, getCredentialsQ, nickname).Scan(&user.Id, &user.Nickname, &user.Fullname, &user.About, &user.Email, &user.PasswordHash, &user.Role)
+	err = dbError(err, "Can't find user by nickname: "+nickname)
 	return
 }
 
-func (r *userRepo) GetByEmailOrNick(email, nickname string) (users []*models.User, err error) {
-	rows, err := r.db.Query(getUserByEmailOrNickQ, nickname, email)
+func (r *userRepo) SetRole(ctx context.Context, nickname string, role models.Role) (res models.SiteRole, err error) {
+	err = r.db.QueryRow(ctx, setRoleQ, nickname, string(role)).Scan(&res.Nickname, &res.Role)
+	err = dbError(err, "Can't find user by nickname: "+nickname)
+	return
+}
+
+func (r *userRepo) SetPassword(ctx context.Context, nickname, passwordHash string) error {
+	tag, err := r.db.Exec(ctx, setPasswordQ, nickname, passwordHash)
+	if err != nil {
+		return dbError(err, "")
+	}
+	if tag.RowsAffected() == 0 {
+		return models.NotFound("Can't find user by nickname: %s", nickname)
+	}
+	return nil
+}
+
+func (r *userRepo) GetByNicknames(ctx context.Context, nicknames []string) ([]models.User, error) {
+	rows, err := r.db.Query(ctx, getUsersByNicknamesQ, nicknames)
+	if err != nil {
+		return nil, dbError(err, "")
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0, len(nicknames))
+	for rows.Next() {
+		var u models.User
+		if err = rows.Scan(&u.Id, &u.Nickname, &u.Fullname, &u.About, &u.Email); err != nil {
+			return nil, dbError(err, "")
+		}
+		users = append(users, u)
+	}
+	return users, dbError(rows.Err(), "")
+}
+
+func (r *userRepo) GetByEmail(ctx context.Context, email string) (user models.User, err error) {
+	err = r.db.QueryRow(ctx, getUserByEmailQ, email).Scan(&user.Id, &user.Nickname, &user.Fullname, &user.About, &user.Email)
+	err = dbError(err, "Can't find user by email: "+email)
+	return
+}
+
+func (r *userRepo) GetByEmailOrNick(ctx context.Context, email, nickname string) (users []*models.User, err error) {
+	rows, err := r.db.Query(ctx, getUserByEmailOrNickQ, nickname, email)
 	if err != nil {
-		return nil, err
+		return nil, dbError(err, "")
 	}
 	defer rows.Close()
 
@@ -56,14 +114,15 @@ func (r *userRepo) GetByEmailOrNick(email, nickname string) (users []*models.Use
 		u := &models.User{}
 		err = rows.Scan(&u.Id, &u.Nickname, &u.Fullname, &u.About, &u.Email)
 		if err != nil {
-			return nil, err
+			return nil, dbError(err, "")
 		}
 		users = append(users, u)
 	}
-	return users, nil
+	return users, dbError(rows.Err(), "")
 }
 
-func (r *userRepo) Update(user models.User) (NewUser models.User, err error) {
-	err = r.db.QueryRow(updateUserQ, user.Nickname, user.Fullname, user.About, user.Email).Scan(&NewUser.Nickname, &NewUser.Fullname, &NewUser.About, &NewUser.Email)
+func (r *userRepo) Update(ctx context.Context, user models.User) (NewUser models.User, err error) {
+	err = r.db.QueryRow(ctx, updateUserQ, user.Nickname, user.Fullname, user.About, user.Email).Scan(&NewUser.Nickname, &NewUser.Fullname, &NewUser.About, &NewUser.Email)
+	err = dbError(err, "Can't find user by nickname: "+user.Nickname)
 	return
 }
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer records a span for every query, batch and COPY of a pool,
+// child of the span in the context of the call.
+func QueryTracer(tp trace.TracerProvider) pgx.QueryTracer {
+	return &queryTracer{tracer: tp.Tracer("park_db_course/internal/repository")}
+}
+
+type queryTracer struct {
+	tracer trace.Tracer
+}
+
+func (t *queryTracer) start(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	ctx, _ = t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, semconv.DBSystemPostgreSQL)...))
+	return ctx
+}
+
+func end(ctx context.Context, err error, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	op, table := statementName(data.SQL)
+	return t.start(ctx, strings.TrimSpace(op+" "+table),
+		semconv.DBQueryText(data.SQL), semconv.DBOperationName(op), semconv.DBCollectionName(table))
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	end(ctx, data.Err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+func (t *queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	return t.start(ctx, "batch", semconv.DBOperationName("batch"), attribute.Int("db.batch.size", data.Batch.Len()))
+}
+
+// TraceBatchQuery adds the queries of a batch to its span as events, they
+// run in one round trip.
+func (t *queryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	op, table := statementName(data.SQL)
+	attrs := []attribute.KeyValue{semconv.DBQueryText(data.SQL), attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected())}
+	if data.Err != nil {
+		attrs = append(attrs, attribute.String("error", data.Err.Error()))
+	}
+	trace.SpanFromContext(ctx).AddEvent(strings.TrimSpace(op+" "+table), trace.WithAttributes(attrs...))
+}
+
+func (t *queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	end(ctx, data.Err)
+}
+
+func (t *queryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	table := data.TableName.Sanitize()
+	return t.start(ctx, "COPY "+table, semconv.DBOperationName("COPY"), semconv.DBCollectionName(table))
+}
+
+func (t *queryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	end(ctx, data.Err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+// statementName returns the operation of a statement and the first table
+// it names, e.g. "SELECT" and "post", which name its span without the
+// cardinality of the full text.
+func statementName(sql string) (op, table string) {
+	words := strings.FieldsFunc(sql, func(r rune) bool {
+		return r == ' ' || r == '\n' || r == '\t' || r == '(' || r == ')' || r == ',' || r == ';'
+	})
+	if len(words) == 0 {
+		return "", ""
+	}
+	op = strings.ToUpper(words[0])
+
+	after := map[string]string{"SELECT": "FROM", "DELETE": "FROM", "INSERT": "INTO", "UPDATE": "UPDATE", "TRUNCATE": "TRUNCATE"}[op]
+	if after == "" {
+		return op, ""
+	}
+	for i, w := range words[:len(words)-1] {
+		if strings.EqualFold(w, after) {
+			return op, strings.Trim(words[i+1], `"`)
+		}
+	}
+	return op, ""
+}
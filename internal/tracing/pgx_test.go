@@ -0,0 +1,23 @@
+package tracing
+
+import "testing"
+
+func TestStatementName(t *testing.T) {
+	cases := []struct {
+		sql, op, table string
+	}{
+		{`SELECT id, parent, author FROM post WHERE id = $1;`, "SELECT", "post"},
+		{`SELECT (SELECT count(*) from forum), (SELECT count(*) from post);`, "SELECT", "forum"},
+		{`INSERT INTO "user" (nickname, fullname) VALUES ($1, $2) RETURNING id;`, "INSERT", "user"},
+		{`UPDATE thread SET votes = votes + $1 WHERE id = $2;`, "UPDATE", "thread"},
+		{`TRUNCATE "user", forum, thread CASCADE;`, "TRUNCATE", "user"},
+		{"\n\tselect nickname\n\tfrom forum_user;", "SELECT", "forum_user"},
+		{`LISTEN forum_events`, "LISTEN", ""},
+		{``, "", ""},
+	}
+	for _, c := range cases {
+		if op, table := statementName(c.sql); op != c.op || table != c.table {
+			t.Errorf("%q: got %q %q, want %q %q", c.sql, op, table, c.op, c.table)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package tracing sets up the OpenTelemetry tracer provider the http
+// handlers and the postgres pool record their spans with.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"park_db_course/cfg"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// ServiceName is the service.name of every span.
+const ServiceName = "forum"
+
+// Propagator reads and writes the W3C traceparent of requests.
+var Propagator = propagation.TraceContext{}
+
+// New returns the provider conf asks for and a function that flushes the
+// spans left and stops it. With exporter none nothing is recorded.
+func New(ctx context.Context, conf cfg.Tracing) (trace.TracerProvider, func(context.Context) error, error) {
+	var (
+		exp     sdktrace.SpanExporter
+		closeFn = func() error { return nil }
+		err     error
+	)
+	switch conf.Exporter {
+	case cfg.ExporterNone:
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	case cfg.ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case cfg.ExporterFile:
+		var f io.WriteCloser
+		if f, err = os.OpenFile(conf.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			return nil, nil, fmt.Errorf("open trace file: %w", err)
+		}
+		closeFn = f.Close
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	case cfg.ExporterOTLP:
+		exp, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(conf.Endpoint))
+	default:
+		err = fmt.Errorf("unknown exporter %q", conf.Exporter)
+	}
+	if err != nil {
+		closeFn()
+		return nil, nil, fmt.Errorf("create %s span exporter: %w", conf.Exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName))),
+	)
+	return tp, func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if cerr := closeFn(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"park_db_course/internal/auth"
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type AuthUsecaseI interface {
+	// Login checks the password of a user and issues a token to act as
+	// them.
+	Login(ctx context.Context, creds models.Credentials) (models.Token, error)
+	// Authenticate returns the caller of a token, without a lookup.
+	Authenticate(token string) (auth.Caller, error)
+}
+
+type authUsecase struct {
+	userRepo repository.UserRepoI
+	signer   *auth.Signer
+}
+
+func NewAuthUsecase(u repository.UserRepoI, signer *auth.Signer) AuthUsecaseI {
+	return &authUsecase{userRepo: u, signer: signer}
+}
+
+// errLogin does not tell an unknown user from a wrong password.
+var errLogin = models.Unauthorized("wrong nickname or password")
+
+// Login issues the token in the nickname as the user spelled it at
+// registration. Users registered without a password cannot log in.
+func (uc *authUsecase) Login(ctx context.Context, creds models.Credentials) (models.Token, error) {
+	user, err := uc.userRepo.GetCredentials(ctx, creds.Nickname)
+	if errors.Is(err, models.ErrNotFound) {
+		return models.Token{}, errLogin
+	}
+	if err != nil {
+		return models.Token{}, err
+	}
+	if !auth.CheckPassword(user.PasswordHash, creds.Password) {
+		return models.Token{}, errLogin
+	}
+	return uc.signer.Issue(user.Nickname), nil
+}
+
+func (uc *authUsecase) Authenticate(token string) (auth.Caller, error) {
+	return uc.signer.Verify(token)
+}
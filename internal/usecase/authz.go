@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"park_db_course/internal/auth"
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+// Authorizer decides what the caller of a request may do. Site admins are
+// the users holding the admin role, banned users may do nothing but read.
+// In a forum its owner and its moderators edit every thread and post,
+// everyone else only their own.
+//
+// With auth disabled there is no caller to judge and everything is
+// allowed, as before roles existed, but moderation: closing, pinning and
+// deleting threads and deleting post trees is refused unless
+// openModeration is set.
+type Authorizer struct {
+	userRepo       repository.UserRepoI
+	forumRepo      repository.ForumRepoI
+	openModeration bool
+}
+
+func NewAuthorizer(u repository.UserRepoI, f repository.ForumRepoI, openModeration bool) *Authorizer {
+	return &Authorizer{userRepo: u, forumRepo: f, openModeration: openModeration}
+}
+
+// errClosedModeration refuses moderation with auth disabled.
+var errClosedModeration = models.Forbidden("Moderation needs auth enabled or auth.open_moderation")
+
+// caller returns the nickname and site role of the caller. enforced is
+// false when auth is disabled. A request without a token is
+// ErrUnauthorized.
+func (a *Authorizer) caller(ctx context.Context) (nickname string, role models.Role, enforced bool, err error) {
+	c, enforced := auth.CallerFrom(ctx)
+	if !enforced {
+		return "", "", false, nil
+	}
+	if c.Anonymous() {
+		return "", "", true, models.Unauthorized("log in to do this")
+	}
+
+	// a token outlives a user dropped by a clear, what it then acts on
+	// is not found further on
+	user, err := a.userRepo.GetCredentials(ctx, c.Nickname)
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return c.Nickname, models.RoleMember, true, nil
+	case err != nil:
+		return "", "", true, err
+	}
+	return c.Nickname, user.Role, true, nil
+}
+
+// act returns the nickname an action is taken as. With auth enabled it is
+// the caller's whatever the request claims, and banned callers are
+// ErrForbidden. Otherwise the claimed nickname is taken at its word.
+func (a *Authorizer) act(ctx context.Context, claimed string) (string, error) {
+	nickname, role, enforced, err := a.caller(ctx)
+	if !enforced || err != nil {
+		return claimed, err
+	}
+	if role == models.RoleBanned {
+		return "", models.Forbidden("User %s is banned", nickname)
+	}
+	return nickname, nil
+}
+
+// forumRole returns the role of nickname in forum, the highest it holds.
+func (a *Authorizer) forumRole(ctx context.Context, forum models.Forum, nickname string, site models.Role) (models.Role, error) {
+	switch {
+	case site == models.RoleAdmin || site == models.RoleBanned:
+		return site, nil
+	case strings.EqualFold(forum.User, nickname):
+		return models.RoleOwner, nil
+	}
+	moderator, err := a.forumRepo.IsModerator(ctx, forum, nickname)
+	if err != nil || !moderator {
+		return models.RoleMember, err
+	}
+	return models.RoleModerator, nil
+}
+
+// mayEdit reports whether the caller may edit a thread or a post by author
+// in the forum forumSlug: their own, or any as its moderator, owner or a
+// site admin.
+func (a *Authorizer) mayEdit(ctx context.Context, forumSlug, author string) (bool, error) {
+	nickname, site, enforced, err := a.caller(ctx)
+	if !enforced || err != nil {
+		return !enforced, err
+	}
+	if site != models.RoleBanned && strings.EqualFold(nickname, author) {
+		return true, nil
+	}
+	return a.moderates(ctx, forumSlug, nickname, site)
+}
+
+// mayModerate reports whether the caller may close, pin and delete the
+// threads of the forum forumSlug: its moderators, owner and site admins.
+func (a *Authorizer) mayModerate(ctx context.Context, forumSlug string) (bool, error) {
+	nickname, site, enforced, err := a.caller(ctx)
+	if !enforced {
+		return a.unenforcedModeration()
+	}
+	if err != nil {
+		return false, err
+	}
+	return a.moderates(ctx, forumSlug, nickname, site)
+}
+
+// mayPurge reports whether the caller may delete post trees: site admins.
+func (a *Authorizer) mayPurge(ctx context.Context) (bool, error) {
+	_, site, enforced, err := a.caller(ctx)
+	if !enforced {
+		return a.unenforcedModeration()
+	}
+	if err != nil {
+		return false, err
+	}
+	return site == models.RoleAdmin, nil
+}
+
+func (a *Authorizer) unenforcedModeration() (bool, error) {
+	if !a.openModeration {
+		return false, errClosedModeration
+	}
+	return true, nil
+}
+
+func (a *Authorizer) moderates(ctx context.Context, forumSlug, nickname string, site models.Role) (bool, error) {
+	forum, err := a.forumRepo.GetBySlug(ctx, forumSlug)
+	if err != nil {
+		return false, err
+	}
+	role, err := a.forumRole(ctx, forum, nickname, site)
+	return role == models.RoleAdmin || role == models.RoleOwner || role == models.RoleModerator, err
+}
+
+// mayManage reports whether the caller may appoint the moderators of
+// forum: its owner or a site admin.
+func (a *Authorizer) mayManage(ctx context.Context, forum models.Forum) (bool, error) {
+	nickname, site, enforced, err := a.caller(ctx)
+	if !enforced || err != nil {
+		return !enforced, err
+	}
+	role, err := a.forumRole(ctx, forum, nickname, site)
+	return role == models.RoleAdmin || role == models.RoleOwner, err
+}
+
+// maySetPassword reports whether the caller may set the password of
+// nickname: the user themself or a site admin. Without auth anyone could
+// claim any account, passwords are set with the password command then.
+func (a *Authorizer) maySetPassword(ctx context.Context, nickname string) (bool, error) {
+	caller, site, enforced, err := a.caller(ctx)
+	if !enforced {
+		return false, models.Forbidden("Passwords are set with the password command while auth is disabled")
+	}
+	if err != nil || site == models.RoleBanned {
+		return false, err
+	}
+	return site == models.RoleAdmin || strings.EqualFold(caller, nickname), nil
+}
+
+// isAdmin reports whether the caller is a site admin.
+func (a *Authorizer) isAdmin(ctx context.Context) (bool, error) {
+	_, site, enforced, err := a.caller(ctx)
+	if !enforced || err != nil {
+		return !enforced, err
+	}
+	return site == models.RoleAdmin, nil
+}
@@ -0,0 +1,412 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"park_db_course/internal/logging"
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type FeedUsecaseI interface {
+	// Run listens for changes until ctx is done and listens again when the
+	// connection is lost, logging why with the logger of ctx. Every
+	// subscription ends when Run returns.
+	Run(ctx context.Context) error
+	// Watch subscribes to the changes of a thread. With lastPost > 0 the
+	// posts added after it are replayed first. ctx bounds the subscribing
+	// only, not the subscription.
+	Watch(ctx context.Context, slugOrId string, lastPost int64) (*Subscription, error)
+	// Follow opens a subscription to the activity of forums, which are
+	// added with ForumSubscription.Subscribe. ctx bounds the opening only.
+	Follow(ctx context.Context, opts FollowOptions) (*ForumSubscription, error)
+}
+
+// Subscription delivers the thread as it is now, the replayed posts and
+// then every change, in order. Updates is closed when the watcher falls
+// behind, the listener loses its connection or the feed stops: changes
+// may have been missed, and the watcher resumes by subscribing again with
+// the id of the last post it got.
+type Subscription struct {
+	Updates <-chan models.ThreadUpdate
+	close   func()
+}
+
+// Close ends the subscription and releases it, Updates is closed shortly
+// after.
+func (s *Subscription) Close() {
+	s.close()
+}
+
+const (
+	// watcherBuffer is how many changes a watcher may lag behind.
+	watcherBuffer = 256
+	// maxReplay is the most posts Watch replays, the limit of one page of
+	// /thread/{slug_or_id}/posts.
+	maxReplay = repository.MaxLimit
+
+	minListenBackoff = 100 * time.Millisecond
+	maxListenBackoff = 30 * time.Second
+)
+
+var (
+	errFeedStopped = errors.New("feed stopped")
+	errEventsLost  = errors.New("events were interrupted")
+)
+
+type feed struct {
+	events     repository.EventRepoI
+	threadRepo repository.ThreadRepoI
+	postRepo   repository.PostRepoI
+	userRepo   repository.UserRepoI
+
+	mu       sync.Mutex
+	watchers map[int]map[*watcher]struct{}
+	// followers are indexed by the folded slugs of the forums they follow
+	// and all of them are in followerSet, followed or not.
+	followers   map[string]map[*follower]struct{}
+	followerSet map[*follower]struct{}
+	// listening is closed once changes are received or the feed stopped,
+	// subscribers wait for it so that no change after a snapshot is missed.
+	listening chan struct{}
+	connected bool
+	stopped   bool
+}
+
+type watcher struct {
+	live chan models.ThreadUpdate
+}
+
+func NewFeed(e repository.EventRepoI, t repository.ThreadRepoI, p repository.PostRepoI, u repository.UserRepoI) FeedUsecaseI {
+	return &feed{
+		events:      e,
+		threadRepo:  t,
+		postRepo:    p,
+		userRepo:    u,
+		watchers:    map[int]map[*watcher]struct{}{},
+		followers:   map[string]map[*follower]struct{}{},
+		followerSet: map[*follower]struct{}{},
+		listening:   make(chan struct{}),
+	}
+}
+
+func (f *feed) Run(ctx context.Context) error {
+	backoff := minListenBackoff
+	for {
+		err := f.listen(ctx, func() { backoff = minListenBackoff })
+		f.disconnect(ctx.Err() != nil)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		logging.FromContext(ctx).WarnContext(ctx, "feed lost its listener", "error", err, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff = min(2*backoff, maxListenBackoff)
+	}
+}
+
+func (f *feed) listen(ctx context.Context, connected func()) error {
+	stream, err := f.events.Listen(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	connected()
+	f.mu.Lock()
+	f.connected = true
+	close(f.listening)
+	f.mu.Unlock()
+
+	for {
+		ev, err := stream.Next(ctx)
+		if err != nil {
+			return err
+		}
+		f.dispatch(ctx, ev)
+	}
+}
+
+// disconnect ends every subscription, as changes will be missed until
+// the feed listens again.
+func (f *feed) disconnect(stop bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for thread, watchers := range f.watchers {
+		for w := range watchers {
+			f.drop(thread, w)
+		}
+	}
+	err := errEventsLost
+	if stop {
+		err = errFeedStopped
+	}
+	for fl := range f.followerSet {
+		f.unfollow(fl, err)
+	}
+
+	if f.connected {
+		f.connected = false
+		f.listening = make(chan struct{})
+	}
+	if stop && !f.stopped {
+		f.stopped = true
+		close(f.listening)
+	}
+}
+
+// Which kinds thread watchers and forum followers get.
+var (
+	threadKinds = map[string]bool{models.EventPost: true, models.EventPostEdit: true, models.EventThread: true, models.EventVotes: true, models.EventThreadDelete: true}
+	forumKinds  = map[string]bool{models.EventPost: true, models.EventNewThread: true, models.EventThread: true, models.EventThreadDelete: true, models.EventMember: true}
+)
+
+// change holds the rows of an event, read once for every subscriber.
+type change struct {
+	post   *models.Post
+	thread *models.Thread
+	user   *models.User
+}
+
+func (f *feed) dispatch(ctx context.Context, ev models.Event) {
+	forum := strings.ToLower(ev.Forum)
+
+	f.mu.Lock()
+	watched := threadKinds[ev.Kind] && len(f.watchers[ev.Thread]) > 0
+	followed := forumKinds[ev.Kind] && len(f.followers[forum]) > 0
+	f.mu.Unlock()
+	if !watched && !followed {
+		return
+	}
+
+	changes, err := f.resolve(ctx, ev)
+	if err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "feed dropped an event", "kind", ev.Kind, "forum", ev.Forum, "thread", ev.Thread, "error", err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range changes {
+		if watched {
+			u := models.ThreadUpdate{Kind: ev.Kind, Post: c.post, Thread: c.thread}
+			for w := range f.watchers[ev.Thread] {
+				select {
+				case w.live <- u:
+					// nothing follows the deletion of a thread
+					if ev.Kind == models.EventThreadDelete {
+						f.drop(ev.Thread, w)
+					}
+				default:
+					f.drop(ev.Thread, w)
+				}
+			}
+		}
+		if followed {
+			for fl := range f.followers[forum] {
+				f.send(fl, models.ForumUpdate{Kind: ev.Kind, Forum: fl.forums[forum], Thread: c.thread, Post: c.post, User: c.user})
+			}
+		}
+	}
+}
+
+// resolve reads the rows of ev, a change for each post of a batch.
+func (f *feed) resolve(ctx context.Context, ev models.Event) ([]change, error) {
+	if ev.Kind == models.EventPost && ev.LastPost > 0 {
+		posts, err := f.postRepo.GetBatch(ctx, ev.Thread, ev.Post, ev.LastPost)
+		if err != nil {
+			return nil, err
+		}
+		changes := make([]change, len(posts))
+		for i := range posts {
+			changes[i].post = &posts[i]
+		}
+		return changes, nil
+	}
+
+	var c change
+	switch ev.Kind {
+	case models.EventPost, models.EventPostEdit:
+		info, err := f.postRepo.Get(ctx, int(ev.Post), nil)
+		if err != nil {
+			return nil, err
+		}
+		c.post = info.Post
+	case models.EventThread, models.EventVotes, models.EventNewThread:
+		threads, err := f.threadRepo.GetByIds(ctx, []int{ev.Thread})
+		if err != nil {
+			return nil, err
+		}
+		if len(threads) == 0 {
+			return nil, models.NotFound("Can't find thread by id: %d", ev.Thread)
+		}
+		c.thread = &threads[0]
+	case models.EventThreadDelete:
+		// the row is gone, the event is all there is
+		c.thread = &models.Thread{Id: ev.Thread, Forum: ev.Forum}
+	case models.EventMember:
+		users, err := f.userRepo.GetByNicknames(ctx, []string{ev.User})
+		if err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			return nil, models.NotFound("Can't find user by nickname: %s", ev.User)
+		}
+		c.user = &users[0]
+	default:
+		return nil, fmt.Errorf("unknown kind %q", ev.Kind)
+	}
+	return []change{c}, nil
+}
+
+func (f *feed) Watch(ctx context.Context, slugOrId string, lastPost int64) (*Subscription, error) {
+	thread, err := f.threadRepo.GetBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{live: make(chan models.ThreadUpdate, watcherBuffer)}
+	err = f.register(ctx, func() {
+		if f.watchers[thread.Id] == nil {
+			f.watchers[thread.Id] = map[*watcher]struct{}{}
+		}
+		f.watchers[thread.Id][w] = struct{}{}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var replay []models.Post
+	if lastPost > 0 {
+		replay, err = f.replay(ctx, thread, lastPost)
+		if err != nil {
+			f.remove(thread.Id, w)
+			return nil, err
+		}
+	}
+
+	out := make(chan models.ThreadUpdate)
+	done := make(chan struct{})
+	go w.forward(thread, replay, out, done)
+	return &Subscription{Updates: out, close: sync.OnceFunc(func() {
+		close(done)
+		f.remove(thread.Id, w)
+	})}, nil
+}
+
+// replay returns the posts added to thread after lastPost, a page at
+// most. A page is as many as the repositories read at once, so whether
+// more follow is asked separately.
+func (f *feed) replay(ctx context.Context, thread models.Thread, lastPost int64) ([]models.Post, error) {
+	posts, err := f.threadRepo.GetThreadPosts(ctx, thread, strconv.FormatInt(lastPost, 10), "flat", maxReplay, false)
+	if err != nil || len(posts) < maxReplay {
+		return posts, err
+	}
+	var last int64
+	for _, p := range posts {
+		last = max(last, p.Id)
+	}
+	more, err := f.threadRepo.GetThreadPosts(ctx, thread, strconv.FormatInt(last, 10), "flat", 1, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(more) > 0 {
+		return nil, models.Validation("more than %d posts were added after post %d, load them from the thread posts first", maxReplay, lastPost)
+	}
+	return posts, nil
+}
+
+// register runs add under f.mu once the feed is listening.
+func (f *feed) register(ctx context.Context, add func()) error {
+	for {
+		f.mu.Lock()
+		if f.stopped {
+			f.mu.Unlock()
+			return models.Unavailable(errFeedStopped)
+		}
+		if f.connected {
+			add()
+			f.mu.Unlock()
+			return nil
+		}
+		listening := f.listening
+		f.mu.Unlock()
+
+		select {
+		case <-listening:
+		case <-ctx.Done():
+			return models.Unavailable(fmt.Errorf("events are not received: %w", ctx.Err()))
+		}
+	}
+}
+
+func (f *feed) remove(thread int, w *watcher) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drop(thread, w)
+}
+
+// drop unregisters a watcher and closes its channel. Callers hold f.mu.
+func (f *feed) drop(thread int, w *watcher) {
+	if _, ok := f.watchers[thread][w]; !ok {
+		return
+	}
+	delete(f.watchers[thread], w)
+	if len(f.watchers[thread]) == 0 {
+		delete(f.watchers, thread)
+	}
+	close(w.live)
+}
+
+// forward sends the snapshot and the replay, then the live changes. Posts
+// that were replayed already and come in live as well are skipped.
+func (w *watcher) forward(thread models.Thread, replay []models.Post, out chan<- models.ThreadUpdate, done <-chan struct{}) {
+	defer close(out)
+
+	send := func(u models.ThreadUpdate) bool {
+		select {
+		case out <- u:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	if !send(models.ThreadUpdate{Kind: models.EventThread, Thread: &thread}) {
+		return
+	}
+	var replayed int64
+	for i := range replay {
+		if !send(models.ThreadUpdate{Kind: models.EventPost, Post: &replay[i]}) {
+			return
+		}
+		replayed = max(replayed, replay[i].Id)
+	}
+
+	for {
+		select {
+		case u, ok := <-w.live:
+			if !ok {
+				return
+			}
+			if u.Kind == models.EventPost && u.Post.Id <= replayed {
+				continue
+			}
+			if !send(u) {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
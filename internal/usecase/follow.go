@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"park_db_course/internal/models"
+)
+
+// ErrSlowConsumer ends a forum subscription that fell behind and does
+// not drop updates.
+var ErrSlowConsumer = errors.New("subscriber is too slow")
+
+type FollowOptions struct {
+	// Buffer is how many updates may wait for the follower.
+	Buffer int
+	// DropSlow drops the updates that do not fit in Buffer and counts them
+	// in Missed of the next one, instead of ending the subscription.
+	DropSlow bool
+	// MaxForums limits how many forums one subscription follows, 0 does
+	// not limit.
+	MaxForums int
+}
+
+// ForumSubscription delivers new threads, posts and members of the forums
+// it follows. Updates is closed when the subscription ends, see Err.
+type ForumSubscription struct {
+	Updates <-chan models.ForumUpdate
+
+	f  *feed
+	fl *follower
+}
+
+type follower struct {
+	updates chan models.ForumUpdate
+	opts    FollowOptions
+	// forums maps the folded slugs to the slugs as subscribed. Guarded by
+	// feed.mu, as are the other fields.
+	forums map[string]string
+	missed int
+	closed bool
+	err    error
+}
+
+func (f *feed) Follow(ctx context.Context, opts FollowOptions) (*ForumSubscription, error) {
+	fl := &follower{updates: make(chan models.ForumUpdate, max(opts.Buffer, 1)), opts: opts, forums: map[string]string{}}
+	err := f.register(ctx, func() {
+		f.followerSet[fl] = struct{}{}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ForumSubscription{Updates: fl.updates, f: f, fl: fl}, nil
+}
+
+// Subscribe follows forum, which the caller has looked up. Following it
+// again does nothing.
+func (s *ForumSubscription) Subscribe(forum models.Forum) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	key := strings.ToLower(forum.Slug)
+	if s.fl.closed {
+		return nil
+	}
+	if _, ok := s.fl.forums[key]; ok {
+		return nil
+	}
+	if s.fl.opts.MaxForums > 0 && len(s.fl.forums) >= s.fl.opts.MaxForums {
+		return models.Validation("can't follow more than %d forums at once", s.fl.opts.MaxForums)
+	}
+
+	s.fl.forums[key] = forum.Slug
+	if s.f.followers[key] == nil {
+		s.f.followers[key] = map[*follower]struct{}{}
+	}
+	s.f.followers[key][s.fl] = struct{}{}
+	return nil
+}
+
+// Unsubscribe stops following a forum. Updates of it that were queued
+// already are still delivered.
+func (s *ForumSubscription) Unsubscribe(slug string) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	key := strings.ToLower(slug)
+	delete(s.fl.forums, key)
+	delete(s.f.followers[key], s.fl)
+	if len(s.f.followers[key]) == 0 {
+		delete(s.f.followers, key)
+	}
+}
+
+// Close ends the subscription.
+func (s *ForumSubscription) Close() {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	s.f.unfollow(s.fl, nil)
+}
+
+// Err tells why Updates was closed: ErrSlowConsumer, an error of the feed
+// or nil after Close.
+func (s *ForumSubscription) Err() error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	return s.fl.err
+}
+
+// send hands u to a follower without waiting for it. Callers hold f.mu.
+func (f *feed) send(fl *follower, u models.ForumUpdate) {
+	u.Missed = fl.missed
+	select {
+	case fl.updates <- u:
+		fl.missed = 0
+	default:
+		if fl.opts.DropSlow {
+			fl.missed++
+			return
+		}
+		f.unfollow(fl, ErrSlowConsumer)
+	}
+}
+
+// unfollow unregisters a follower and closes its channel. Callers hold
+// f.mu.
+func (f *feed) unfollow(fl *follower, err error) {
+	if fl.closed {
+		return
+	}
+	for key := range fl.forums {
+		delete(f.followers[key], fl)
+		if len(f.followers[key]) == 0 {
+			delete(f.followers, key)
+		}
+	}
+	delete(f.followerSet, fl)
+	fl.closed, fl.err = true, err
+	close(fl.updates)
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type ForumUsecaseI interface {
+	Create(ctx context.Context, req models.ForumReq) (models.Forum, error)
+	Get(ctx context.Context, slug string) (models.Forum, error)
+	GetMany(ctx context.Context, slugs []string) ([]models.Forum, error)
+	CreateThread(ctx context.Context, slug string, req models.ThreadsReq) (models.Thread, error)
+	Threads(ctx context.Context, slug, since string, limit int, desc bool) ([]models.Thread, error)
+	Users(ctx context.Context, slug, since string, limit int, desc bool) ([]models.User, error)
+	Moderators(ctx context.Context, slug string) ([]models.User, error)
+	// AddModerator and RemoveModerator appoint and dismiss a moderator of
+	// the forum slug and return its moderators. With auth enabled only the
+	// owner of the forum and site admins may.
+	AddModerator(ctx context.Context, slug, nickname string) ([]models.User, error)
+	RemoveModerator(ctx context.Context, slug, nickname string) ([]models.User, error)
+}
+
+type forumUsecase struct {
+	forumRepo  repository.ForumRepoI
+	userRepo   repository.UserRepoI
+	threadRepo repository.ThreadRepoI
+	authz      *Authorizer
+}
+
+func NewForumUsecase(f repository.ForumRepoI, u repository.UserRepoI, t repository.ThreadRepoI, authz *Authorizer) ForumUsecaseI {
+	return &forumUsecase{
+		forumRepo:  f,
+		userRepo:   u,
+		threadRepo: t,
+		authz:      authz,
+	}
+}
+
+// Create makes a forum owned by req.User, or the caller with auth enabled,
+// stored with the nickname as the user spelled it at registration. If the
+// slug is taken, the existing forum is returned with an ErrConflict error.
+func (uc *forumUsecase) Create(ctx context.Context, req models.ForumReq) (models.Forum, error) {
+	var err error
+	if req.User, err = uc.authz.act(ctx, req.User); err != nil {
+		return models.Forum{}, err
+	}
+
+	forum, err := uc.forumRepo.GetBySlug(ctx, req.Slug)
+	if err == nil {
+		return forum, models.Conflict("Forum with slug %s already exists", forum.Slug)
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return models.Forum{}, err
+	}
+
+	owner, err := uc.userRepo.GetByNickname(ctx, req.User)
+	if err != nil {
+		return models.Forum{}, err
+	}
+	req.User = owner.Nickname
+
+	return uc.forumRepo.Create(ctx, req)
+}
+
+func (uc *forumUsecase) Get(ctx context.Context, slug string) (models.Forum, error) {
+	return uc.forumRepo.GetBySlug(ctx, slug)
+}
+
+// GetMany looks up several forums at once; unknown slugs are left out of
+// the result.
+func (uc *forumUsecase) GetMany(ctx context.Context, slugs []string) ([]models.Forum, error) {
+	return uc.forumRepo.GetBySlugs(ctx, slugs)
+}
+
+// CreateThread opens a thread in the forum slug, by req.Author or the
+// caller with auth enabled. If the thread slug is taken, the existing
+// thread is returned with an ErrConflict error.
+func (uc *forumUsecase) CreateThread(ctx context.Context, slug string, req models.ThreadsReq) (models.Thread, error) {
+	var err error
+	if req.Author, err = uc.authz.act(ctx, req.Author); err != nil {
+		return models.Thread{}, err
+	}
+
+	forum, err := uc.forumRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return models.Thread{}, err
+	}
+
+	if req.Slug != "" {
+		thread, err := uc.threadRepo.GetBySlugOrId(ctx, req.Slug)
+		if err == nil {
+			return thread, models.Conflict("Thread with slug %s already exists", thread.Slug)
+		}
+		if !errors.Is(err, models.ErrNotFound) {
+			return models.Thread{}, err
+		}
+	}
+	req.Forum = forum.Slug
+
+	author, err := uc.userRepo.GetByNickname(ctx, req.Author)
+	if err != nil {
+		return models.Thread{}, err
+	}
+	req.Author = author.Nickname
+
+	return uc.threadRepo.Create(ctx, req)
+}
+
+func (uc *forumUsecase) Threads(ctx context.Context, slug, since string, limit int, desc bool) ([]models.Thread, error) {
+	if _, err := uc.forumRepo.GetBySlug(ctx, slug); err != nil {
+		return nil, err
+	}
+	return uc.forumRepo.GetThreads(ctx, slug, since, limit, desc)
+}
+
+func (uc *forumUsecase) Users(ctx context.Context, slug, since string, limit int, desc bool) ([]models.User, error) {
+	forum, err := uc.forumRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	return uc.forumRepo.GetUsers(ctx, forum, since, limit, desc)
+}
+
+func (uc *forumUsecase) Moderators(ctx context.Context, slug string) ([]models.User, error) {
+	forum, err := uc.forumRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	return uc.forumRepo.GetModerators(ctx, forum)
+}
+
+// AddModerator is a no-op for a user already moderating the forum.
+func (uc *forumUsecase) AddModerator(ctx context.Context, slug, nickname string) ([]models.User, error) {
+	forum, user, err := uc.moderation(ctx, slug, nickname)
+	if err != nil {
+		return nil, err
+	}
+	if err = uc.forumRepo.AddModerator(ctx, forum, user); err != nil {
+		return nil, err
+	}
+	return uc.forumRepo.GetModerators(ctx, forum)
+}
+
+// RemoveModerator is ErrNotFound for a user not moderating the forum.
+func (uc *forumUsecase) RemoveModerator(ctx context.Context, slug, nickname string) ([]models.User, error) {
+	forum, user, err := uc.moderation(ctx, slug, nickname)
+	if err != nil {
+		return nil, err
+	}
+	if err = uc.forumRepo.RemoveModerator(ctx, forum, user); err != nil {
+		return nil, err
+	}
+	return uc.forumRepo.GetModerators(ctx, forum)
+}
+
+// moderation looks up the forum and the user a change of moderators is
+// about once the caller is allowed to make it.
+func (uc *forumUsecase) moderation(ctx context.Context, slug, nickname string) (models.Forum, models.User, error) {
+	forum, err := uc.forumRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return models.Forum{}, models.User{}, err
+	}
+	ok, err := uc.authz.mayManage(ctx, forum)
+	if err != nil {
+		return models.Forum{}, models.User{}, err
+	}
+	if !ok {
+		return models.Forum{}, models.User{}, models.Forbidden("Can't change the moderators of forum %s", forum.Slug)
+	}
+
+	user, err := uc.userRepo.GetByNickname(ctx, nickname)
+	if err != nil {
+		return models.Forum{}, models.User{}, err
+	}
+	return forum, user, nil
+}
@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"context"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type PostUsecaseI interface {
+	Get(ctx context.Context, id int, related []string) (models.PostFull, error)
+	Update(ctx context.Context, id int, upd models.PostUpdateReq) (models.Post, error)
+	// Delete leaves a placeholder of the post in its thread, see
+	// repository.PostRepoI.Delete. With auth enabled those who may edit the
+	// post may delete it.
+	Delete(ctx context.Context, id int, hideAuthor bool) (models.Post, error)
+	// DeleteTree removes the post with every reply below it. With auth
+	// enabled only site admins may, without it only with open moderation.
+	DeleteTree(ctx context.Context, id int) error
+}
+
+type postUsecase struct {
+	postRepo repository.PostRepoI
+	authz    *Authorizer
+}
+
+func NewPostUsecase(p repository.PostRepoI, authz *Authorizer) PostUsecaseI {
+	return &postUsecase{postRepo: p, authz: authz}
+}
+
+func (uc *postUsecase) Get(ctx context.Context, id int, related []string) (models.PostFull, error) {
+	return uc.postRepo.Get(ctx, id, related)
+}
+
+// Update edits the message. A post only becomes isEdited when the message
+// actually changes. With auth enabled users edit their own posts,
+// moderators any in their forum.
+func (uc *postUsecase) Update(ctx context.Context, id int, upd models.PostUpdateReq) (models.Post, error) {
+	info, err := uc.postRepo.Get(ctx, id, nil)
+	if err != nil {
+		return models.Post{}, err
+	}
+	ok, err := uc.authz.mayEdit(ctx, info.Post.Forum, info.Post.Author)
+	if err != nil {
+		return models.Post{}, err
+	}
+	if !ok {
+		return models.Post{}, models.Forbidden("Can't change post %d of user %s", id, info.Post.Author)
+	}
+	if info.Post.IsDeleted {
+		return models.Post{}, models.Conflict("Post %d is deleted", id)
+	}
+
+	if upd.Message == "" || upd.Message == info.Post.Message {
+		return *info.Post, nil
+	}
+	return uc.postRepo.Update(ctx, id, upd)
+}
+
+func (uc *postUsecase) Delete(ctx context.Context, id int, hideAuthor bool) (models.Post, error) {
+	info, err := uc.postRepo.Get(ctx, id, nil)
+	if err != nil {
+		return models.Post{}, err
+	}
+	ok, err := uc.authz.mayEdit(ctx, info.Post.Forum, info.Post.Author)
+	if err != nil {
+		return models.Post{}, err
+	}
+	if !ok {
+		return models.Post{}, models.Forbidden("Can't delete post %d of user %s", id, info.Post.Author)
+	}
+	return uc.postRepo.Delete(ctx, id, hideAuthor)
+}
+
+func (uc *postUsecase) DeleteTree(ctx context.Context, id int) error {
+	ok, err := uc.authz.mayPurge(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.Forbidden("Can't delete the replies to post %d", id)
+	}
+	_, err = uc.postRepo.DeleteTree(ctx, id)
+	return err
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type ServiceUsecaseI interface {
+	Status(ctx context.Context) (models.Status, error)
+	Clear(ctx context.Context) error
+	PoolStats(ctx context.Context) models.PoolStats
+}
+
+type serviceUsecase struct {
+	serviceRepo repository.ServiceRepoI
+	authz       *Authorizer
+}
+
+func NewServiceUsecase(s repository.ServiceRepoI, authz *Authorizer) ServiceUsecaseI {
+	return &serviceUsecase{serviceRepo: s, authz: authz}
+}
+
+func (uc *serviceUsecase) Status(ctx context.Context) (models.Status, error) {
+	return uc.serviceRepo.Status(ctx)
+}
+
+// Clear drops all data. With auth enabled only site admins may.
+func (uc *serviceUsecase) Clear(ctx context.Context) error {
+	ok, err := uc.authz.isAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.Forbidden("Only admins may clear the service")
+	}
+	return uc.serviceRepo.Clear(ctx)
+}
+
+func (uc *serviceUsecase) PoolStats(ctx context.Context) models.PoolStats {
+	return uc.serviceRepo.PoolStats(ctx)
+}
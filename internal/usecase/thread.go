@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type ThreadUsecaseI interface {
+	Get(ctx context.Context, slugOrId string) (models.Thread, error)
+	GetMany(ctx context.Context, ids []int) ([]models.Thread, error)
+	Update(ctx context.Context, slugOrId string, upd models.ThreadUpdateReq) (models.Thread, error)
+	AddPosts(ctx context.Context, slugOrId string, posts []models.PostReq) ([]models.Post, error)
+	Posts(ctx context.Context, slugOrId, since, sort string, limit int, desc bool) ([]models.Post, error)
+	Vote(ctx context.Context, slugOrId string, vote models.VoteRequest) (models.Thread, error)
+	// SetState closes or opens and pins or unpins a thread. With auth
+	// enabled only the moderators of its forum, its owner and site admins
+	// may, who may Delete it as well. Without auth both need open
+	// moderation.
+	SetState(ctx context.Context, slugOrId string, state models.ThreadState) (models.Thread, error)
+	Delete(ctx context.Context, slugOrId string) error
+}
+
+type threadUsecase struct {
+	threadRepo repository.ThreadRepoI
+	userRepo   repository.UserRepoI
+	authz      *Authorizer
+	// closedVotes lets closed threads take votes.
+	closedVotes bool
+}
+
+// NewThreadUsecase returns the thread use cases, closed threads take votes
+// when closedVotes is set.
+func NewThreadUsecase(t repository.ThreadRepoI, u repository.UserRepoI, authz *Authorizer, closedVotes bool) ThreadUsecaseI {
+	return &threadUsecase{threadRepo: t, userRepo: u, authz: authz, closedVotes: closedVotes}
+}
+
+func (uc *threadUsecase) Get(ctx context.Context, slugOrId string) (models.Thread, error) {
+	return uc.threadRepo.GetBySlugOrId(ctx, slugOrId)
+}
+
+// GetMany looks up several threads at once; unknown ids are left out of
+// the result.
+func (uc *threadUsecase) GetMany(ctx context.Context, ids []int) ([]models.Thread, error) {
+	return uc.threadRepo.GetByIds(ctx, ids)
+}
+
+// Update changes title and message; empty fields keep their value. With
+// auth enabled users edit their own threads, moderators any in their forum.
+func (uc *threadUsecase) Update(ctx context.Context, slugOrId string, upd models.ThreadUpdateReq) (models.Thread, error) {
+	thread, err := uc.threadRepo.GetBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		return models.Thread{}, err
+	}
+	ok, err := uc.authz.mayEdit(ctx, thread.Forum, thread.Author)
+	if err != nil {
+		return models.Thread{}, err
+	}
+	if !ok {
+		return models.Thread{}, models.Forbidden("Can't change thread %d of user %s", thread.Id, thread.Author)
+	}
+
+	if upd.Title == "" && upd.Message == "" {
+		return thread, nil
+	}
+	if upd.Title == "" {
+		upd.Title = thread.Title
+	}
+	if upd.Message == "" {
+		upd.Message = thread.Message
+	}
+	return uc.threadRepo.Update(ctx, thread, upd)
+}
+
+// AddPosts stores the batch in the thread all or nothing. A rejected post
+// is reported as a *models.PostBatchError. With auth enabled every post is
+// by the caller.
+func (uc *threadUsecase) AddPosts(ctx context.Context, slugOrId string, posts []models.PostReq) ([]models.Post, error) {
+	author, err := uc.authz.act(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if author != "" {
+		posts = append([]models.PostReq(nil), posts...)
+		for i := range posts {
+			posts[i].Author = author
+		}
+	}
+
+	thread, err := uc.threadRepo.GetBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		return nil, err
+	}
+	if thread.IsClosed {
+		return nil, models.ErrThreadClosed
+	}
+	if len(posts) == 0 {
+		return []models.Post{}, nil
+	}
+
+	created, err := uc.threadRepo.CreatePosts(ctx, thread, models.PostsReq{Posts: posts})
+	if err != nil {
+		return nil, err
+	}
+	return created.Posts, nil
+}
+
+func (uc *threadUsecase) Posts(ctx context.Context, slugOrId, since, sort string, limit int, desc bool) ([]models.Post, error) {
+	thread, err := uc.threadRepo.GetBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		return nil, err
+	}
+	return uc.threadRepo.GetThreadPosts(ctx, thread, since, sort, limit, desc)
+}
+
+// Vote records the voice of a user, one per user and thread. Repeating a
+// voice changes nothing, flipping it moves the rating by twice the voice
+// because the old one is taken back. The returned thread carries the new
+// rating. With auth enabled the voice is the caller's.
+// Closed threads take votes only when configured to.
+func (uc *threadUsecase) Vote(ctx context.Context, slugOrId string, vote models.VoteRequest) (models.Thread, error) {
+	var err error
+	if vote.Nickname, err = uc.authz.act(ctx, vote.Nickname); err != nil {
+		return models.Thread{}, err
+	}
+
+	thread, err := uc.threadRepo.GetBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		return models.Thread{}, err
+	}
+	if thread.IsClosed && !uc.closedVotes {
+		return models.Thread{}, models.ErrThreadClosed
+	}
+
+	user, err := uc.userRepo.GetByNickname(ctx, vote.Nickname)
+	if err != nil {
+		return models.Thread{}, err
+	}
+
+	prev, err := uc.threadRepo.CheckVotes(ctx, user.Id, thread.Id)
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		if err = uc.threadRepo.CreateVote(ctx, user.Id, vote, thread); err != nil {
+			return models.Thread{}, err
+		}
+		thread.Votes += vote.Voice
+	case err != nil:
+		return models.Thread{}, err
+	case prev.Voice != vote.Voice:
+		if _, err = uc.threadRepo.UpdateVote(ctx, vote, prev.Id); err != nil {
+			return models.Thread{}, err
+		}
+		thread.Votes += 2 * vote.Voice
+	}
+	return thread, nil
+}
+
+// SetState closes or opens and pins or unpins a thread, fields left out
+// keep their value.
+func (uc *threadUsecase) SetState(ctx context.Context, slugOrId string, state models.ThreadState) (models.Thread, error) {
+	thread, err := uc.moderated(ctx, slugOrId)
+	if err != nil {
+		return models.Thread{}, err
+	}
+	if state.Closed == nil && state.Pinned == nil {
+		return thread, nil
+	}
+	return uc.threadRepo.SetState(ctx, thread, state)
+}
+
+// Delete drops a thread with its posts and votes.
+func (uc *threadUsecase) Delete(ctx context.Context, slugOrId string) error {
+	thread, err := uc.moderated(ctx, slugOrId)
+	if err != nil {
+		return err
+	}
+	return uc.threadRepo.Delete(ctx, thread)
+}
+
+// moderated returns the thread slugOrId if the caller moderates its forum.
+func (uc *threadUsecase) moderated(ctx context.Context, slugOrId string) (models.Thread, error) {
+	thread, err := uc.threadRepo.GetBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		return models.Thread{}, err
+	}
+	ok, err := uc.authz.mayModerate(ctx, thread.Forum)
+	if err != nil {
+		return models.Thread{}, err
+	}
+	if !ok {
+		return models.Thread{}, models.Forbidden("Can't moderate thread %d in forum %s", thread.Id, thread.Forum)
+	}
+	return thread, nil
+}
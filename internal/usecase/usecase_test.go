@@ -0,0 +1,313 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository/memory"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testUsecases struct {
+	users   UserUsecaseI
+	forums  ForumUsecaseI
+	threads ThreadUsecaseI
+	posts   PostUsecaseI
+	feed    FeedUsecaseI
+}
+
+// newTestUsecases returns use cases over a fresh in-memory store with
+// users alice and bob, forum pirates and thread jolly.
+func newTestUsecases(t *testing.T) testUsecases {
+	t.Helper()
+
+	s := memory.NewStore()
+	userRepo, forumRepo, threadRepo := memory.NewUserRepo(s), memory.NewForumRepo(s), memory.NewThreadRepo(s)
+	authz := NewAuthorizer(userRepo, forumRepo, false)
+	uc := testUsecases{
+		users:   NewUserUsecase(userRepo, bcrypt.MinCost, authz),
+		forums:  NewForumUsecase(forumRepo, userRepo, threadRepo, authz),
+		threads: NewThreadUsecase(threadRepo, userRepo, authz, true),
+		posts:   NewPostUsecase(memory.NewPostRepo(s), authz),
+		feed:    NewFeed(memory.NewEventRepo(s), threadRepo, memory.NewPostRepo(s), userRepo),
+	}
+
+	ctx := context.Background()
+	for _, u := range []models.User{
+		{Nickname: "alice", Fullname: "Alice", Email: "alice@mail.ru"},
+		{Nickname: "bob", Fullname: "Bob", Email: "bob@mail.ru"},
+	} {
+		if _, _, err := uc.users.Create(ctx, u, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := uc.forums.Create(ctx, models.ForumReq{Title: "Pirates", User: "alice", Slug: "pirates"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.forums.CreateThread(ctx, "pirates", models.ThreadsReq{Title: "Jolly", Author: "alice", Message: "m", Slug: "jolly"}); err != nil {
+		t.Fatal(err)
+	}
+	return uc
+}
+
+func TestVote(t *testing.T) {
+	uc := newTestUsecases(t)
+	ctx := context.Background()
+
+	steps := []struct {
+		nickname string
+		voice    int
+		votes    int
+	}{
+		{"alice", 1, 1},
+		{"alice", 1, 1},   // repeating a voice changes nothing
+		{"bob", -1, 0},    // a new voter counts once
+		{"alice", -1, -2}, // flipping takes the old voice back
+		{"BOB", 1, 0},     // nicknames are case-insensitive
+	}
+	for i, s := range steps {
+		thread, err := uc.threads.Vote(ctx, "jolly", models.VoteRequest{Nickname: s.nickname, Voice: s.voice})
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if thread.Votes != s.votes {
+			t.Fatalf("step %d: votes %d, want %d", i, thread.Votes, s.votes)
+		}
+		if stored, _ := uc.threads.Get(ctx, "jolly"); stored.Votes != s.votes {
+			t.Fatalf("step %d: stored votes %d, want %d", i, stored.Votes, s.votes)
+		}
+	}
+
+	if _, err := uc.threads.Vote(ctx, "jolly", models.VoteRequest{Nickname: "nobody", Voice: 1}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("unknown voter: %v, want ErrNotFound", err)
+	}
+	if _, err := uc.threads.Vote(ctx, "nope", models.VoteRequest{Nickname: "alice", Voice: 1}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("unknown thread: %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreateConflicts(t *testing.T) {
+	uc := newTestUsecases(t)
+	ctx := context.Background()
+
+	forum, err := uc.forums.Create(ctx, models.ForumReq{Title: "Other", User: "bob", Slug: "PIRATES"})
+	if !errors.Is(err, models.ErrConflict) || forum.User != "alice" {
+		t.Errorf("forum slug taken: %+v, %v", forum, err)
+	}
+	forum, err = uc.forums.Create(ctx, models.ForumReq{Title: "Sailors", User: "BOB", Slug: "sailors"})
+	if err != nil || forum.User != "bob" {
+		t.Errorf("forum owner is not spelled as registered: %+v, %v", forum, err)
+	}
+
+	thread, err := uc.forums.CreateThread(ctx, "sailors", models.ThreadsReq{Title: "T", Author: "bob", Message: "m", Slug: "Jolly"})
+	if !errors.Is(err, models.ErrConflict) || thread.Forum != "pirates" {
+		t.Errorf("thread slug taken: %+v, %v", thread, err)
+	}
+
+	_, existing, err := uc.users.Create(ctx, models.User{Nickname: "ALICE", Email: "bob@mail.ru"}, "")
+	if !errors.Is(err, models.ErrConflict) || len(existing) != 2 {
+		t.Errorf("user create: %d existing users, %v", len(existing), err)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	uc := newTestUsecases(t)
+	ctx := context.Background()
+
+	about, email := "captain", "bob@mail.ru"
+	user, err := uc.users.UpdateProfile(ctx, "alice", models.UserUpdate{About: &about})
+	if err != nil || user.About != "captain" || user.Fullname != "Alice" || user.Email != "alice@mail.ru" {
+		t.Errorf("partial update: %+v, %v", user, err)
+	}
+
+	if _, err = uc.users.UpdateProfile(ctx, "alice", models.UserUpdate{Email: &email}); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("email of bob: %v, want ErrConflict", err)
+	}
+
+	email = "ALICE@mail.ru"
+	if _, err = uc.users.UpdateProfile(ctx, "alice", models.UserUpdate{Email: &email}); err != nil {
+		t.Errorf("own email: %v", err)
+	}
+}
+
+func TestPosts(t *testing.T) {
+	uc := newTestUsecases(t)
+	ctx := context.Background()
+
+	posts, err := uc.threads.AddPosts(ctx, "jolly", nil)
+	if err != nil || posts == nil || len(posts) != 0 {
+		t.Fatalf("empty batch: %v, %v", posts, err)
+	}
+
+	posts, err = uc.threads.AddPosts(ctx, "jolly", []models.PostReq{{Author: "alice", Message: "m"}})
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("add: %v, %v", posts, err)
+	}
+	id := int(posts[0].Id)
+
+	post, err := uc.posts.Update(ctx, id, models.PostUpdateReq{Message: "m"})
+	if err != nil || post.IsEdited {
+		t.Errorf("same message marked the post edited: %+v, %v", post, err)
+	}
+	post, err = uc.posts.Update(ctx, id, models.PostUpdateReq{Message: "edited"})
+	if err != nil || !post.IsEdited || post.Message != "edited" {
+		t.Errorf("edit: %+v, %v", post, err)
+	}
+
+	var batchErr *models.PostBatchError
+	_, err = uc.threads.AddPosts(ctx, "jolly", []models.PostReq{{Author: "alice", Message: "m"}, {Author: "nobody", Message: "m"}})
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("unknown author: %v", err)
+	}
+}
+
+// receive returns the next update, ok is false once updates are closed.
+func receive[U any](t *testing.T, updates <-chan U) (u U, ok bool) {
+	t.Helper()
+	select {
+	case u, ok = <-updates:
+		return u, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("no update within 5s")
+		return u, false
+	}
+}
+
+func TestWatch(t *testing.T) {
+	uc := newTestUsecases(t)
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- uc.feed.Run(ctx) }()
+
+	if _, err := uc.feed.Watch(ctx, "nope", 0); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("unknown thread: %v", err)
+	}
+
+	sub, err := uc.feed.Watch(ctx, "jolly", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := receive(t, sub.Updates); u.Kind != models.EventThread || u.Thread.Slug != "jolly" {
+		t.Fatalf("snapshot: %+v", u)
+	}
+
+	// A watcher that does not keep up is dropped after the posts it has
+	// room for, and resumes from the last one it got.
+	batch := make([]models.PostReq, 2*watcherBuffer)
+	for i := range batch {
+		batch[i] = models.PostReq{Author: "alice", Message: "m"}
+	}
+	if _, err = uc.threads.AddPosts(ctx, "jolly", batch); err != nil {
+		t.Fatal(err)
+	}
+	var last int64
+	for {
+		u, ok := receive(t, sub.Updates)
+		if !ok {
+			break
+		}
+		if u.Post.Id != last+1 {
+			t.Fatalf("got post %d after %d", u.Post.Id, last)
+		}
+		last = u.Post.Id
+	}
+	if last == 0 || last == int64(len(batch)) {
+		t.Fatalf("watcher got %d of %d posts, want to be dropped in between", last, len(batch))
+	}
+
+	sub, err = uc.feed.Watch(ctx, "1", last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, sub.Updates)
+	for last < int64(len(batch)) {
+		u, _ := receive(t, sub.Updates)
+		if u.Kind != models.EventPost || u.Post.Id != last+1 {
+			t.Fatalf("resumed with %s %+v after post %d", u.Kind, u.Post, last)
+		}
+		last = u.Post.Id
+	}
+
+	stop()
+	if _, ok := receive(t, sub.Updates); ok {
+		t.Error("updates continue after the feed stopped")
+	}
+	if err = <-stopped; err != nil {
+		t.Errorf("run: %v", err)
+	}
+	if _, err = uc.feed.Watch(context.Background(), "jolly", 0); !errors.Is(err, models.ErrUnavailable) {
+		t.Errorf("watch after stop: %v", err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	uc := newTestUsecases(t)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go uc.feed.Run(ctx)
+
+	pirates, err := uc.forums.Get(ctx, "pirates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	follow := func(opts FollowOptions) *ForumSubscription {
+		t.Helper()
+		sub, err := uc.feed.Follow(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = sub.Subscribe(pirates); err != nil {
+			t.Fatal(err)
+		}
+		return sub
+	}
+	fast := follow(FollowOptions{Buffer: 64, MaxForums: 1})
+	dropping := follow(FollowOptions{Buffer: 4, DropSlow: true})
+	slow := follow(FollowOptions{Buffer: 4})
+
+	if err = fast.Subscribe(models.Forum{Slug: "navy"}); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("over max forums: %v", err)
+	}
+
+	batch := make([]models.PostReq, 10)
+	for i := range batch {
+		batch[i] = models.PostReq{Author: "alice", Message: "m"}
+	}
+	if _, err = uc.threads.AddPosts(ctx, "jolly", batch); err != nil {
+		t.Fatal(err)
+	}
+	// The fast follower got the batch once every follower was sent it.
+	for i := range batch {
+		if u, _ := receive(t, fast.Updates); u.Kind != models.EventPost || u.Forum != "pirates" {
+			t.Fatalf("post %d: %+v", i+1, u)
+		}
+	}
+
+	var got int
+	for range slow.Updates {
+		got++
+	}
+	if got != 4 || !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("slow follower got %d updates and %v, want 4 and to be disconnected", got, slow.Err())
+	}
+
+	for i := 0; i < 4; i++ {
+		receive(t, dropping.Updates)
+	}
+	if _, err = uc.threads.AddPosts(ctx, "jolly", batch[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := receive(t, dropping.Updates); u.Post == nil || u.Post.Id != 11 || u.Missed != 6 {
+		t.Errorf("after dropping: %+v, want post 11 with 6 missed", u)
+	}
+
+	dropping.Unsubscribe("PIRATES")
+	dropping.Close()
+	if _, ok := receive(t, dropping.Updates); ok || dropping.Err() != nil {
+		t.Errorf("closed subscription: updates open or error %v", dropping.Err())
+	}
+}
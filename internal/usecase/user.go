@@ -0,0 +1,155 @@
+// Package usecase holds the rules of the forum independent of any
+// transport: what counts as a conflict, how a vote moves the rating, which
+// fields of a profile an update may change. Handlers only decode requests
+// and encode results; every error is one of the domain errors of
+// internal/models.
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"park_db_course/internal/auth"
+	"park_db_course/internal/models"
+	"park_db_course/internal/repository"
+)
+
+type UserUsecaseI interface {
+	// Create registers user, able to log in when password is set. With
+	// auth enabled a password is required.
+	Create(ctx context.Context, user models.User, password string) (created models.User, existing []*models.User, err error)
+	Get(ctx context.Context, nickname string) (models.User, error)
+	GetMany(ctx context.Context, nicknames []string) ([]models.User, error)
+	UpdateProfile(ctx context.Context, nickname string, upd models.UserUpdate) (models.User, error)
+	// SetRole makes a user a site admin, a member again or bans them.
+	// With auth enabled only site admins may.
+	SetRole(ctx context.Context, role models.SiteRole) (models.SiteRole, error)
+	// SetPassword sets the password nickname logs in with. Users set their
+	// own, site admins anyone's, so users registered without a password can
+	// get one. It needs auth enabled.
+	SetPassword(ctx context.Context, nickname, password string) error
+}
+
+type userUsecase struct {
+	userRepo     repository.UserRepoI
+	passwordCost int
+	authz        *Authorizer
+}
+
+// NewUserUsecase stores passwords hashed with bcrypt at passwordCost.
+func NewUserUsecase(u repository.UserRepoI, passwordCost int, authz *Authorizer) UserUsecaseI {
+	return &userUsecase{userRepo: u, passwordCost: passwordCost, authz: authz}
+}
+
+// Create registers user. When the nickname or the email is taken, the
+// users holding them are returned with an ErrConflict error.
+func (uc *userUsecase) Create(ctx context.Context, user models.User, password string) (models.User, []*models.User, error) {
+	if _, enabled := auth.CallerFrom(ctx); enabled && password == "" {
+		return models.User{}, nil, models.Validation("Can't create user %s without a password", user.Nickname)
+	}
+
+	existing, err := uc.userRepo.GetByEmailOrNick(ctx, user.Email, user.Nickname)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	if len(existing) > 0 {
+		return models.User{}, existing, models.Conflict("Can't create user with nickname %s or email %s", user.Nickname, user.Email)
+	}
+
+	if password != "" {
+		if user.PasswordHash, err = auth.HashPassword(password, uc.passwordCost); err != nil {
+			return models.User{}, nil, err
+		}
+	}
+	if _, err = uc.userRepo.Create(ctx, user); err != nil {
+		return models.User{}, nil, err
+	}
+	user.PasswordHash = ""
+	return user, nil, nil
+}
+
+func (uc *userUsecase) Get(ctx context.Context, nickname string) (models.User, error) {
+	return uc.userRepo.GetByNickname(ctx, nickname)
+}
+
+// GetMany looks up several users at once; nicknames nobody holds are
+// left out of the result.
+func (uc *userUsecase) GetMany(ctx context.Context, nicknames []string) ([]models.User, error) {
+	return uc.userRepo.GetByNicknames(ctx, nicknames)
+}
+
+// UpdateProfile applies upd to the profile of nickname. An email may
+// belong to one user only. With auth enabled users change their own
+// profile only.
+func (uc *userUsecase) UpdateProfile(ctx context.Context, nickname string, upd models.UserUpdate) (models.User, error) {
+	who, err := uc.authz.act(ctx, nickname)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !strings.EqualFold(who, nickname) {
+		return models.User{}, models.Forbidden("Can't change the profile of user %s", nickname)
+	}
+
+	user, err := uc.userRepo.GetByNickname(ctx, nickname)
+	if err != nil {
+		return models.User{}, err
+	}
+	if upd.Fullname != nil {
+		user.Fullname = *upd.Fullname
+	}
+	if upd.About != nil {
+		user.About = *upd.About
+	}
+	if upd.Email != nil {
+		user.Email = *upd.Email
+	}
+
+	owner, err := uc.userRepo.GetByEmail(ctx, user.Email)
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+	case err != nil:
+		return models.User{}, err
+	case owner.Nickname != user.Nickname:
+		return models.User{}, models.Conflict("This email is already registered by user %s", owner.Nickname)
+	}
+
+	return uc.userRepo.Update(ctx, user)
+}
+
+func (uc *userUsecase) SetRole(ctx context.Context, role models.SiteRole) (models.SiteRole, error) {
+	switch role.Role {
+	case models.RoleAdmin, models.RoleMember, models.RoleBanned:
+	default:
+		return models.SiteRole{}, models.Validation("Role %q is not one of admin, member and banned", role.Role)
+	}
+
+	ok, err := uc.authz.isAdmin(ctx)
+	if err != nil {
+		return models.SiteRole{}, err
+	}
+	if !ok {
+		return models.SiteRole{}, models.Forbidden("Can't change the role of user %s", role.Nickname)
+	}
+	return uc.userRepo.SetRole(ctx, role.Nickname, role.Role)
+}
+
+func (uc *userUsecase) SetPassword(ctx context.Context, nickname, password string) error {
+	if password == "" {
+		return models.Validation("Can't set an empty password for user %s", nickname)
+	}
+
+	ok, err := uc.authz.maySetPassword(ctx, nickname)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.Forbidden("Can't set the password of user %s", nickname)
+	}
+
+	hash, err := auth.HashPassword(password, uc.passwordCost)
+	if err != nil {
+		return err
+	}
+	return uc.userRepo.SetPassword(ctx, nickname, hash)
+}